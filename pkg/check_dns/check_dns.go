@@ -9,7 +9,6 @@ import (
 	"net"
 	"runtime"
 	"slices"
-	"strconv"
 	"strings"
 	"time"
 
@@ -41,7 +40,11 @@ func Check(ctx context.Context, output io.Writer, args []string) int {
 type dnsOpts struct {
 	Host            string   `short:"H" long:"host" required:"true" description:"The name or address you want to query"`
 	Servers         []string `short:"s" long:"server" description:"DNS servers to use for the lookup. This can be specified multiple times."`
-	Port            int      `short:"p" long:"port" default:"53" description:"Port number you want to use"`
+	Port            int      `short:"p" long:"port" description:"Port number you want to use. Default is 53, 853 for dot and 443 for doh."`
+	Transport       string   `long:"transport" default:"udp" choice:"udp" choice:"tcp" choice:"dot" choice:"doh" description:"Transport used to query the nameservers: udp, tcp, dns-over-tls (dot) or dns-over-https (doh)."`
+	DoHMethod       string   `long:"doh-method" default:"POST" choice:"GET" choice:"POST" description:"HTTP method used for dns-over-https queries."`
+	TLSServerName   string   `long:"tls-servername" description:"Server name used to verify the certificate of dot and doh nameservers. Default is the nameserver address."`
+	Insecure        bool     `long:"insecure" description:"Skip certificate verification of dot and doh nameservers."`
 	QueryType       string   `short:"q" long:"querytype" default:"A" description:"DNS record query type"`
 	Norec           bool     `long:"norec" description:"Clears the Recursion Desired flag, DNS server answers only from its authoritative data or cache, does not ask other nameservers."`
 	ExpectedString  []string `short:"e" long:"expected-string" description:"IP-ADDRESS string you expect the DNS server to return. If multiple IP-ADDRESS are returned at once, you have to specify whole string"`
@@ -52,6 +55,13 @@ type dnsOpts struct {
 	CriticalTimeout *int     `short:"c" long:"critical" description:"Return critical if elapsed time to get a successful DNS query exceeds this value in seconds. Default ist off."`
 	Timeout         int      `short:"t" long:"timeout" default:"30" description:"Global timeout in seconds. Exit early and return unknown if elapsed time to get a successful DNS query exceeds this value."`
 	QueryTimeout    int      `short:"T" long:"query-timeout" default:"5" description:"Timeout for each single DNS query in seconds. If exceeded, the next query is tried instead of exiting."`
+	DNSSEC          bool     `long:"dnssec" description:"Validate the DNSSEC chain of trust of the answer up to the trust anchor."`
+	TrustAnchors    []string `long:"trust-anchor" description:"DS or DNSKEY record (or a file containing them) used as trust anchor for --dnssec. This can be specified multiple times. Default are the root zone key signing keys."`
+	DNSSECWarning   int      `long:"dnssec-warning" default:"7" description:"Return warning if a DNSSEC signature of the queried zone expires within this number of days. Signatures of parent zones are not taken into account."`
+	DNSSECCritical  int      `long:"dnssec-critical" default:"3" description:"Return critical if a DNSSEC signature of the queried zone expires within this number of days. Signatures of parent zones are not taken into account."`
+	SOAConsistency  bool     `long:"soa-consistency" description:"Query the SOA record of the zone given by --host from all authoritative nameservers and return critical if the serials differ."`
+
+	trustAnchors []dns.RR
 }
 
 func parseArgs(args []string) (*dnsOpts, error) {
@@ -73,6 +83,9 @@ func (opts *dnsOpts) validate() error {
 	if strings.TrimSpace(opts.QueryType) == "" {
 		return fmt.Errorf("query type must not be empty")
 	}
+	if opts.Port == 0 {
+		opts.Port = defaultTransportPorts[opts.Transport]
+	}
 	if opts.Port < 1 || opts.Port > 65535 {
 		return fmt.Errorf("port must be between 1 and 65535, got: %d", opts.Port)
 	}
@@ -96,6 +109,22 @@ func (opts *dnsOpts) validate() error {
 			return fmt.Errorf("expected string must not be empty")
 		}
 	}
+	if opts.SOAConsistency && opts.Transport != transportUDP && opts.Transport != transportTCP {
+		return fmt.Errorf("--soa-consistency supports udp and tcp transport only")
+	}
+	if opts.DNSSEC && opts.SOAConsistency {
+		return fmt.Errorf("--dnssec and --soa-consistency cannot be used together")
+	}
+	if opts.DNSSECWarning < opts.DNSSECCritical {
+		return fmt.Errorf("dnssec warning days (%d) must not be lower than the dnssec critical days (%d)", opts.DNSSECWarning, opts.DNSSECCritical)
+	}
+	if opts.DNSSEC {
+		anchors, err := parseTrustAnchors(opts.TrustAnchors)
+		if err != nil {
+			return err
+		}
+		opts.trustAnchors = anchors
+	}
 
 	return nil
 }
@@ -135,12 +164,16 @@ func (opts *dnsOpts) run(ctx context.Context) *checkers.Checker {
 		}
 	}
 	for i := range nameservers {
-		nameservers[i] = net.JoinHostPort(nameservers[i], strconv.Itoa(opts.Port))
+		nameservers[i] = opts.nameserverAddress(nameservers[i])
 	}
 	if logger != nil && opts.Verbose {
 		logger.Tracef("DNS nameservers: %v ", nameservers)
 	}
 
+	if opts.SOAConsistency {
+		return opts.checkSOAConsistency(ctx, nameservers)
+	}
+
 	var searchPaths []string
 	if len(opts.SearchPaths) > 0 {
 		searchPaths = opts.SearchPaths
@@ -173,7 +206,7 @@ func (opts *dnsOpts) run(ctx context.Context) *checkers.Checker {
 	}
 
 	// Timeout is a builtin cumulative timeout for dial, write and read, it is applied to every single Exchange i.e. DNS query.
-	c := &dns.Client{Timeout: time.Duration(opts.QueryTimeout) * time.Second}
+	c := opts.newTransport()
 
 	var r *dns.Msg
 	var duration time.Duration
@@ -213,8 +246,12 @@ func (opts *dnsOpts) run(ctx context.Context) *checkers.Checker {
 					},
 				}
 				message.Id = dns.Id()
+				if opts.DNSSEC {
+					message.SetEdns0(4096, true)
+					message.CheckingDisabled = true
+				}
 
-				r, duration, err = c.Exchange(message, nameserver)
+				r, duration, err = c.ExchangeContext(ctx, message, nameserver)
 
 				if err == nil {
 					if len(r.Answer) == 0 {
@@ -286,6 +323,9 @@ func (opts *dnsOpts) run(ctx context.Context) *checkers.Checker {
 	answersWithoutHeaders := make([]string, 0)
 	answerTypes := make([]string, 0)
 	for _, answer := range r.Answer {
+		if _, ok := answer.(*dns.RRSIG); ok {
+			continue
+		}
 		answerWithoutHeader, answerType, err := dnsAnswer(answer)
 		if err != nil {
 			return checkers.Critical(err.Error())
@@ -342,6 +382,14 @@ func (opts *dnsOpts) run(ctx context.Context) *checkers.Checker {
 		escalateStatus(checkers.CRITICAL)
 	}
 
+	dnssecMsg, dnssecMetric := "", ""
+	if opts.DNSSEC {
+		var dnssecStatus checkers.Status
+		dnssecStatus, dnssecMsg, dnssecMetric = opts.validateDNSSEC(ctx, c, successfulNameserver, r.Answer)
+		tryLogTrace(fmt.Sprintf("DNSSEC validation returned %s%s", dnssecStatus.String(), dnssecMsg))
+		escalateStatus(dnssecStatus)
+	}
+
 	timeMetric := fmt.Sprintf(
 		"time=%fs;%s;%s", queriesDuration.Seconds(),
 		func() string {
@@ -358,11 +406,17 @@ func (opts *dnsOpts) run(ctx context.Context) *checkers.Checker {
 		}(),
 	)
 
+	transportMsg, tlsMetric := "", ""
+	if c.tlsVersion != 0 {
+		transportMsg = fmt.Sprintf(" via %s (TLS %s)", transportNames[opts.Transport], c.TLSVersion())
+		tlsMetric = fmt.Sprintf(" tls_handshake=%fs tls_version=%s", c.tlsHandshake.Seconds(), c.TLSVersion())
+	}
+
 	msg := ""
 	if len(answersWithoutHeaders) > 0 && len(answerTypes) > 0 {
-		msg = fmt.Sprintf("%s returns %s (%s) |%s\n", opts.Host, answersWithoutHeaders[0], answerTypes[0], timeMetric)
+		msg = fmt.Sprintf("%s returns %s (%s)%s%s |%s%s%s\n", opts.Host, answersWithoutHeaders[0], answerTypes[0], transportMsg, dnssecMsg, timeMetric, tlsMetric, dnssecMetric)
 	} else {
-		msg = fmt.Sprintf("%s (%s) returns no answer from %s\n", opts.Host, opts.QueryType, successfulNameserver)
+		msg = fmt.Sprintf("%s (%s) returns no answer from %s%s\n", opts.Host, opts.QueryType, successfulNameserver, dnssecMsg)
 	}
 
 	msg += fmt.Sprintf("HEADER-> %s\n", r.MsgHdr.String())
@@ -0,0 +1,393 @@
+package check_dns
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"github.com/miekg/dns"
+)
+
+// defaultTrustAnchors contains the DS records of the root zone key signing keys (KSK-2017 and KSK-2024)
+// see https://data.iana.org/root-anchors/root-anchors.xml
+var defaultTrustAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// dnssecValidator verifies the chain of trust of an answer using DNSKEY and DS records
+// from the given nameserver up to a configured trust anchor.
+type dnssecValidator struct {
+	client     dnsExchanger
+	nameserver string
+	anchors    []dns.RR
+	now        time.Time
+
+	// zoneKeys caches the validated DNSKEYs by zone
+	zoneKeys map[string][]*dns.DNSKEY
+	// zoneExpiry contains the expiration of the DNSKEY signature by zone
+	zoneExpiry map[string]time.Time
+	// expiry is the earliest expiration of the answer signatures and the DNSKEY signature of their zone,
+	// signatures of parent zones are maintained by the parent and not taken into account
+	expiry time.Time
+	// signatures counts the verified signatures
+	signatures int
+}
+
+// parseTrustAnchors parses DS or DNSKEY records given as string or file name
+func parseTrustAnchors(anchors []string) ([]dns.RR, error) {
+	if len(anchors) == 0 {
+		anchors = defaultTrustAnchors
+	}
+
+	records := []dns.RR{}
+	for _, anchor := range anchors {
+		source := anchor
+		if _, err := os.Stat(anchor); err == nil {
+			data, err := os.ReadFile(anchor)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read trust anchor file: %s", err.Error())
+			}
+			source = string(data)
+		}
+
+		parser := dns.NewZoneParser(strings.NewReader(source), ".", anchor)
+		for record, ok := parser.Next(); ok; record, ok = parser.Next() {
+			switch record.(type) {
+			case *dns.DS, *dns.DNSKEY:
+				records = append(records, record)
+			}
+		}
+		if err := parser.Err(); err != nil {
+			return nil, fmt.Errorf("cannot parse trust anchor %s: %s", anchor, err.Error())
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no DS or DNSKEY record found in trust anchors")
+	}
+
+	return records, nil
+}
+
+// validateDNSSEC validates the answer and returns the status, a message suffix and the expiry metric
+func (opts *dnsOpts) validateDNSSEC(ctx context.Context, client dnsExchanger, nameserver string, answer []dns.RR) (status checkers.Status, msg, metric string) {
+	validator := newDNSSECValidator(client, nameserver, opts.trustAnchors)
+	if err := validator.ValidateAnswer(ctx, answer); err != nil {
+		return checkers.CRITICAL, fmt.Sprintf(" - DNSSEC validation failed: %s", err.Error()), ""
+	}
+
+	days := validator.ExpiryDays()
+	status = checkers.OK
+	switch {
+	case days < opts.DNSSECCritical:
+		status = checkers.CRITICAL
+	case days < opts.DNSSECWarning:
+		status = checkers.WARNING
+	}
+
+	msg = fmt.Sprintf(" - DNSSEC valid, %d signatures verified, first signature expires in %d days", validator.signatures, days)
+	metric = fmt.Sprintf(" dnssec_expiry_days=%d;%d;%d", days, opts.DNSSECWarning, opts.DNSSECCritical)
+
+	return status, msg, metric
+}
+
+func newDNSSECValidator(client dnsExchanger, nameserver string, anchors []dns.RR) *dnssecValidator {
+	return &dnssecValidator{
+		client:     client,
+		nameserver: nameserver,
+		anchors:    anchors,
+		now:        time.Now(),
+		zoneKeys:   map[string][]*dns.DNSKEY{},
+		zoneExpiry: map[string]time.Time{},
+	}
+}
+
+// ExpiryDays returns the number of days until the first signature of the queried zone expires
+func (v *dnssecValidator) ExpiryDays() int {
+	return int(v.expiry.Sub(v.now).Hours() / 24)
+}
+
+func (v *dnssecValidator) addExpiry(expiry time.Time) {
+	if v.expiry.IsZero() || expiry.Before(v.expiry) {
+		v.expiry = expiry
+	}
+}
+
+// ValidateAnswer verifies the signatures of all rrsets in the answer section and their chain of trust
+func (v *dnssecValidator) ValidateAnswer(ctx context.Context, answer []dns.RR) error {
+	rrsets, sigs := splitRRsets(answer)
+	if len(rrsets) == 0 {
+		return fmt.Errorf("answer contains no records")
+	}
+
+	for _, rrset := range rrsets {
+		header := rrset[0].Header()
+		signers := signerNames(sigs, header.Name, header.Rrtype)
+		if len(signers) == 0 {
+			return fmt.Errorf("no RRSIG for %s %s", header.Name, dns.TypeToString[header.Rrtype])
+		}
+
+		// the signer name is the zone containing the record, miekg/dns only compares the name as string suffix
+		for _, signer := range signers {
+			if !dns.IsSubDomain(signer, header.Name) {
+				return fmt.Errorf("%s %s is signed by %s which is not a parent zone", header.Name, dns.TypeToString[header.Rrtype], signer)
+			}
+		}
+		keys, err := v.validateZone(ctx, signers[0])
+		if err != nil {
+			return err
+		}
+		v.addExpiry(v.zoneExpiry[dns.CanonicalName(signers[0])])
+
+		expiry, err := v.verifyRRset(rrset, sigs, keys)
+		if err != nil {
+			return fmt.Errorf("%s %s: %s", header.Name, dns.TypeToString[header.Rrtype], err.Error())
+		}
+		v.addExpiry(expiry)
+	}
+
+	return nil
+}
+
+// validateZone returns the DNSKEYs of the zone after verifying them against the trust anchor or the DS records of the parent zone
+func (v *dnssecValidator) validateZone(ctx context.Context, zone string) ([]*dns.DNSKEY, error) {
+	zone = dns.CanonicalName(zone)
+	if keys, ok := v.zoneKeys[zone]; ok {
+		return keys, nil
+	}
+
+	answer, err := v.query(ctx, zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*dns.DNSKEY{}
+	rrset := []dns.RR{}
+	for _, record := range answer {
+		if key, ok := record.(*dns.DNSKEY); ok && dns.CanonicalName(key.Hdr.Name) == zone {
+			keys = append(keys, key)
+			rrset = append(rrset, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no DNSKEY found for %s", zone)
+	}
+
+	var trusted []*dns.DNSKEY
+	if v.isAnchor(zone) {
+		trusted = v.anchoredKeys(zone, keys)
+		if len(trusted) == 0 {
+			return nil, fmt.Errorf("DNSKEY of %s does not match the trust anchor", zone)
+		}
+	} else {
+		trusted, err = v.delegatedKeys(ctx, zone, keys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the DNSKEY rrset must be signed by one of the trusted keys
+	_, sigs := splitRRsets(answer)
+	expiry, err := v.verifyRRset(rrset, sigs, trusted)
+	if err != nil {
+		return nil, fmt.Errorf("%s DNSKEY: %s", zone, err.Error())
+	}
+
+	v.zoneKeys[zone] = keys
+	v.zoneExpiry[zone] = expiry
+
+	return keys, nil
+}
+
+// delegatedKeys returns the keys of the zone matching a DS record which is signed by the parent zone
+func (v *dnssecValidator) delegatedKeys(ctx context.Context, zone string, keys []*dns.DNSKEY) ([]*dns.DNSKEY, error) {
+	answer, err := v.query(ctx, zone, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+
+	rrsets, sigs := splitRRsets(answer)
+	dsSet := []dns.RR{}
+	for _, rrset := range rrsets {
+		if rrset[0].Header().Rrtype == dns.TypeDS {
+			dsSet = rrset
+		}
+	}
+	if len(dsSet) == 0 {
+		return nil, fmt.Errorf("no DS record found for %s, chain of trust is broken", zone)
+	}
+
+	parents := signerNames(sigs, zone, dns.TypeDS)
+	if len(parents) == 0 {
+		return nil, fmt.Errorf("no RRSIG for %s DS", zone)
+	}
+	// the DS record must be signed by a parent zone, otherwise the validation would never end
+	if parent := dns.CanonicalName(parents[0]); parent == zone || !dns.IsSubDomain(parent, zone) {
+		return nil, fmt.Errorf("DS record of %s is not signed by a parent zone", zone)
+	}
+
+	parentKeys, err := v.validateZone(ctx, parents[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := v.verifyRRset(dsSet, sigs, parentKeys); err != nil {
+		return nil, fmt.Errorf("%s DS: %s", zone, err.Error())
+	}
+
+	trusted := []*dns.DNSKEY{}
+	for _, key := range keys {
+		for _, record := range dsSet {
+			if ds, ok := record.(*dns.DS); ok && dsMatchesKey(ds, key) {
+				trusted = append(trusted, key)
+			}
+		}
+	}
+	if len(trusted) == 0 {
+		return nil, fmt.Errorf("no DNSKEY of %s matches the DS record", zone)
+	}
+
+	return trusted, nil
+}
+
+// verifyRRset checks that at least one valid signature from the given keys exists for the rrset and returns its expiration
+func (v *dnssecValidator) verifyRRset(rrset []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY) (time.Time, error) {
+	header := rrset[0].Header()
+	lastErr := fmt.Errorf("no RRSIG matches a DNSKEY")
+	for _, sig := range sigs {
+		if sig.TypeCovered != header.Rrtype || dns.CanonicalName(sig.Hdr.Name) != dns.CanonicalName(header.Name) {
+			continue
+		}
+
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm || dns.CanonicalName(key.Hdr.Name) != dns.CanonicalName(sig.SignerName) {
+				continue
+			}
+
+			if err := sig.Verify(key, rrset); err != nil {
+				lastErr = fmt.Errorf("invalid signature from key %d: %s", key.KeyTag(), err.Error())
+
+				continue
+			}
+
+			if !sig.ValidityPeriod(v.now) {
+				lastErr = fmt.Errorf("signature from key %d is not valid between %s and %s",
+					key.KeyTag(), dns.TimeToString(sig.Inception), dns.TimeToString(sig.Expiration))
+
+				continue
+			}
+
+			v.signatures++
+
+			return time.Unix(int64(sig.Expiration), 0), nil
+		}
+	}
+
+	return time.Time{}, lastErr
+}
+
+// isAnchor returns true if there is a trust anchor for the given zone
+func (v *dnssecValidator) isAnchor(zone string) bool {
+	for _, anchor := range v.anchors {
+		if dns.CanonicalName(anchor.Header().Name) == zone {
+			return true
+		}
+	}
+
+	return false
+}
+
+// anchoredKeys returns the keys matching a trust anchor of the zone
+func (v *dnssecValidator) anchoredKeys(zone string, keys []*dns.DNSKEY) []*dns.DNSKEY {
+	trusted := []*dns.DNSKEY{}
+	for _, key := range keys {
+		for _, anchor := range v.anchors {
+			if dns.CanonicalName(anchor.Header().Name) != zone {
+				continue
+			}
+
+			switch anchor := anchor.(type) {
+			case *dns.DS:
+				if dsMatchesKey(anchor, key) {
+					trusted = append(trusted, key)
+				}
+			case *dns.DNSKEY:
+				if anchor.Algorithm == key.Algorithm && anchor.PublicKey == key.PublicKey {
+					trusted = append(trusted, key)
+				}
+			}
+		}
+	}
+
+	return trusted
+}
+
+func (v *dnssecValidator) query(ctx context.Context, name string, qtype uint16) ([]dns.RR, error) {
+	message := new(dns.Msg)
+	message.SetQuestion(dns.Fqdn(name), qtype)
+	message.SetEdns0(4096, true)
+	// validation is done here, so ask the resolver to return data even if it fails to validate
+	message.CheckingDisabled = true
+
+	response, _, err := v.client.ExchangeContext(ctx, message, v.nameserver)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s query failed: %s", name, dns.TypeToString[qtype], err.Error())
+	}
+	if response.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("%s %s query failed: %s", name, dns.TypeToString[qtype], emptyResultReason(response.Rcode))
+	}
+
+	return response.Answer, nil
+}
+
+// splitRRsets groups the records by name and type, signatures are returned separately
+func splitRRsets(records []dns.RR) (rrsets [][]dns.RR, sigs []*dns.RRSIG) {
+	index := map[string]int{}
+	for _, record := range records {
+		if sig, ok := record.(*dns.RRSIG); ok {
+			sigs = append(sigs, sig)
+
+			continue
+		}
+
+		key := dns.CanonicalName(record.Header().Name) + "/" + dns.TypeToString[record.Header().Rrtype]
+		if i, ok := index[key]; ok {
+			rrsets[i] = append(rrsets[i], record)
+
+			continue
+		}
+		index[key] = len(rrsets)
+		rrsets = append(rrsets, []dns.RR{record})
+	}
+
+	return rrsets, sigs
+}
+
+// signerNames returns the signer names of all signatures covering the given name and type
+func signerNames(sigs []*dns.RRSIG, name string, rrtype uint16) []string {
+	signers := []string{}
+	for _, sig := range sigs {
+		if sig.TypeCovered == rrtype && dns.CanonicalName(sig.Hdr.Name) == dns.CanonicalName(name) {
+			signers = append(signers, sig.SignerName)
+		}
+	}
+
+	return signers
+}
+
+func dsMatchesKey(ds *dns.DS, key *dns.DNSKEY) bool {
+	if ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+		return false
+	}
+
+	keyDS := key.ToDS(ds.DigestType)
+	if keyDS == nil {
+		return false
+	}
+
+	return strings.EqualFold(keyDS.Digest, ds.Digest)
+}
@@ -0,0 +1,230 @@
+package check_dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/consol-monitoring/snclient/pkg/utils"
+	"github.com/mackerelio/checkers"
+	"github.com/miekg/dns"
+)
+
+// soaResult contains the SOA serial returned by a single authoritative nameserver
+type soaResult struct {
+	nameserver string
+	address    string
+	serial     uint32
+	err        error
+}
+
+func (res *soaResult) String() string {
+	if res.err != nil {
+		return fmt.Sprintf("%s (%s): %s", res.nameserver, res.address, res.err.Error())
+	}
+
+	return fmt.Sprintf("%s (%s): %d", res.nameserver, res.address, res.serial)
+}
+
+// checkSOAConsistency queries the SOA record from all authoritative nameservers of the zone and compares the serials
+func (opts *dnsOpts) checkSOAConsistency(ctx context.Context, resolvers []string) *checkers.Checker {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(opts.Timeout)*time.Second)
+	defer cancel()
+
+	logger := utils.LoggerFromContext(ctx)
+	client := &dns.Client{Net: opts.Transport, Timeout: time.Duration(opts.QueryTimeout) * time.Second}
+	zone := dns.Fqdn(opts.Host)
+	started := time.Now()
+
+	nsNames, glue, err := opts.lookupNS(ctx, client, resolvers, zone)
+	if err != nil {
+		return checkers.Critical(err.Error())
+	}
+	if logger != nil && opts.Verbose {
+		logger.Tracef("authoritative nameservers for %s: %v", zone, nsNames)
+	}
+
+	results := []*soaResult{}
+	for _, nsName := range nsNames {
+		addresses := glue[nsName]
+		if len(addresses) == 0 {
+			addresses = opts.lookupAddresses(ctx, client, resolvers, nsName)
+		}
+		if len(addresses) == 0 {
+			results = append(results, &soaResult{nameserver: nsName, address: "-", err: fmt.Errorf("no address found")})
+
+			continue
+		}
+
+		for _, address := range addresses {
+			result := &soaResult{nameserver: nsName, address: address}
+			result.serial, result.err = querySOASerial(ctx, client, zone, net.JoinHostPort(address, strconv.Itoa(opts.Port)))
+			if logger != nil && opts.Verbose {
+				logger.Tracef("SOA serial from %s", result.String())
+			}
+			results = append(results, result)
+		}
+	}
+	duration := time.Since(started)
+
+	checkSt := checkers.OK
+	problems := []string{}
+	serials := []uint32{}
+	for _, result := range results {
+		if result.err != nil {
+			checkSt = checkers.CRITICAL
+			problems = append(problems, result.String())
+
+			continue
+		}
+		if !slices.Contains(serials, result.serial) {
+			serials = append(serials, result.serial)
+		}
+	}
+
+	if len(serials) > 1 {
+		checkSt = checkers.CRITICAL
+		for _, result := range results {
+			if result.err == nil {
+				problems = append(problems, result.String())
+			}
+		}
+	}
+
+	switch {
+	case checkSt != checkers.OK:
+	case opts.CriticalTimeout != nil && duration.Seconds() > float64(*opts.CriticalTimeout):
+		checkSt = checkers.CRITICAL
+	case opts.WarningTimeout != nil && duration.Seconds() > float64(*opts.WarningTimeout):
+		checkSt = checkers.WARNING
+	}
+
+	perfData := fmt.Sprintf("time=%fs;%s;%s nameservers=%d", duration.Seconds(), thresholdString(opts.WarningTimeout), thresholdString(opts.CriticalTimeout), len(results))
+
+	switch {
+	case len(serials) > 1:
+		return checkers.NewChecker(checkSt, fmt.Sprintf("%s SOA serials differ |%s\n%s", opts.Host, perfData, strings.Join(problems, "\n")))
+	case len(problems) > 0:
+		return checkers.NewChecker(checkSt, fmt.Sprintf("%s SOA query failed on %d of %d nameservers |%s\n%s", opts.Host, len(problems), len(results), perfData, strings.Join(problems, "\n")))
+	default:
+		return checkers.NewChecker(checkSt, fmt.Sprintf("%s SOA serial %d is consistent on %d nameservers |%s", opts.Host, serials[0], len(results), perfData))
+	}
+}
+
+// lookupNS returns the sorted names of the authoritative nameservers and addresses from the glue records
+func (opts *dnsOpts) lookupNS(ctx context.Context, client *dns.Client, resolvers []string, zone string) (names []string, glue map[string][]string, err error) {
+	var response *dns.Msg
+	for _, resolver := range resolvers {
+		message := new(dns.Msg)
+		message.SetQuestion(zone, dns.TypeNS)
+		message.RecursionDesired = !opts.Norec
+
+		response, _, err = client.ExchangeContext(ctx, message, resolver)
+		if err != nil {
+			err = fmt.Errorf("NS query for %s failed: %s: %s", zone, resolver, queryFailedReason(err))
+
+			continue
+		}
+		if response.Rcode != dns.RcodeSuccess {
+			err = fmt.Errorf("NS query for %s failed: %s: %s", zone, resolver, emptyResultReason(response.Rcode))
+
+			continue
+		}
+
+		break
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, answer := range response.Answer {
+		if ns, ok := answer.(*dns.NS); ok && !slices.Contains(names, dns.CanonicalName(ns.Ns)) {
+			names = append(names, dns.CanonicalName(ns.Ns))
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no NS records found for %s", strings.TrimSuffix(zone, "."))
+	}
+	slices.Sort(names)
+
+	glue = map[string][]string{}
+	for _, extra := range response.Extra {
+		name := dns.CanonicalName(extra.Header().Name)
+		switch record := extra.(type) {
+		case *dns.A:
+			glue[name] = append(glue[name], record.A.String())
+		case *dns.AAAA:
+			glue[name] = append(glue[name], record.AAAA.String())
+		}
+	}
+
+	return names, glue, nil
+}
+
+// lookupAddresses resolves the A and AAAA records of a nameserver
+func (opts *dnsOpts) lookupAddresses(ctx context.Context, client *dns.Client, resolvers []string, name string) []string {
+	addresses := []string{}
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		for _, resolver := range resolvers {
+			message := new(dns.Msg)
+			message.SetQuestion(name, qtype)
+			message.RecursionDesired = !opts.Norec
+
+			response, _, err := client.ExchangeContext(ctx, message, resolver)
+			if err != nil || response.Rcode != dns.RcodeSuccess {
+				continue
+			}
+
+			for _, answer := range response.Answer {
+				switch record := answer.(type) {
+				case *dns.A:
+					addresses = append(addresses, record.A.String())
+				case *dns.AAAA:
+					addresses = append(addresses, record.AAAA.String())
+				}
+			}
+
+			break
+		}
+	}
+
+	return addresses
+}
+
+// querySOASerial asks the nameserver directly for the SOA record, the answer must be authoritative
+func querySOASerial(ctx context.Context, client *dns.Client, zone, address string) (uint32, error) {
+	message := new(dns.Msg)
+	message.SetQuestion(zone, dns.TypeSOA)
+	message.RecursionDesired = false
+
+	response, _, err := client.ExchangeContext(ctx, message, address)
+	if err != nil {
+		return 0, fmt.Errorf("%s", queryFailedReason(err))
+	}
+	if response.Rcode != dns.RcodeSuccess {
+		return 0, fmt.Errorf("%s", emptyResultReason(response.Rcode))
+	}
+	if !response.Authoritative {
+		return 0, fmt.Errorf("answer is not authoritative")
+	}
+
+	for _, answer := range response.Answer {
+		if soa, ok := answer.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no SOA record returned")
+}
+
+func thresholdString(threshold *int) string {
+	if threshold == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*threshold)
+}
@@ -0,0 +1,237 @@
+package check_dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	transportUDP = "udp"
+	transportTCP = "tcp"
+	transportDoT = "dot"
+	transportDoH = "doh"
+
+	// maximum size of a dns message
+	maxDNSMessageSize = 65535
+
+	dohContentType = "application/dns-message"
+)
+
+// defaultTransportPorts contains the default port for each transport
+var defaultTransportPorts = map[string]int{
+	transportUDP: 53,
+	transportTCP: 53,
+	transportDoT: 853,
+	transportDoH: 443,
+}
+
+// transportNames contains the display names of the encrypted transports
+var transportNames = map[string]string{
+	transportDoT: "DoT",
+	transportDoH: "DoH",
+}
+
+// dnsExchanger sends a dns query and returns the answer
+type dnsExchanger interface {
+	ExchangeContext(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error)
+}
+
+// dnsTransport sends queries using udp, tcp, dns-over-tls (RFC 7858) or dns-over-https (RFC 8484)
+type dnsTransport struct {
+	opts       *dnsOpts
+	client     *dns.Client
+	httpClient *http.Client
+
+	// tls handshake duration and protocol version of the last encrypted connection
+	tlsHandshake time.Duration
+	tlsVersion   uint16
+}
+
+func (opts *dnsOpts) newTransport() *dnsTransport {
+	timeout := time.Duration(opts.QueryTimeout) * time.Second
+	transport := &dnsTransport{
+		opts:   opts,
+		client: &dns.Client{Net: opts.Transport, Timeout: timeout},
+	}
+
+	if opts.Transport == transportDoH {
+		transport.httpClient = &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				TLSClientConfig:   opts.tlsConfig(""),
+				ForceAttemptHTTP2: true,
+			},
+		}
+	}
+
+	return transport
+}
+
+// nameserverAddress returns the address used to query the nameserver with the configured transport
+func (opts *dnsOpts) nameserverAddress(nameserver string) string {
+	if opts.Transport == transportDoH {
+		if strings.HasPrefix(nameserver, "https://") {
+			return nameserver
+		}
+
+		return "https://" + net.JoinHostPort(nameserver, strconv.Itoa(opts.Port)) + "/dns-query"
+	}
+
+	return net.JoinHostPort(nameserver, strconv.Itoa(opts.Port))
+}
+
+func (opts *dnsOpts) tlsConfig(serverName string) *tls.Config {
+	if opts.TLSServerName != "" {
+		serverName = opts.TLSServerName
+	}
+
+	return &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: opts.Insecure, //nolint:gosec // only if requested by --insecure
+		MinVersion:         tls.VersionTLS12,
+	}
+}
+
+// ExchangeContext sends the query to the nameserver address using the configured transport
+func (t *dnsTransport) ExchangeContext(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	switch t.opts.Transport {
+	case transportDoT:
+		return t.exchangeDoT(ctx, msg, address)
+	case transportDoH:
+		return t.exchangeDoH(ctx, msg, address)
+	default:
+		return t.client.ExchangeContext(ctx, msg, address)
+	}
+}
+
+// TLSVersion returns the negotiated tls version as number, ex.: 1.3
+func (t *dnsTransport) TLSVersion() string {
+	return strings.TrimPrefix(tls.VersionName(t.tlsVersion), "TLS ")
+}
+
+func (t *dnsTransport) exchangeDoT(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	started := time.Now()
+	deadline := started.Add(t.client.Timeout)
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid address %s: %w", address, err)
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	rawConn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, 0, fmt.Errorf("connect failed: %w", err)
+	}
+	defer rawConn.Close()
+	_ = rawConn.SetDeadline(deadline)
+
+	tlsConn := tls.Client(rawConn, t.opts.tlsConfig(host))
+	handshakeStarted := time.Now()
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, 0, fmt.Errorf("tls handshake failed: %w", err)
+	}
+	t.tlsHandshake = time.Since(handshakeStarted)
+	t.tlsVersion = tlsConn.ConnectionState().Version
+
+	conn := &dns.Conn{Conn: tlsConn}
+	if err = conn.WriteMsg(msg); err != nil {
+		return nil, 0, fmt.Errorf("sending query failed: %w", err)
+	}
+
+	response, err := conn.ReadMsg()
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading answer failed: %w", err)
+	}
+	if response.Id != msg.Id {
+		return nil, 0, dns.ErrId
+	}
+
+	return response, time.Since(started), nil
+}
+
+func (t *dnsTransport) exchangeDoH(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	started := time.Now()
+
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot pack query: %w", err)
+	}
+
+	var req *http.Request
+	if strings.EqualFold(t.opts.DoHMethod, http.MethodGet) {
+		dohURL, parseErr := url.Parse(address)
+		if parseErr != nil {
+			return nil, 0, fmt.Errorf("invalid url %s: %w", address, parseErr)
+		}
+		query := dohURL.Query()
+		query.Set("dns", base64.RawURLEncoding.EncodeToString(packed))
+		dohURL.RawQuery = query.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, dohURL.String(), http.NoBody)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(packed))
+		if err == nil {
+			req.Header.Set("Content-Type", dohContentType)
+		}
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid url %s: %w", address, err)
+	}
+	req.Header.Set("Accept", dohContentType)
+
+	var handshakeStarted time.Time
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeStart: func() { handshakeStarted = time.Now() },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				t.tlsHandshake = time.Since(handshakeStarted)
+				t.tlsVersion = state.Version
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	res, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("request failed: %s", res.Status)
+	}
+	if contentType := res.Header.Get("Content-Type"); !strings.HasPrefix(contentType, dohContentType) {
+		return nil, 0, fmt.Errorf("unexpected content type: %s", contentType)
+	}
+	if res.TLS != nil {
+		t.tlsVersion = res.TLS.Version
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxDNSMessageSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading answer failed: %w", err)
+	}
+
+	response := new(dns.Msg)
+	if err = response.Unpack(body); err != nil {
+		return nil, 0, fmt.Errorf("cannot unpack answer: %w", err)
+	}
+	if response.Id != msg.Id {
+		return nil, 0, dns.ErrId
+	}
+
+	return response, time.Since(started), nil
+}
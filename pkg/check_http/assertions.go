@@ -0,0 +1,484 @@
+package check_http
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	reAssertionLabel   = regexp.MustCompile(`^([A-Za-z_][\w.-]*)=([^=].*)$`)
+	reAssertionNonWord = regexp.MustCompile(`\W+`)
+)
+
+// assertionOperators are tried in this order, so the two character operators come first
+var assertionOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// bodyAssertion is a single --jsonpath or --xpath expression with an optional comparison and perfdata thresholds
+type bodyAssertion struct {
+	warning        *thresholdRange
+	critical       *thresholdRange
+	kind           string // jsonpath or xpath
+	expr           string
+	label          string
+	path           string
+	operator       string
+	expected       string
+	jsonSteps      []jsonPathStep
+	xpathSteps     []xpathStep
+	count          bool
+	expectedString bool
+}
+
+// thresholdRange is a nagios plugin range like 10, 10:, ~:10, 10:20 or @10:20
+type thresholdRange struct {
+	raw    string
+	start  float64
+	end    float64
+	inside bool
+}
+
+// parseBodyAssertion parses expressions like: [label=]<path> [<op> <value>][;<warn>[;<crit>]]
+// Path can be wrapped in count(...) to compare the number of matches.
+func parseBodyAssertion(kind, raw string) (*bodyAssertion, error) {
+	parts := splitOutsideQuotes(raw, ';')
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("too many thresholds in %s: %s", kind, raw)
+	}
+
+	assertion := &bodyAssertion{kind: kind, expr: strings.TrimSpace(parts[0])}
+	expr := assertion.expr
+	if matches := reAssertionLabel.FindStringSubmatch(expr); len(matches) > 0 {
+		assertion.label = matches[1]
+		expr = strings.TrimSpace(matches[2])
+		assertion.expr = expr
+	}
+
+	path := expr
+	if pos, operator := findAssertionOperator(expr); pos != -1 {
+		assertion.operator = operator
+		path = strings.TrimSpace(expr[:pos])
+		assertion.expected = strings.TrimSpace(expr[pos+len(operator):])
+		if len(assertion.expected) >= 2 && (assertion.expected[0] == '"' || assertion.expected[0] == '\'') &&
+			assertion.expected[len(assertion.expected)-1] == assertion.expected[0] {
+			assertion.expected = assertion.expected[1 : len(assertion.expected)-1]
+			assertion.expectedString = true
+		}
+		if assertion.expected == "" {
+			return nil, fmt.Errorf("missing value after %s in %s: %s", operator, kind, raw)
+		}
+	}
+
+	if inner, ok := strings.CutPrefix(path, "count("); ok && strings.HasSuffix(inner, ")") {
+		assertion.count = true
+		path = strings.TrimSpace(strings.TrimSuffix(inner, ")"))
+	}
+	assertion.path = path
+
+	var err error
+	switch kind {
+	case "jsonpath":
+		assertion.jsonSteps, err = parseJSONPath(path)
+	case "xpath":
+		assertion.xpathSteps, err = parseXPath(path)
+	default:
+		err = fmt.Errorf("unknown assertion type: %s", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if assertion.label == "" {
+		assertion.label = strings.Trim(reAssertionNonWord.ReplaceAllString(path, "_"), "_")
+		if assertion.count {
+			assertion.label = "count_" + assertion.label
+		}
+	}
+
+	if len(parts) > 1 && parts[1] != "" {
+		if assertion.warning, err = parseThresholdRange(parts[1]); err != nil {
+			return nil, err
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if assertion.critical, err = parseThresholdRange(parts[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	return assertion, nil
+}
+
+// splitOutsideQuotes splits str by sep, separators within quotes are ignored
+func splitOutsideQuotes(str string, sep byte) []string {
+	parts := []string{}
+	var quote byte
+	last := 0
+	for i := range len(str) {
+		switch {
+		case quote != 0:
+			if str[i] == quote {
+				quote = 0
+			}
+		case str[i] == '\'' || str[i] == '"':
+			quote = str[i]
+		case str[i] == sep:
+			parts = append(parts, str[last:i])
+			last = i + 1
+		}
+	}
+
+	return append(parts, str[last:])
+}
+
+// findAssertionOperator returns the position of the first comparison operator outside of quotes, brackets and parentheses
+func findAssertionOperator(expr string) (pos int, operator string) {
+	var quote byte
+	depth := 0
+	for i := range len(expr) {
+		switch {
+		case quote != 0:
+			if expr[i] == quote {
+				quote = 0
+			}
+		case expr[i] == '\'' || expr[i] == '"':
+			quote = expr[i]
+		case expr[i] == '[' || expr[i] == '(':
+			depth++
+		case expr[i] == ']' || expr[i] == ')':
+			depth--
+		case depth == 0:
+			for _, op := range assertionOperators {
+				if strings.HasPrefix(expr[i:], op) {
+					return i, op
+				}
+			}
+		}
+	}
+
+	return -1, ""
+}
+
+func parseThresholdRange(str string) (*thresholdRange, error) {
+	rng := &thresholdRange{raw: str, start: 0, end: math.Inf(1)}
+	spec := str
+	if rest, ok := strings.CutPrefix(spec, "@"); ok {
+		rng.inside = true
+		spec = rest
+	}
+
+	start, end, hasColon := strings.Cut(spec, ":")
+	if !hasColon {
+		end = start
+		start = "0"
+	}
+
+	var err error
+	switch start {
+	case "~":
+		rng.start = math.Inf(-1)
+	case "":
+	default:
+		if rng.start, err = strconv.ParseFloat(start, 64); err != nil {
+			return nil, fmt.Errorf("invalid threshold range: %s", str)
+		}
+	}
+
+	if end != "" {
+		if rng.end, err = strconv.ParseFloat(end, 64); err != nil {
+			return nil, fmt.Errorf("invalid threshold range: %s", str)
+		}
+	}
+
+	if rng.start > rng.end {
+		return nil, fmt.Errorf("invalid threshold range, start is greater than end: %s", str)
+	}
+
+	return rng, nil
+}
+
+// violated returns true if the value should raise an alert
+func (r *thresholdRange) violated(value float64) bool {
+	inRange := value >= r.start && value <= r.end
+	if r.inside {
+		return inRange
+	}
+
+	return !inRange
+}
+
+// evaluate runs the query on the parsed document and returns the matched values
+func (a *bodyAssertion) evaluate(doc any) ([]any, error) {
+	var values []any
+	switch a.kind {
+	case "jsonpath":
+		values = jsonPathQuery(doc, a.jsonSteps)
+	case "xpath":
+		xmlDoc, ok := doc.(*xmlNode)
+		if !ok {
+			return nil, fmt.Errorf("response body is not a xml document")
+		}
+		var err error
+		values, err = xpathQuery(xmlDoc, a.xpathSteps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if a.count {
+		// count($.items) returns the length of the array instead of the number of matches,
+		// wildcard and recursive steps always count the matches, ex.: count($.items[*])
+		if len(values) == 1 && a.singleJSONPath() {
+			if list, ok := values[0].([]any); ok {
+				return []any{len(list)}, nil
+			}
+		}
+
+		return []any{len(values)}, nil
+	}
+
+	return values, nil
+}
+
+// singleJSONPath returns true if the jsonpath selects a single value and not a list of matches
+func (a *bodyAssertion) singleJSONPath() bool {
+	if a.kind != "jsonpath" {
+		return false
+	}
+	if len(a.jsonSteps) == 0 {
+		return true
+	}
+	last := a.jsonSteps[len(a.jsonSteps)-1]
+
+	return !last.recursive && last.name != "*"
+}
+
+// compare returns true if the value matches the expected value using the assertion operator
+func (a *bodyAssertion) compare(value any) bool {
+	if num, ok := assertionNumber(value); ok && !a.expectedString {
+		if expected, err := strconv.ParseFloat(a.expected, 64); err == nil {
+			switch a.operator {
+			case "==":
+				return num == expected
+			case "!=":
+				return num != expected
+			case ">=":
+				return num >= expected
+			case "<=":
+				return num <= expected
+			case ">":
+				return num > expected
+			case "<":
+				return num < expected
+			}
+		}
+	}
+
+	cmp := strings.Compare(formatAssertionValue(value), a.expected)
+	switch a.operator {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	}
+
+	return false
+}
+
+func assertionNumber(value any) (float64, bool) {
+	switch val := value.(type) {
+	case json.Number:
+		num, err := val.Float64()
+
+		return num, err == nil
+	case int:
+		return float64(val), true
+	case string:
+		num, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+
+		return num, err == nil
+	}
+
+	return 0, false
+}
+
+func formatAssertionValue(value any) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool, int:
+		return fmt.Sprintf("%v", val)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+
+		return string(data)
+	}
+}
+
+// subcheckBodyAssertions runs all --jsonpath and --xpath expressions against the response body.
+// Numeric results are added as perfdata and checked against their thresholds.
+//
+//nolint:funlen // the result handling is easier to follow in one place
+func subcheckBodyAssertions(meta *RequestMetadata, opts *commandOpts) (matches []string, err *CheckResult) {
+	if len(opts.bodyAssertions) == 0 {
+		return matches, nil
+	}
+
+	opts.tracef("subcheck: body assertions")
+
+	statusLine := getStatusLine(meta)
+	docs := map[string]any{}
+	for _, assertion := range opts.bodyAssertions {
+		if _, ok := docs[assertion.kind]; ok {
+			continue
+		}
+
+		switch assertion.kind {
+		case "jsonpath":
+			var data any
+			decoder := json.NewDecoder(strings.NewReader(meta.body))
+			decoder.UseNumber()
+			if decodeErr := decoder.Decode(&data); decodeErr != nil {
+				return matches, &CheckResult{
+					nil,
+					fmt.Sprintf("HTTP CRITICAL - %s - response body is not valid json: %s", statusLine, decodeErr.Error()),
+					CRITICAL,
+				}
+			}
+			docs[assertion.kind] = data
+		case "xpath":
+			doc, parseErr := parseXMLTree(meta.buffer.Bytes())
+			if parseErr != nil {
+				return matches, &CheckResult{
+					nil,
+					fmt.Sprintf("HTTP CRITICAL - %s - response body is not valid xml: %s", statusLine, parseErr.Error()),
+					CRITICAL,
+				}
+			}
+			docs[assertion.kind] = doc
+		}
+	}
+
+	for _, assertion := range opts.bodyAssertions {
+		values, evalErr := assertion.evaluate(docs[assertion.kind])
+		if evalErr != nil {
+			if err == nil || err.code < UNKNOWN {
+				err = &CheckResult{
+					nil,
+					fmt.Sprintf("HTTP UNKNOWN - %s - %s '%s' failed: %s", statusLine, assertion.kind, assertion.expr, evalErr.Error()),
+					UNKNOWN,
+				}
+			}
+
+			continue
+		}
+
+		formatted := make([]string, 0, len(values))
+		for _, val := range values {
+			formatted = append(formatted, formatAssertionValue(val))
+		}
+
+		if len(values) == 1 {
+			if num, ok := assertionNumber(values[0]); ok {
+				meta.perfdata = append(meta.perfdata, assertion.perfdata(num))
+				state, msg := assertion.checkThresholds(num)
+				if state > OK && (err == nil || err.code < state) {
+					err = &CheckResult{
+						nil,
+						fmt.Sprintf("HTTP %s - %s - %s", stateName(state), statusLine, msg),
+						state,
+					}
+				}
+			}
+		}
+
+		passed := len(values) > 0
+		if assertion.operator != "" {
+			for _, val := range values {
+				if !assertion.compare(val) {
+					passed = false
+
+					break
+				}
+			}
+		}
+
+		if !passed {
+			got := "no match"
+			if len(values) > 0 {
+				got = "got '" + strings.Join(formatted, ", ") + "'"
+			}
+			if err == nil || err.code < CRITICAL {
+				err = &CheckResult{
+					nil,
+					fmt.Sprintf("HTTP CRITICAL - %s - %s '%s' failed: %s", statusLine, assertion.kind, assertion.expr, got),
+					CRITICAL,
+				}
+			}
+
+			continue
+		}
+
+		matches = append(matches, fmt.Sprintf("%s: '%s' , value: '%s'", assertion.kind, assertion.expr, strings.Join(formatted, ", ")))
+	}
+
+	return matches, err
+}
+
+func (a *bodyAssertion) perfdata(value float64) string {
+	warn := ""
+	if a.warning != nil {
+		warn = a.warning.raw
+	}
+
+	crit := ""
+	if a.critical != nil {
+		crit = a.critical.raw
+	}
+
+	return fmt.Sprintf("%s=%s;%s;%s;;", a.label, strconv.FormatFloat(value, 'f', -1, 64), warn, crit)
+}
+
+func (a *bodyAssertion) checkThresholds(value float64) (state int, msg string) {
+	valueStr := strconv.FormatFloat(value, 'f', -1, 64)
+	if a.critical != nil && a.critical.violated(value) {
+		return CRITICAL, fmt.Sprintf("%s '%s' value %s is outside critical threshold %s", a.kind, a.expr, valueStr, a.critical.raw)
+	}
+
+	if a.warning != nil && a.warning.violated(value) {
+		return WARNING, fmt.Sprintf("%s '%s' value %s is outside warning threshold %s", a.kind, a.expr, valueStr, a.warning.raw)
+	}
+
+	return OK, ""
+}
+
+func stateName(state int) string {
+	switch state {
+	case OK:
+		return "OK"
+	case WARNING:
+		return "WARNING"
+	case CRITICAL:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,226 @@
+package check_http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	// tokens are refreshed a bit before they expire
+	oauth2TokenExpiryMargin = 30 * time.Second
+	// used if the token endpoint does not return expires_in
+	oauth2DefaultTokenLifetime = 5 * time.Minute
+)
+
+// oauth2TokenCache contains access tokens by token url, client id and scope
+var oauth2TokenCache = struct {
+	tokens map[string]*oauth2Token
+	// per key locks, so concurrent checks of the same client share a single token request
+	fetching map[string]*sync.Mutex
+	mutex    sync.Mutex
+}{tokens: map[string]*oauth2Token{}, fetching: map[string]*sync.Mutex{}}
+
+type oauth2Token struct {
+	expires     time.Time
+	accessToken string
+}
+
+// readSecret returns the secret from the command line or the first line of the secret file
+func readSecret(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file: %s", err.Error())
+	}
+
+	secret, _, _ := strings.Cut(string(data), "\n")
+
+	return strings.TrimRight(secret, "\r"), nil
+}
+
+// loadClientCertificate reads a client certificate from a pem file (with an optional separate key file) or a pkcs#12 file
+func loadClientCertificate(certFile, keyFile, password string) (*tls.Certificate, error) {
+	certData, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read client certificate: %s", err.Error())
+	}
+
+	ext := strings.ToLower(filepath.Ext(certFile))
+	if ext == ".p12" || ext == ".pfx" || !bytes.Contains(certData, []byte("-----BEGIN")) {
+		return loadPKCS12Certificate(certData, password)
+	}
+
+	keyData := certData
+	if keyFile != "" {
+		keyData, err = os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read client key: %s", err.Error())
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load client certificate: %s", err.Error())
+	}
+
+	return &cert, nil
+}
+
+// loadPKCS12Certificate converts the pkcs#12 data into a certificate with its chain.
+func loadPKCS12Certificate(data []byte, password string) (*tls.Certificate, error) {
+	key, leafCert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode pkcs#12 client certificate: %s", err.Error())
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs#12 client certificate contains no usable private key: %s", err.Error())
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	certs := [][]byte{}
+	for _, cert := range append([]*x509.Certificate{leafCert}, caCerts...) {
+		certs = append(certs, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	}
+
+	// the leaf certificate is the one matching the private key, all others are added as chain
+	for i, leaf := range certs {
+		cert, err := tls.X509KeyPair(leaf, keyPEM)
+		if err != nil {
+			continue
+		}
+
+		for j, chain := range certs {
+			if j == i {
+				continue
+			}
+			if block, _ := pem.Decode(chain); block != nil {
+				cert.Certificate = append(cert.Certificate, block.Bytes)
+			}
+		}
+
+		return &cert, nil
+	}
+
+	return nil, fmt.Errorf("pkcs#12 client certificate contains no certificate matching the private key")
+}
+
+func oauth2CacheKey(opts *commandOpts) string {
+	hash := sha256.Sum256([]byte(opts.oauth2ClientSecret))
+
+	return strings.Join([]string{opts.flags.OAuth2TokenURL, opts.flags.OAuth2ClientID, strings.Join(opts.flags.OAuth2Scope, " "), hex.EncodeToString(hash[:8])}, "\x00")
+}
+
+// fetchOAuth2Token returns a cached access token or requests a new one using the client credentials grant
+func fetchOAuth2Token(ctx context.Context, opts *commandOpts, client *http.Client) (string, error) {
+	cacheKey := oauth2CacheKey(opts)
+
+	// only the per key lock is held during the token request, checks using other clients are not blocked
+	oauth2TokenCache.mutex.Lock()
+	fetchLock, ok := oauth2TokenCache.fetching[cacheKey]
+	if !ok {
+		fetchLock = &sync.Mutex{}
+		oauth2TokenCache.fetching[cacheKey] = fetchLock
+	}
+	oauth2TokenCache.mutex.Unlock()
+
+	fetchLock.Lock()
+	defer fetchLock.Unlock()
+
+	oauth2TokenCache.mutex.Lock()
+	token, ok := oauth2TokenCache.tokens[cacheKey]
+	oauth2TokenCache.mutex.Unlock()
+	if ok && time.Now().Before(token.expires) {
+		opts.tracef("using cached oauth2 token, valid until %s", token.expires.Format(time.RFC3339))
+
+		return token.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(opts.flags.OAuth2Scope) > 0 {
+		form.Set("scope", strings.Join(opts.flags.OAuth2Scope, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.flags.OAuth2TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("invalid token url: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", opts.flags.UserAgent)
+	req.SetBasicAuth(url.QueryEscape(opts.flags.OAuth2ClientID), url.QueryEscape(opts.oauth2ClientSecret))
+
+	opts.debugf("requesting oauth2 token from %s", opts.flags.OAuth2TokenURL)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBufferSizeLimit))
+	if err != nil {
+		return "", fmt.Errorf("reading token response failed: %s", err.Error())
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return "", fmt.Errorf("cannot parse token response: %s", err.Error())
+	}
+
+	if tokenResponse.AccessToken == "" {
+		return "", fmt.Errorf("token response contains no access_token")
+	}
+
+	lifetime := oauth2DefaultTokenLifetime
+	if tokenResponse.ExpiresIn > 0 {
+		lifetime = time.Duration(tokenResponse.ExpiresIn) * time.Second
+	}
+
+	oauth2TokenCache.mutex.Lock()
+	oauth2TokenCache.tokens[cacheKey] = &oauth2Token{
+		accessToken: tokenResponse.AccessToken,
+		expires:     time.Now().Add(lifetime - oauth2TokenExpiryMargin),
+	}
+	oauth2TokenCache.mutex.Unlock()
+
+	return tokenResponse.AccessToken, nil
+}
+
+// invalidateOAuth2Token removes the cached token, ex.: if it has been rejected
+func invalidateOAuth2Token(opts *commandOpts) {
+	oauth2TokenCache.mutex.Lock()
+	defer oauth2TokenCache.mutex.Unlock()
+
+	delete(oauth2TokenCache.tokens, oauth2CacheKey(opts))
+}
@@ -5,12 +5,15 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
 )
 
 // Issues that are more important to display have lower importance numbers.
@@ -74,6 +77,88 @@ func checkCertificate(ctx context.Context, opts *commandOpts, dialFunc func(ctx
 	return checkCertificateChain(opts, certs)
 }
 
+// CertificateCheckOptions contains the certificate check settings for other checks, ex.: check_tcp --starttls.
+type CertificateCheckOptions struct {
+	CritDays                 *int
+	Hostname                 string
+	WarnDays                 int
+	IgnoreCertificateChain   bool
+	CheckCN                  bool
+	CheckSAN                 bool
+	IgnoreNotAfter           bool
+	IgnoreNotBefore          bool
+	IgnoreSignatureAlgorithm bool
+	Verbose                  bool
+}
+
+// ParseCertificateDays parses the warn_days[,<crit_days>] threshold of the certificate check.
+func ParseCertificateDays(str string) (warnDays int, critDays *int, err error) {
+	splits := strings.SplitN(str, ",", 2)
+
+	parseDays := func(str string) (int, error) {
+		if str == "" {
+			return 0, nil
+		}
+
+		parsedInt, parseErr := convert.Int64E(str)
+		if parseErr != nil {
+			return 0, fmt.Errorf("int parse error: %w", parseErr)
+		}
+
+		if parsedInt < 0 {
+			return 0, errors.New("days remaining cannot be a negative value")
+		}
+
+		return int(parsedInt), nil
+	}
+
+	warnDays, err = parseDays(splits[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("certificate check warning days could not be parsed: %s", err.Error())
+	}
+
+	if len(splits) == 2 {
+		crit, parseErr := parseDays(splits[1])
+		if parseErr != nil {
+			return 0, nil, fmt.Errorf("certificate check critical days could not be parsed: %s", parseErr.Error())
+		}
+
+		if crit > warnDays {
+			return 0, nil, errors.New("certificate expiration date check: critical days cannot be higher than warning days")
+		}
+
+		critDays = &crit
+	}
+
+	return warnDays, critDays, nil
+}
+
+// CheckCertificateChain validates the certificate chain like check_http -C does.
+// It returns the exit code and the message including perfdata, but without the leading "HTTP <STATE> - ".
+func CheckCertificateChain(certOpts *CertificateCheckOptions, certs []*x509.Certificate) (code int, msg string) {
+	opts := &commandOpts{
+		certificateWarnDays: certOpts.WarnDays,
+		certificateCritDays: certOpts.CritDays,
+	}
+	opts.flags.Hostname = certOpts.Hostname
+	opts.flags.IgnoreCertificateChain = certOpts.IgnoreCertificateChain
+	opts.flags.CheckCN = certOpts.CheckCN
+	opts.flags.CheckSAN = certOpts.CheckSAN
+	opts.flags.IgnoreNotAfter = certOpts.IgnoreNotAfter
+	opts.flags.IgnoreNotBefore = certOpts.IgnoreNotBefore
+	opts.flags.IgnoreSignatureAlgorithm = certOpts.IgnoreSignatureAlgorithm
+	opts.flags.Verbose = certOpts.Verbose
+
+	if len(certs) == 0 {
+		return CRITICAL, "No certificate returned"
+	}
+
+	result := checkCertificateChain(opts, certs)
+	msg = strings.TrimPrefix(result.msg, fmt.Sprintf("HTTP %s - ", stateName(result.code)))
+
+	return result.code, msg
+}
+
 // The main inspiration is from https://github.com/matteocorti/check_ssl_cert.
 // That project has many options, this function implements only a subset of them.
 //
@@ -20,7 +20,6 @@ import (
 	"unicode"
 	"unicode/utf8"
 
-	"github.com/consol-monitoring/snclient/pkg/convert"
 	"github.com/dustin/go-humanize"
 	"github.com/kdar/factorlog"
 	"github.com/sni/go-flags"
@@ -51,7 +50,15 @@ const (
 type commandOpts struct {
 	log                     *factorlog.FactorLog
 	certificateCritDays     *int
+	clientCertificate       *tls.Certificate         // parsed --client-cert
+	oauth2Client            *http.Client             // client used for the token requests
+	phaseWarning            map[string]time.Duration // parsed --warning-<phase> thresholds
+	phaseCritical           map[string]time.Duration // parsed --critical-<phase> thresholds
+	bodyAssertions          []*bodyAssertion
+	transactionSteps        []*transactionStep
 	Expect                  []string      // parsed version of ExpectStr
+	oauth2ClientSecret      string        // from --oauth2-client-secret or --oauth2-client-secret-file
+	oauth2AccessToken       string        // bearer token for the current request
 	TimeoutParsed           time.Duration // parsed version of the timeoutStr after possibly appending time unit seconds
 	warningThresholdParsed  time.Duration // parsed version of the warningThreshold after possibly appending time unit seconds
 	criticalThresholdParsed time.Duration // parsed version of the warningThreshold after possibly appending time unit seconds
@@ -69,7 +76,20 @@ type commandOpts struct {
 		Base64ExpectContent      string        `          long:"base64-string"                           description:"Base64 Encoded string to expect the content"`
 		UserAgent                string        `short:"A" long:"useragent"         default:"check_http"  description:"UserAgent to be sent"`
 		Authorization            string        `short:"a" long:"authorization"                           description:"Pass '[username]:[password]' formatted string to be used as basic authorization header"`
+		ClientCert               string        `          long:"client-cert"                             description:"Client certificate for tls client authentication. Either a pem file, which may contain the private key as well, or a pkcs#12 file (.p12/.pfx)"`
+		ClientKey                string        `          long:"client-key"                              description:"Private key for the client certificate in pem format, if not contained in the certificate file"`
+		ClientCertPassword       string        `          long:"client-cert-password"                    description:"Password of the pkcs#12 client certificate"`
+		ClientCertPasswordFile   string        `          long:"client-cert-password-file"               description:"Read the password of the pkcs#12 client certificate from this file"`
+		OAuth2TokenURL           string        `          long:"oauth2-token-url"                        description:"Fetch a bearer token from this url using the oauth2 client credentials grant. Tokens are cached until they expire"`
+		OAuth2ClientID           string        `          long:"oauth2-client-id"                        description:"Client id for the oauth2 token request"`
+		OAuth2ClientSecret       string        `          long:"oauth2-client-secret"                    description:"Client secret for the oauth2 token request"`
+		OAuth2ClientSecretFile   string        `          long:"oauth2-client-secret-file"               description:"Read the oauth2 client secret from this file"`
+		OAuth2Scope              []string      `          long:"oauth2-scope"                            description:"Scope to request with the oauth2 token. Use multiple times for additional scopes"`
 		Header                   []string      `short:"k" long:"header"                                  description:"Any other tags to be sent in http header. Use multiple times for additional headers"`
+		JSONPath                 []string      `          long:"jsonpath"                                description:"JSONPath expression to check in the json response body, ex.: '$.status == UP' or 'count($.items) > 0'. Numeric values are added as perfdata, thresholds can be appended like '[label=]<expr>;<warn>;<crit>'. Use multiple times for additional expressions"`
+		Step                     []string      `          long:"step"                                    description:"Request to run before the main request, ex.: 'POST /login|body=user=${USER}|capture=token=jsonpath:$.token|expect=200'. Captured variables can be used as ${name} in later steps and the main request, they are url encoded in form bodies. Cookies persist across all steps. Use multiple times for additional steps"`
+		StepFile                 string        `          long:"step-file"                               description:"Read transaction steps from this yaml file, steps from the file run before steps from --step"`
+		XPath                    []string      `          long:"xpath"                                   description:"XPath expression to check in the xml response body, ex.: '/health/status == ok' or 'count(//error) == 0'. Same syntax as --jsonpath. Use multiple times for additional expressions"`
 		Certificate              string        `short:"C" long:"certificate"                             description:"Check certificates instead of content. Specified in mandatory days left to warn and optional days to crit with a comma: warn_days[,<crit_days>]" `
 		TLSMinVersion            string        `          long:"tls-min"                                 description:"Minimum supported TLS version. Values with plus set the max tls version as well to latest version: 1.3" choice:"1.0" choice:"1.0+" choice:"1.1" choice:"1.1+" choice:"1.2" choice:"1.2+" choice:"1.3"`
 		TLSMaxVersion            string        `          long:"tls-max"                                 description:"Maximum supported TLS version" choice:"1.0" choice:"1.1" choice:"1.2" choice:"1.3"`
@@ -81,6 +101,16 @@ type commandOpts struct {
 		TimeoutStr               string        `short:"t" long:"timeout"           default:"10"          description:"Timeout to wait for connection. If no time unit is given at the end, default of seconds is assumed"`
 		WarningThresholdStr      string        `short:"w" long:"warning"           default:"30"          description:"If the request+response takes longer specified warning threshold, raises a warning. If no time unit is given at the end, default of seconds is assumed. Value is truncated to milliseconds."`
 		CriticalThresholdStr     string        `short:"c" long:"critical"          default:"60"          description:"If the request+response takes longer specified critical threshold, raises a critical. If no time unit is given at the end, default of seconds is assumed. Value is truncated to milliseconds."`
+		WarningDNS               string        `          long:"warning-dns"                             description:"Warning threshold for the dns lookup duration. If no time unit is given at the end, default of seconds is assumed."`
+		CriticalDNS              string        `          long:"critical-dns"                            description:"Critical threshold for the dns lookup duration"`
+		WarningConnect           string        `          long:"warning-connect"                         description:"Warning threshold for the tcp connect duration"`
+		CriticalConnect          string        `          long:"critical-connect"                        description:"Critical threshold for the tcp connect duration"`
+		WarningTLS               string        `          long:"warning-tls"                             description:"Warning threshold for the tls handshake duration"`
+		CriticalTLS              string        `          long:"critical-tls"                            description:"Critical threshold for the tls handshake duration"`
+		WarningTTFB              string        `          long:"warning-ttfb"                            description:"Warning threshold for the time to first byte after the request has been sent"`
+		CriticalTTFB             string        `          long:"critical-ttfb"                           description:"Critical threshold for the time to first byte after the request has been sent"`
+		WarningTransfer          string        `          long:"warning-transfer"                        description:"Warning threshold for the content transfer duration"`
+		CriticalTransfer         string        `          long:"critical-transfer"                       description:"Critical threshold for the content transfer duration"`
 		WaitForInterval          time.Duration `          long:"wait-for-interval" default:"2s"          description:"Retry interval"`
 		WaitForMax               time.Duration `          long:"wait-for-max"                            description:"Time to wait for success (max.: 180s)"`
 		Interim                  time.Duration `          long:"interim"           default:"1s"          description:"Interval time after successful request for consecutive mode"`
@@ -151,9 +181,26 @@ func makeTLSConfig(opts *commandOpts) (conf *tls.Config) {
 		conf.MaxVersion = opts.tlsMaxVersion
 	}
 
+	if opts.clientCertificate != nil {
+		conf.Certificates = []tls.Certificate{*opts.clientCertificate}
+	}
+
 	return conf
 }
 
+// MakeTLSConfig returns the tls config used by check_http for other checks, ex.: check_tls_scan.
+// SNI is enabled if serverName is set, a zero version leaves the go default.
+func MakeTLSConfig(serverName string, minVersion, maxVersion uint16) *tls.Config {
+	opts := &commandOpts{
+		tlsMinVersion: minVersion,
+		tlsMaxVersion: maxVersion,
+	}
+	opts.flags.Hostname = serverName
+	opts.flags.SNI = serverName != ""
+
+	return makeTLSConfig(opts)
+}
+
 // net.Dialer is for creating a TCP connection.
 func makeDialer(opts *commandOpts) func(ctx context.Context, _ string, _ string) (net.Conn, error) {
 	baseDialFunc := (&net.Dialer{
@@ -315,6 +362,10 @@ func buildRequest(ctx context.Context, opts *commandOpts) (*http.Request, error)
 		req.SetBasicAuth(a[0], a[1])
 	}
 
+	if opts.oauth2AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.oauth2AccessToken)
+	}
+
 	req.Header.Set("User-Agent", opts.flags.UserAgent)
 
 	// set additional headers
@@ -328,12 +379,32 @@ func buildRequest(ctx context.Context, opts *commandOpts) (*http.Request, error)
 	return req, nil
 }
 
+// expandRequestVariables replaces variables captured by transaction steps in the uri and headers of the main request
+func expandRequestVariables(req *http.Request, opts *commandOpts, vars map[string]string) error {
+	target, err := req.URL.Parse(expandVariables(opts.flags.URI, vars))
+	if err != nil {
+		return fmt.Errorf("invalid uri: %w", err)
+	}
+	req.URL = target
+
+	for _, hdr := range opts.flags.Header {
+		parts := strings.SplitN(expandVariables(hdr, vars), ":", 2)
+		if len(parts) == 2 {
+			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+	}
+
+	return nil
+}
+
 type RequestMetadata struct {
 	req            *http.Request
 	res            *http.Response
 	buffer         *capWriter
 	redirectionErr *clientRedirectError
 	body           string
+	timings        *requestTimings
+	perfdata       []string // additional perfdata, ex.: from jsonpath expressions
 	duration       time.Duration
 }
 
@@ -380,6 +451,9 @@ func performHTTPRequest(req *http.Request, client *http.Client, opts *commandOpt
 		opts.tracef("request:\n%s", reqDump)
 	}
 
+	timings := newRequestTimings()
+	req = req.WithContext(timings.withClientTrace(req.Context()))
+
 	start := time.Now()
 	res, err := client.Do(req)
 	duration := time.Since(start).Truncate(time.Millisecond)
@@ -422,14 +496,21 @@ func performHTTPRequest(req *http.Request, client *http.Client, opts *commandOpt
 		body = string(buffer.Bytes())
 	}
 
+	timings.finishTransfer()
+
+	if res != nil {
+		opts.debugf("connection: %s", connectionDetails(res))
+	}
+
 	// the returned err might be of type clientRedirectError
 	return &RequestMetadata{
-		req,
-		res,
-		buffer,
-		redirectionErr,
-		body,
-		duration,
+		req:            req,
+		res:            res,
+		buffer:         buffer,
+		redirectionErr: redirectionErr,
+		body:           body,
+		timings:        timings,
+		duration:       duration,
 	}, nil
 }
 
@@ -721,13 +802,23 @@ func buildPerfdataString(opts *commandOpts, meta *RequestMetadata) string {
 		criticalThresholdStr = strconv.FormatFloat(opts.criticalThresholdParsed.Seconds(), 'f', 3, 64)
 	}
 
-	return fmt.Sprintf(
+	perfdata := fmt.Sprintf(
 		`time=%ss;%s;%s;0; size=%dB;;;0;`,
 		durationStr,
 		warnThresholdStr,
 		criticalThresholdStr,
 		meta.buffer.Size(),
 	)
+
+	if meta.timings != nil {
+		perfdata += " " + strings.Join(buildTimingPerfdata(opts, meta.timings), " ")
+	}
+
+	if len(meta.perfdata) > 0 {
+		perfdata += " " + strings.Join(meta.perfdata, " ")
+	}
+
+	return perfdata
 }
 
 // if this function does not return an error, the redirection can continue
@@ -767,7 +858,27 @@ func clientRedirectHandler(req *http.Request, via []*http.Request, opts *command
 //
 //nolint:funlen // splitting the function more would be worse
 func request(ctx context.Context, client *http.Client, opts *commandOpts) (okMsg string, result *CheckResult) {
+	if opts.flags.OAuth2TokenURL != "" {
+		token, tokenErr := fetchOAuth2Token(ctx, opts, opts.oauth2Client)
+		if tokenErr != nil {
+			return "", &CheckResult{
+				nil,
+				fmt.Sprintf("HTTP CRITICAL - oauth2: %s", tokenErr.Error()),
+				CRITICAL,
+			}
+		}
+		opts.oauth2AccessToken = token
+	}
+
+	vars, stepPerfdata, stepMatches, stepResult := runTransactionSteps(ctx, client, opts)
+	if stepResult != nil {
+		return "", stepResult
+	}
+
 	req, err := buildRequest(ctx, opts)
+	if err == nil && len(vars) > 0 {
+		err = expandRequestVariables(req, opts, vars)
+	}
 	if err != nil {
 		return "", &CheckResult{
 			nil,
@@ -825,6 +936,13 @@ func request(ctx context.Context, client *http.Client, opts *commandOpts) (okMsg
 
 	opts.tracef("request metadata: %v", meta)
 
+	// request a new token next time
+	if opts.oauth2AccessToken != "" && meta.res.StatusCode == http.StatusUnauthorized {
+		invalidateOAuth2Token(opts)
+	}
+
+	meta.perfdata = append(stepPerfdata, meta.perfdata...)
+
 	var reqErr *CheckResult
 
 	matches := []string{}
@@ -886,9 +1004,22 @@ func request(ctx context.Context, client *http.Client, opts *commandOpts) (okMsg
 
 	matches = append(matches, matchesRegexi...)
 
+	matchesBodyAssertions, reqErr := subcheckBodyAssertions(meta, opts)
+	if reqErr != nil {
+		reqErr.msg += " | " + buildPerfdataString(opts, meta)
+
+		return "", reqErr
+	}
+
+	matches = append(matches, matchesBodyAssertions...)
+
 	matchesOutputStr := ""
+	if len(stepMatches) > 0 {
+		matchesOutputStr = fmt.Sprintf("Transaction steps: [%s] - ", strings.Join(stepMatches, ", "))
+	}
+
 	if len(matches) > 0 {
-		matchesOutputStr = fmt.Sprintf("Response body matched: [%s] - ", strings.Join(matches, ", "))
+		matchesOutputStr += fmt.Sprintf("Response body matched: [%s] - ", strings.Join(matches, ", "))
 	}
 
 	// Page size check is not yet implemented
@@ -898,13 +1029,23 @@ func request(ctx context.Context, client *http.Client, opts *commandOpts) (okMsg
 		return "", reqErr
 	}
 
+	// phase thresholds are checked last, so a failed status code is not hidden by a slower warning phase
+	phaseErr := checkPhaseThresholds(meta, opts)
+
 	reqErr = handleErroneousHTTPReturnCodes(meta.res, opts, meta)
 	if reqErr != nil {
+		if phaseErr != nil && phaseErr.code > reqErr.code {
+			return "", phaseErr
+		}
 		reqErr.msg += " | " + buildPerfdataString(opts, meta)
 
 		return "", reqErr
 	}
 
+	if phaseErr != nil {
+		return "", phaseErr
+	}
+
 	statusLine := getStatusLine(meta)
 
 	_, err = meta.buffer.Write([]byte(statusLine + "\r\n\r\n"))
@@ -926,8 +1067,12 @@ func request(ctx context.Context, client *http.Client, opts *commandOpts) (okMsg
 	}
 
 	showBodyStr := ""
+	if opts.flags.Verbose {
+		showBodyStr = "\n" + connectionDetails(meta.res)
+	}
+
 	if opts.flags.ShowBody {
-		showBodyStr = "\n" + meta.body
+		showBodyStr += "\n" + meta.body
 	}
 
 	okMsg = fmt.Sprintf(
@@ -1036,6 +1181,56 @@ func Check(ctx context.Context, output io.Writer, osArgs []string) int {
 		opts.Expect = strings.Split(opts.flags.ExpectStr, ",")
 	}
 
+	for _, expr := range opts.flags.JSONPath {
+		assertion, parseErr := parseBodyAssertion("jsonpath", expr)
+		if parseErr != nil {
+			fmt.Fprintf(output, "Could not parse jsonpath: %s\n", parseErr.Error())
+
+			return UNKNOWN
+		}
+
+		opts.bodyAssertions = append(opts.bodyAssertions, assertion)
+	}
+
+	for _, expr := range opts.flags.XPath {
+		assertion, parseErr := parseBodyAssertion("xpath", expr)
+		if parseErr != nil {
+			fmt.Fprintf(output, "Could not parse xpath: %s\n", parseErr.Error())
+
+			return UNKNOWN
+		}
+
+		opts.bodyAssertions = append(opts.bodyAssertions, assertion)
+	}
+
+	if opts.flags.StepFile != "" {
+		steps, stepErr := readTransactionFile(opts.flags.StepFile)
+		if stepErr != nil {
+			fmt.Fprintf(output, "%s\n", stepErr.Error())
+
+			return UNKNOWN
+		}
+
+		opts.transactionSteps = steps
+	}
+
+	for _, spec := range opts.flags.Step {
+		step, stepErr := parseTransactionStep(spec)
+		if stepErr != nil {
+			fmt.Fprintf(output, "Could not parse step: %s\n", stepErr.Error())
+
+			return UNKNOWN
+		}
+
+		opts.transactionSteps = append(opts.transactionSteps, step)
+	}
+
+	if err := validateTransactionSteps(opts.transactionSteps); err != nil {
+		fmt.Fprintf(output, "Invalid transaction step: %s\n", err.Error())
+
+		return UNKNOWN
+	}
+
 	if opts.flags.ExpectContent != "" && opts.flags.Base64ExpectContent != "" {
 		fmt.Fprintf(output, "Both string and base64-string are specified\n")
 
@@ -1160,6 +1355,12 @@ func Check(ctx context.Context, output io.Writer, osArgs []string) int {
 
 	opts.criticalThresholdParsed = opts.criticalThresholdParsed.Truncate(time.Millisecond)
 
+	if err := parsePhaseThresholds(&opts); err != nil {
+		fmt.Fprintf(output, "Error parsing phase threshold: %s\n", err.Error())
+
+		return UNKNOWN
+	}
+
 	switch opts.flags.TLSMinVersion {
 	// argument parser only accepts these values as valid
 	case "1.0":
@@ -1199,52 +1400,53 @@ func Check(ctx context.Context, output io.Writer, osArgs []string) int {
 		return UNKNOWN
 	}
 
-	//nolint:nestif // imported 3rd party source
 	if opts.flags.Certificate != "" {
-		splits := strings.SplitN(opts.flags.Certificate, ",", 2)
+		warnDays, critDays, parseErr := ParseCertificateDays(opts.flags.Certificate)
+		if parseErr != nil {
+			fmt.Fprintf(output, "%s\n", parseErr.Error())
 
-		parseDays := func(str string) (int, error) {
-			if str == "" {
-				return 0, nil
-			}
+			return UNKNOWN
+		}
 
-			parsedInt, parseErr := convert.Int64E(str)
-			if parseErr != nil {
-				return 0, fmt.Errorf("int parse error: %w", parseErr)
-			}
+		opts.certificateWarnDays = warnDays
+		opts.certificateCritDays = critDays
+	}
 
-			if parsedInt < 0 {
-				return 0, errors.New("days remaining cannot be a negative value")
-			}
+	if opts.flags.ClientCert != "" {
+		password, secretErr := readSecret(opts.flags.ClientCertPassword, opts.flags.ClientCertPasswordFile)
+		if secretErr != nil {
+			fmt.Fprintf(output, "Could not read client certificate password: %s\n", secretErr.Error())
 
-			return int(parsedInt), nil
+			return UNKNOWN
 		}
 
-		warnDays, parseWarnErr := parseDays(splits[0])
-		if parseWarnErr != nil {
-			fmt.Fprintf(output, "Certificate check warning days could not be parsed: %s.\n", parseWarnErr.Error())
+		cert, certErr := loadClientCertificate(opts.flags.ClientCert, opts.flags.ClientKey, password)
+		if certErr != nil {
+			fmt.Fprintf(output, "%s\n", certErr.Error())
 
 			return UNKNOWN
 		}
 
-		opts.certificateWarnDays = warnDays
+		opts.clientCertificate = cert
+	}
 
-		if len(splits) == 2 {
-			critDays, parseCritErr := parseDays(splits[1])
-			if parseCritErr != nil {
-				fmt.Fprintf(output, "Certificate check critical days could not be parsed: %s.\n", parseCritErr.Error())
+	if opts.flags.OAuth2TokenURL != "" {
+		if opts.flags.OAuth2ClientID == "" {
+			fmt.Fprintf(output, "oauth2-client-id is required when using oauth2-token-url\n")
 
-				return UNKNOWN
-			}
-
-			if critDays > warnDays {
-				fmt.Fprintf(output, "Certificate expiration date check: critical days cannot be higher than warning days.\n")
+			return UNKNOWN
+		}
 
-				return UNKNOWN
-			}
+		secret, secretErr := readSecret(opts.flags.OAuth2ClientSecret, opts.flags.OAuth2ClientSecretFile)
+		if secretErr != nil {
+			fmt.Fprintf(output, "Could not read oauth2 client secret: %s\n", secretErr.Error())
 
-			opts.certificateCritDays = &critDays
+			return UNKNOWN
 		}
+		opts.oauth2ClientSecret = secret
+
+		// the token endpoint is a different host, so it does not use the dialer and tls settings of the target
+		opts.oauth2Client = &http.Client{Timeout: opts.TimeoutParsed}
 	}
 
 	// Build shared TLS config and dialer
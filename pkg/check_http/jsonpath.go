@@ -0,0 +1,176 @@
+package check_http
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is a single step of a jsonpath expression, ex.: .name, ['name'], [0], [*] or ..name
+type jsonPathStep struct {
+	index     *int
+	name      string // "*" matches all children
+	recursive bool
+}
+
+// parseJSONPath parses a simple jsonpath expression like $.items[0].name, $..status or $['key'][*]
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("jsonpath must start with $: %s", path)
+	}
+
+	steps := []jsonPathStep{}
+	rest := path[1:]
+	for rest != "" {
+		step := jsonPathStep{}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+		case rest[0] == '.':
+			rest = rest[1:]
+		case rest[0] == '[':
+		default:
+			return nil, fmt.Errorf("unexpected character %q in jsonpath: %s", rest[0], path)
+		}
+
+		if strings.HasPrefix(rest, "[") {
+			var err error
+			rest, err = parseJSONPathBracket(rest, &step)
+			if err != nil {
+				return nil, fmt.Errorf("%s in jsonpath: %s", err.Error(), path)
+			}
+		} else {
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			step.name = rest[:end]
+			rest = rest[end:]
+			if step.name == "" {
+				return nil, fmt.Errorf("empty name in jsonpath: %s", path)
+			}
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// parseJSONPathBracket parses a bracket step like [0], [-1], [*] or ['name'] and returns the remaining expression
+func parseJSONPathBracket(rest string, step *jsonPathStep) (string, error) {
+	if len(rest) > 1 && (rest[1] == '\'' || rest[1] == '"') {
+		end := strings.IndexByte(rest[2:], rest[1])
+		if end == -1 || len(rest) < end+4 || rest[end+3] != ']' {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		step.name = rest[2 : end+2]
+
+		return rest[end+4:], nil
+	}
+
+	end := strings.IndexByte(rest, ']')
+	if end == -1 {
+		return "", fmt.Errorf("missing closing bracket")
+	}
+
+	content := strings.TrimSpace(rest[1:end])
+	if content == "*" {
+		step.name = "*"
+
+		return rest[end+1:], nil
+	}
+
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return "", fmt.Errorf("unsupported index %q", content)
+	}
+	step.index = &index
+
+	return rest[end+1:], nil
+}
+
+// jsonPathQuery returns all values matching the parsed jsonpath
+func jsonPathQuery(data any, steps []jsonPathStep) []any {
+	nodes := []any{data}
+	for i := range steps {
+		next := []any{}
+		for _, node := range nodes {
+			if steps[i].recursive {
+				for _, desc := range jsonDescendants(node) {
+					next = append(next, steps[i].match(desc)...)
+				}
+			} else {
+				next = append(next, steps[i].match(node)...)
+			}
+		}
+		nodes = next
+	}
+
+	return nodes
+}
+
+// match returns the direct children of node matching this step
+func (s *jsonPathStep) match(node any) []any {
+	switch val := node.(type) {
+	case map[string]any:
+		if s.index != nil {
+			return nil
+		}
+		if s.name == "*" {
+			keys := make([]string, 0, len(val))
+			for key := range val {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			res := make([]any, 0, len(keys))
+			for _, key := range keys {
+				res = append(res, val[key])
+			}
+
+			return res
+		}
+		if child, ok := val[s.name]; ok {
+			return []any{child}
+		}
+	case []any:
+		if s.name == "*" {
+			return val
+		}
+		if s.index != nil {
+			idx := *s.index
+			if idx < 0 {
+				idx += len(val)
+			}
+			if idx >= 0 && idx < len(val) {
+				return []any{val[idx]}
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonDescendants returns the node itself and all nested objects and arrays
+func jsonDescendants(node any) []any {
+	res := []any{node}
+	switch val := node.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			res = append(res, jsonDescendants(val[key])...)
+		}
+	case []any:
+		for _, child := range val {
+			res = append(res, jsonDescendants(child)...)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,235 @@
+package check_http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// timingPhases are the phases of a request in the order they happen
+var timingPhases = []string{"dns", "connect", "tls", "ttfb", "transfer"}
+
+var timingPhaseNames = map[string]string{
+	"dns":      "dns lookup",
+	"connect":  "tcp connect",
+	"tls":      "tls handshake",
+	"ttfb":     "time to first byte",
+	"transfer": "content transfer",
+}
+
+// requestTimings collects the duration of each request phase, redirects are summed up.
+// Phases of reused connections are zero.
+type requestTimings struct {
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	durations    map[string]time.Duration
+	mutex        sync.Mutex
+}
+
+func newRequestTimings() *requestTimings {
+	return &requestTimings{durations: map[string]time.Duration{}}
+}
+
+// withClientTrace returns a context which records the request phases
+func (t *requestTimings) withClientTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.start(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.done("dns", &t.dnsStart)
+		},
+		ConnectStart: func(_, _ string) {
+			t.start(&t.connectStart)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.done("connect", &t.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.start(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				t.done("tls", &t.tlsStart)
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.start(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			t.firstByte = time.Now()
+			if !t.wroteRequest.IsZero() {
+				t.durations["ttfb"] += t.firstByte.Sub(t.wroteRequest)
+				t.wroteRequest = time.Time{}
+			}
+		},
+	})
+}
+
+func (t *requestTimings) start(start *time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if start.IsZero() {
+		*start = time.Now()
+	}
+}
+
+func (t *requestTimings) done(phase string, start *time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !start.IsZero() {
+		t.durations[phase] += time.Since(*start)
+		*start = time.Time{}
+	}
+}
+
+// finishTransfer sets the transfer duration once the body has been read
+func (t *requestTimings) finishTransfer() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.firstByte.IsZero() {
+		t.durations["transfer"] = time.Since(t.firstByte)
+	}
+}
+
+func (t *requestTimings) get(phase string) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.durations[phase].Truncate(time.Microsecond)
+}
+
+// parsePhaseThreshold parses a duration, seconds are assumed if no unit is given
+func parsePhaseThreshold(str string) (time.Duration, error) {
+	if str == "" {
+		return 0, nil
+	}
+
+	lastRune, _ := utf8.DecodeLastRuneInString(str)
+	if unicode.IsDigit(lastRune) {
+		str += "s"
+	}
+
+	duration, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse duration %q: %s", str, err.Error())
+	}
+
+	return duration, nil
+}
+
+// parsePhaseThresholds fills the phase thresholds from the --warning-<phase> / --critical-<phase> flags
+func parsePhaseThresholds(opts *commandOpts) error {
+	opts.phaseWarning = map[string]time.Duration{}
+	opts.phaseCritical = map[string]time.Duration{}
+
+	flags := map[string][2]string{
+		"dns":      {opts.flags.WarningDNS, opts.flags.CriticalDNS},
+		"connect":  {opts.flags.WarningConnect, opts.flags.CriticalConnect},
+		"tls":      {opts.flags.WarningTLS, opts.flags.CriticalTLS},
+		"ttfb":     {opts.flags.WarningTTFB, opts.flags.CriticalTTFB},
+		"transfer": {opts.flags.WarningTransfer, opts.flags.CriticalTransfer},
+	}
+	for phase, thresholds := range flags {
+		warn, err := parsePhaseThreshold(thresholds[0])
+		if err != nil {
+			return fmt.Errorf("warning-%s: %s", phase, err.Error())
+		}
+		crit, err := parsePhaseThreshold(thresholds[1])
+		if err != nil {
+			return fmt.Errorf("critical-%s: %s", phase, err.Error())
+		}
+		opts.phaseWarning[phase] = warn
+		opts.phaseCritical[phase] = crit
+	}
+
+	return nil
+}
+
+// checkPhaseThresholds compares each request phase against its warning and critical threshold
+func checkPhaseThresholds(meta *RequestMetadata, opts *commandOpts) *CheckResult {
+	if meta.timings == nil {
+		return nil
+	}
+
+	statusLine := getStatusLine(meta)
+	for _, state := range []int{CRITICAL, WARNING} {
+		thresholds := opts.phaseCritical
+		if state == WARNING {
+			thresholds = opts.phaseWarning
+		}
+
+		for _, phase := range timingPhases {
+			threshold := thresholds[phase]
+			duration := meta.timings.get(phase)
+			if threshold == 0 || duration <= threshold {
+				continue
+			}
+
+			return &CheckResult{
+				nil,
+				fmt.Sprintf("HTTP %s - %s - %s took %.3fs (longer than the %s threshold %.3fs) | %s",
+					stateName(state), statusLine, timingPhaseNames[phase], duration.Seconds(),
+					strings.ToLower(stateName(state)), threshold.Seconds(), buildPerfdataString(opts, meta)),
+				state,
+			}
+		}
+	}
+
+	return nil
+}
+
+// buildTimingPerfdata returns the perfdata for all request phases
+func buildTimingPerfdata(opts *commandOpts, timings *requestTimings) []string {
+	formatThreshold := func(threshold time.Duration) string {
+		if threshold == 0 {
+			return ""
+		}
+
+		return strconv.FormatFloat(threshold.Seconds(), 'f', 3, 64)
+	}
+
+	perfdata := make([]string, 0, len(timingPhases))
+	for _, phase := range timingPhases {
+		perfdata = append(perfdata, fmt.Sprintf("time_%s=%ss;%s;%s;0;",
+			phase,
+			strconv.FormatFloat(timings.get(phase).Seconds(), 'f', 3, 64),
+			formatThreshold(opts.phaseWarning[phase]),
+			formatThreshold(opts.phaseCritical[phase]),
+		))
+	}
+
+	return perfdata
+}
+
+// connectionDetails returns the negotiated protocol, tls version and cipher of the response
+func connectionDetails(res *http.Response) string {
+	details := "protocol: " + res.Proto
+	if res.TLS != nil {
+		details += fmt.Sprintf(", tls version: %s, cipher: %s",
+			tls.VersionName(res.TLS.Version), tls.CipherSuiteName(res.TLS.CipherSuite))
+		if res.TLS.NegotiatedProtocol != "" {
+			details += ", alpn: " + res.TLS.NegotiatedProtocol
+		}
+	}
+
+	return details
+}
@@ -0,0 +1,316 @@
+package check_http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	reTransactionVariable = regexp.MustCompile(`\$\{(\w+)\}`)
+	reStepNameNonWord     = regexp.MustCompile(`\W+`)
+)
+
+// transactionStep is a single request of a scripted transaction, steps run in order before the main request
+type transactionStep struct {
+	Capture map[string]string `yaml:"capture"` // variable name -> source, ex.: cookie:JSESSIONID, header:X-Token, jsonpath:$.token or regex:name="csrf" value="([^"]+)"
+	Name    string            `yaml:"name"`
+	Method  string            `yaml:"method"`
+	URI     string            `yaml:"uri"`
+	Body    string            `yaml:"body"`
+	Expect  string            `yaml:"expect"` // comma separated list of expected status codes
+	String  string            `yaml:"string"` // string to expect in the response body
+	Headers []string          `yaml:"headers"`
+}
+
+// parseTransactionStep parses the compact --step syntax: '<METHOD> <URI>|body=...|header=...|capture=<var>=<source>|expect=...|string=...|name=...'
+func parseTransactionStep(spec string) (*transactionStep, error) {
+	parts := strings.Split(spec, "|")
+	step := &transactionStep{Capture: map[string]string{}}
+
+	request := strings.Fields(parts[0])
+	switch len(request) {
+	case 1:
+		step.Method = http.MethodGet
+		step.URI = request[0]
+	case 2:
+		step.Method = strings.ToUpper(request[0])
+		step.URI = request[1]
+	default:
+		return nil, fmt.Errorf("step must start with '[<method>] <uri>': %s", spec)
+	}
+
+	for _, part := range parts[1:] {
+		key, val, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("expected key=value in step: %s", part)
+		}
+		switch strings.TrimSpace(key) {
+		case "name":
+			step.Name = val
+		case "body":
+			step.Body = val
+		case "header":
+			step.Headers = append(step.Headers, val)
+		case "expect":
+			step.Expect = val
+		case "string":
+			step.String = val
+		case "capture":
+			name, source, ok := strings.Cut(val, "=")
+			if !ok {
+				return nil, fmt.Errorf("capture must be <variable>=<source>: %s", val)
+			}
+			step.Capture[strings.TrimSpace(name)] = source
+		default:
+			return nil, fmt.Errorf("unknown step attribute: %s", key)
+		}
+	}
+
+	return step, nil
+}
+
+// readTransactionFile reads a list of steps from a yaml file
+func readTransactionFile(file string) ([]*transactionStep, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read step file: %s", err.Error())
+	}
+
+	steps := []*transactionStep{}
+	if err := yaml.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("cannot parse step file %s: %s", file, err.Error())
+	}
+
+	return steps, nil
+}
+
+// validateTransactionSteps sets defaults and checks the capture sources
+func validateTransactionSteps(steps []*transactionStep) error {
+	names := map[string]bool{}
+	for num, step := range steps {
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step%d", num+1)
+		}
+		step.Name = strings.Trim(reStepNameNonWord.ReplaceAllString(step.Name, "_"), "_")
+		if names[step.Name] {
+			return fmt.Errorf("duplicate step name: %s", step.Name)
+		}
+		names[step.Name] = true
+
+		if step.Method == "" {
+			step.Method = http.MethodGet
+		}
+		if step.URI == "" {
+			return fmt.Errorf("step %s has no uri", step.Name)
+		}
+
+		for name, source := range step.Capture {
+			kind, expr, _ := strings.Cut(source, ":")
+			if expr == "" {
+				return fmt.Errorf("step %s: capture %s has an empty source", step.Name, name)
+			}
+			switch kind {
+			case "cookie", "header":
+			case "jsonpath":
+				if _, err := parseJSONPath(expr); err != nil {
+					return fmt.Errorf("step %s: %s", step.Name, err.Error())
+				}
+			case "regex":
+				if _, err := regexp.Compile(expr); err != nil {
+					return fmt.Errorf("step %s: invalid regex: %s", step.Name, err.Error())
+				}
+			default:
+				return fmt.Errorf("step %s: unknown capture source %q, must be cookie, header, jsonpath or regex", step.Name, kind)
+			}
+		}
+	}
+
+	return nil
+}
+
+// expandVariables replaces ${name} with captured values, unknown variables are kept
+func expandVariables(str string, vars map[string]string) string {
+	return expandVariablesEscaped(str, vars, nil)
+}
+
+// expandVariablesEscaped replaces ${name} with the captured values escaped by the given function
+func expandVariablesEscaped(str string, vars map[string]string, escape func(string) string) string {
+	if len(vars) == 0 {
+		return str
+	}
+
+	return reTransactionVariable.ReplaceAllStringFunc(str, func(match string) string {
+		if val, ok := vars[match[2:len(match)-1]]; ok {
+			if escape != nil {
+				return escape(val)
+			}
+
+			return val
+		}
+
+		return match
+	})
+}
+
+// runTransactionSteps runs all steps with a fresh cookie jar and returns the captured variables.
+// Timing and status of each step is returned as perfdata.
+func runTransactionSteps(ctx context.Context, client *http.Client, opts *commandOpts) (vars map[string]string, perfdata, matches []string, result *CheckResult) {
+	vars = map[string]string{}
+	if len(opts.transactionSteps) == 0 {
+		return vars, nil, nil, nil
+	}
+
+	// cookies persist across all steps and the main request
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return vars, nil, nil, &CheckResult{nil, fmt.Sprintf("HTTP UNKNOWN - cannot create cookie jar: %s", err.Error()), UNKNOWN}
+	}
+	client.Jar = jar
+
+	for _, step := range opts.transactionSteps {
+		opts.tracef("transaction step: %s", step.Name)
+
+		req, err := buildStepRequest(ctx, opts, step, vars)
+		if err != nil {
+			return vars, perfdata, matches, &CheckResult{
+				nil,
+				fmt.Sprintf("HTTP UNKNOWN - step %s: error in building request: %s", step.Name, err.Error()),
+				UNKNOWN,
+			}
+		}
+
+		meta, err := performHTTPRequest(req, client, opts)
+		if err != nil {
+			return vars, perfdata, matches, &CheckResult{
+				nil,
+				fmt.Sprintf("HTTP CRITICAL - step %s: error when performing request: %s | %s", step.Name, err.Error(), strings.Join(perfdata, " ")),
+				CRITICAL,
+			}
+		}
+
+		perfdata = append(perfdata,
+			fmt.Sprintf("%s_time=%ss;;;0;", step.Name, strconv.FormatFloat(meta.duration.Seconds(), 'f', 3, 64)),
+			fmt.Sprintf("%s_status=%d;;;;", step.Name, meta.res.StatusCode),
+		)
+
+		if stepErr := checkTransactionStep(step, meta, jar, vars); stepErr != "" {
+			return vars, perfdata, matches, &CheckResult{
+				nil,
+				fmt.Sprintf("HTTP CRITICAL - step %s: %s - %s | %s", step.Name, getStatusLine(meta), stepErr, strings.Join(perfdata, " ")),
+				CRITICAL,
+			}
+		}
+
+		matches = append(matches, fmt.Sprintf("%s: %d", step.Name, meta.res.StatusCode))
+	}
+
+	return vars, perfdata, matches, nil
+}
+
+func buildStepRequest(ctx context.Context, opts *commandOpts, step *transactionStep, vars map[string]string) (*http.Request, error) {
+	// the base request contains authorization, user agent and the default headers
+	req, err := buildRequest(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := req.URL.Parse(expandVariables(step.URI, vars))
+	if err != nil {
+		return nil, fmt.Errorf("invalid uri: %s", err.Error())
+	}
+	req.URL = target
+	req.Method = step.Method
+
+	if step.Body != "" {
+		var body string
+		if strings.HasPrefix(strings.TrimSpace(step.Body), "{") || strings.HasPrefix(strings.TrimSpace(step.Body), "[") {
+			body = expandVariables(step.Body, vars)
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			// captured values may contain &, = or +, so they are url encoded in form bodies
+			body = expandVariablesEscaped(step.Body, vars, url.QueryEscape)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req.Body = io.NopCloser(strings.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+
+	for _, hdr := range step.Headers {
+		parts := strings.SplitN(expandVariables(hdr, vars), ":", 2)
+		if len(parts) == 2 {
+			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+	}
+
+	return req, nil
+}
+
+// checkTransactionStep verifies the step response and stores captured values, it returns an error message if the step failed
+func checkTransactionStep(step *transactionStep, meta *RequestMetadata, jar http.CookieJar, vars map[string]string) string {
+	if step.Expect != "" {
+		codes := strings.Split(strings.ReplaceAll(step.Expect, " ", ""), ",")
+		if !slices.Contains(codes, strconv.Itoa(meta.res.StatusCode)) {
+			return fmt.Sprintf("unexpected status code, expected: %s", step.Expect)
+		}
+	} else if meta.res.StatusCode >= http.StatusBadRequest {
+		return "invalid HTTP response received"
+	}
+
+	if step.String != "" && !strings.Contains(meta.body, expandVariables(step.String, vars)) {
+		return fmt.Sprintf("response body did not match content: %s", step.String)
+	}
+
+	for name, source := range step.Capture {
+		kind, expr, _ := strings.Cut(source, ":")
+		value, found := "", false
+		switch kind {
+		case "cookie":
+			for _, cookie := range jar.Cookies(meta.req.URL) {
+				if cookie.Name == expr {
+					value, found = cookie.Value, true
+				}
+			}
+		case "header":
+			value = meta.res.Header.Get(expr)
+			found = value != ""
+		case "jsonpath":
+			var data any
+			decoder := json.NewDecoder(strings.NewReader(meta.body))
+			decoder.UseNumber()
+			if err := decoder.Decode(&data); err != nil {
+				return fmt.Sprintf("capture %s: response body is not valid json: %s", name, err.Error())
+			}
+			steps, _ := parseJSONPath(expr)
+			if values := jsonPathQuery(data, steps); len(values) > 0 {
+				value, found = formatAssertionValue(values[0]), true
+			}
+		case "regex":
+			regex := regexp.MustCompile(expr)
+			// use the first capture group if there is one
+			if match := regex.FindStringSubmatch(meta.body); len(match) > 1 {
+				value, found = match[1], true
+			} else if len(match) == 1 {
+				value, found = match[0], true
+			}
+		}
+
+		if !found {
+			return fmt.Sprintf("could not capture %s from %s", name, source)
+		}
+		vars[name] = value
+	}
+
+	return ""
+}
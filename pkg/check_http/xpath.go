@@ -0,0 +1,263 @@
+package check_http
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is a simplified xml element, namespaces are ignored
+type xmlNode struct {
+	attrs    map[string]string
+	name     string
+	text     string
+	children []*xmlNode
+}
+
+// textContent returns the text of this element and all nested elements
+func (n *xmlNode) textContent() string {
+	res := strings.Builder{}
+	res.WriteString(n.text)
+	for _, child := range n.children {
+		res.WriteString(child.textContent())
+	}
+
+	return res.String()
+}
+
+// descendants returns the node itself and all nested elements in document order
+func (n *xmlNode) descendants() []*xmlNode {
+	res := []*xmlNode{n}
+	for _, child := range n.children {
+		res = append(res, child.descendants()...)
+	}
+
+	return res
+}
+
+// xpathStep is a single location step of an xpath expression, ex.: //item[2] or @id
+type xpathStep struct {
+	name       string // element name, "*", "@attr" or "text()"
+	predicates []string
+	descendant bool
+}
+
+// parseXMLTree parses the xml document and returns a virtual document node containing the root element
+func parseXMLTree(data []byte) (*xmlNode, error) {
+	doc := &xmlNode{}
+	stack := []*xmlNode{doc}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xml parse error: %s", err.Error())
+		}
+
+		current := stack[len(stack)-1]
+		switch elem := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: elem.Name.Local, attrs: map[string]string{}}
+			for _, attr := range elem.Attr {
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			current.children = append(current.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			current.text += string(elem)
+		}
+	}
+
+	if len(doc.children) == 0 {
+		return nil, fmt.Errorf("xml document contains no elements")
+	}
+
+	return doc, nil
+}
+
+// parseXPath parses a simple absolute xpath expression like /status/db[@name='main']/@state or //item[1]
+func parseXPath(path string) ([]xpathStep, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("xpath must start with /: %s", path)
+	}
+
+	steps := []xpathStep{}
+	rest := path
+	for rest != "" {
+		step := xpathStep{}
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			step.descendant = true
+			rest = rest[2:]
+		case rest[0] == '/':
+			rest = rest[1:]
+		default:
+			return nil, fmt.Errorf("unexpected character %q in xpath: %s", rest[0], path)
+		}
+
+		end := strings.IndexAny(rest, "/[")
+		if end == -1 {
+			end = len(rest)
+		}
+		step.name = strings.TrimSpace(rest[:end])
+		rest = rest[end:]
+		if step.name == "" {
+			return nil, fmt.Errorf("empty step in xpath: %s", path)
+		}
+
+		for strings.HasPrefix(rest, "[") {
+			end := xpathPredicateEnd(rest)
+			if end == -1 {
+				return nil, fmt.Errorf("missing closing bracket in xpath: %s", path)
+			}
+			step.predicates = append(step.predicates, strings.TrimSpace(rest[1:end]))
+			rest = rest[end+1:]
+		}
+
+		if (strings.HasPrefix(step.name, "@") || step.name == "text()") && rest != "" {
+			return nil, fmt.Errorf("%s must be the last step in xpath: %s", step.name, path)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// xpathPredicateEnd returns the position of the closing bracket, brackets within quotes are ignored
+func xpathPredicateEnd(str string) int {
+	var quote byte
+	for i := 1; i < len(str); i++ {
+		switch {
+		case quote != 0:
+			if str[i] == quote {
+				quote = 0
+			}
+		case str[i] == '\'' || str[i] == '"':
+			quote = str[i]
+		case str[i] == ']':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// xpathQuery returns the text content of all matching elements or the matching attribute values
+func xpathQuery(doc *xmlNode, steps []xpathStep) ([]any, error) {
+	nodes := []*xmlNode{doc}
+	for _, step := range steps {
+		candidates := nodes
+		if step.descendant {
+			candidates = []*xmlNode{}
+			for _, node := range nodes {
+				candidates = append(candidates, node.descendants()...)
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(step.name, "@"):
+			res := []any{}
+			for _, node := range candidates {
+				if val, ok := node.attrs[step.name[1:]]; ok {
+					res = append(res, val)
+				}
+			}
+
+			return res, nil
+		case step.name == "text()":
+			res := []any{}
+			for _, node := range candidates {
+				if text := strings.TrimSpace(node.text); text != "" {
+					res = append(res, text)
+				}
+			}
+
+			return res, nil
+		}
+
+		next := []*xmlNode{}
+		for _, node := range candidates {
+			matched := []*xmlNode{}
+			for _, child := range node.children {
+				if step.name == "*" || child.name == step.name {
+					matched = append(matched, child)
+				}
+			}
+			for _, pred := range step.predicates {
+				var err error
+				matched, err = xpathFilter(matched, pred)
+				if err != nil {
+					return nil, err
+				}
+			}
+			next = append(next, matched...)
+		}
+		nodes = next
+	}
+
+	res := make([]any, 0, len(nodes))
+	for _, node := range nodes {
+		res = append(res, strings.TrimSpace(node.textContent()))
+	}
+
+	return res, nil
+}
+
+// xpathFilter applies a predicate: [1], [last()], [@attr], [@attr='value'] or [child='value']
+func xpathFilter(nodes []*xmlNode, pred string) ([]*xmlNode, error) {
+	if pred == "last()" {
+		if len(nodes) == 0 {
+			return nodes, nil
+		}
+
+		return nodes[len(nodes)-1:], nil
+	}
+
+	if index, err := strconv.Atoi(pred); err == nil {
+		if index < 1 || index > len(nodes) {
+			return nil, nil
+		}
+
+		return nodes[index-1 : index], nil
+	}
+
+	name, value, hasValue := strings.Cut(pred, "=")
+	name = strings.TrimSpace(name)
+	if hasValue {
+		value = strings.TrimSpace(value)
+		if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+			return nil, fmt.Errorf("unsupported xpath predicate: [%s]", pred)
+		}
+		value = value[1 : len(value)-1]
+	}
+
+	res := []*xmlNode{}
+	for _, node := range nodes {
+		if attr, ok := strings.CutPrefix(name, "@"); ok {
+			if val, found := node.attrs[attr]; found && (!hasValue || val == value) {
+				res = append(res, node)
+			}
+
+			continue
+		}
+		for _, child := range node.children {
+			if child.name == name && (!hasValue || strings.TrimSpace(child.textContent()) == value) {
+				res = append(res, node)
+
+				break
+			}
+		}
+	}
+
+	return res, nil
+}
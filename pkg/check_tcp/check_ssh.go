@@ -11,7 +11,8 @@ import (
 )
 
 func CheckSSH(_ context.Context, output io.Writer, args []string, sendString string) int {
-	opts, err := parseArgs(args)
+	opts := &sshOpts{}
+	err := parseFlags("check_ssh", opts, &opts.tcpOpts, args)
 	if err != nil {
 		var flagsErr *flags.Error
 		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
@@ -58,6 +59,22 @@ func CheckSSH(_ context.Context, output io.Writer, args []string, sendString str
 		ckr.Name = opts.Service
 	}
 
+	// run the key exchange on a separate connection once the banner check succeeded
+	msg, perf, found := strings.Cut(ckr.Message, " | ")
+	if found && !opts.ExpectClosed {
+		if opts.Verbose {
+			fmt.Fprintf(output, "Running key exchange with client version: %s\n", sendString)
+		}
+		hostKeyStatus, hostKeyMsg, longOutput := opts.checkHostKey(sendString)
+		if hostKeyStatus > ckr.Status {
+			ckr.Status = hostKeyStatus
+		}
+		ckr.Message = msg + " - " + hostKeyMsg + " | " + perf
+		if longOutput != "" {
+			ckr.Message += "\n" + longOutput
+		}
+	}
+
 	fmt.Fprintf(output, "%s %s - %s", ckr.Name, ckr.Status, strings.TrimSpace(ckr.Message))
 
 	return int(ckr.Status)
@@ -74,6 +74,22 @@ type tcpOpts struct {
 	ErrWarning   bool    `short:"W" long:"error-warning" description:"Set the error level to warning when exiting with unexpected error (default: critical). In the case of request succeeded, evaluation result of -c option eval takes priority."`
 	ExpectClosed bool    `short:"C" long:"expect-closed" description:"Verify that the port/unixsock is closed. If the port/unixsock is closed, OK; if open, follow the ErrWarning flag. This option only verifies the connection."`
 	Verbose      bool    `short:"v" long:"verbose" description:"Enables verbose logging of the actions taken."`
+	cert         certificateOpts
+}
+
+// checkTCPOpts adds the certificate options which are only available in check_tcp
+type checkTCPOpts struct {
+	tcpOpts
+	certificateOpts
+}
+
+type certificateOpts struct {
+	StartTLS                 string `long:"starttls" description:"Negotiate tls with STARTTLS after connecting and check the certificate. The send/expect exchange runs on the encrypted connection." choice:"smtp" choice:"imap" choice:"pop3" choice:"ftp" choice:"ldap" choice:"postgres" choice:"xmpp"`
+	Certificate              string `long:"certificate" description:"Check the certificate with the thresholds in days left: warn_days[,<crit_days>]. Enables the certificate check for --ssl connections, defaults to 30,14 with --starttls"`
+	IgnoreCertificateChain   bool   `long:"ignore-certificate-chain" description:"Only check the leaf certificate and not the whole chain"`
+	CheckCN                  bool   `long:"check-cn" description:"Check that the common name of the leaf certificate matches the hostname"`
+	CheckSAN                 bool   `long:"check-san" description:"Check that the subject alternative names of the leaf certificate match the hostname"`
+	IgnoreSignatureAlgorithm bool   `long:"ignore-signature-algorithm" description:"Do not check for weak or deprecated signature algorithms"`
 }
 
 type exchange struct {
@@ -88,18 +104,28 @@ type exchange struct {
 }
 
 func parseArgs(args []string) (*tcpOpts, error) {
-	opts := &tcpOpts{}
-	psr := flags.NewParser(opts, flags.HelpFlag|flags.PassDoubleDash) // default flags without flags.PrintErrors
-	psr.Name = "check_tcp"
+	data := &checkTCPOpts{}
+	err := parseFlags("check_tcp", data, &data.tcpOpts, args)
+	if err != nil {
+		return nil, err
+	}
+	data.tcpOpts.cert = data.certificateOpts
+	return &data.tcpOpts, nil
+}
+
+// parseFlags parses the args into data, the first remaining argument is used as hostname
+func parseFlags(name string, data any, opts *tcpOpts, args []string) error {
+	psr := flags.NewParser(data, flags.HelpFlag|flags.PassDoubleDash) // default flags without flags.PrintErrors
+	psr.Name = name
 	remaining, err := psr.ParseArgs(args)
 	if len(remaining) > 0 && opts.Hostname == "" {
 		opts.Hostname = remaining[0]
 		remaining = remaining[1:]
 	}
 	if len(remaining) > 0 {
-		return nil, fmt.Errorf("cannot parse options, unknown option: %s", strings.Join(remaining, " "))
+		return fmt.Errorf("cannot parse options, unknown option: %s", strings.Join(remaining, " "))
 	}
-	return opts, err
+	return err
 }
 
 var defaultExchangeMap = map[string]exchange{
@@ -164,6 +190,15 @@ func (opts *tcpOpts) prepare() error {
 		opts.merge(defaultEx)
 	}
 
+	if opts.cert.StartTLS != "" {
+		if opts.Port == 0 {
+			opts.Port = defaultStartTLSPorts[opts.cert.StartTLS]
+		}
+		if opts.cert.Certificate == "" {
+			opts.cert.Certificate = defaultStartTLSCertificate
+		}
+	}
+
 	if opts.Escape {
 		opts.Quit = escapedString(opts.Quit)
 		opts.Send = escapedString(opts.Send)
@@ -236,7 +271,9 @@ func (opts *tcpOpts) run(output io.Writer) *checkers.Checker {
 	if opts.Verbose {
 		fmt.Fprintf(output, "Establishing a connection to addr: %s protocol: %s ssl: %t noCheckCertificate: %t timeout: %f\n", addr, proto, opts.SSL, opts.NoCheckCertificate, timeout.Seconds())
 	}
-	conn, err := dial(proto, addr, opts.SSL, opts.NoCheckCertificate, timeout)
+	// certificates are validated by the certificate check itself
+	noCheckCertificate := opts.NoCheckCertificate || opts.cert.Certificate != ""
+	conn, err := dial(proto, addr, opts.SSL, noCheckCertificate, timeout)
 	if err != nil {
 		if opts.ExpectClosed {
 			var msg string
@@ -267,6 +304,29 @@ func (opts *tcpOpts) run(output io.Writer) *checkers.Checker {
 		return checkers.Critical(msg)
 	}
 
+	if opts.cert.StartTLS != "" {
+		if opts.Verbose {
+			fmt.Fprintf(output, "Negotiating tls with starttls: %s\n", opts.cert.StartTLS)
+		}
+		tlsConn, err := startTLS(conn, opts.cert.StartTLS, opts.Hostname, timeout)
+		if err != nil {
+			if opts.ErrWarning {
+				return checkers.Warning(err.Error())
+			}
+			return checkers.Critical(err.Error())
+		}
+		defer tlsConn.Close()
+		conn = tlsConn
+	}
+
+	certStatus, certMsg := checkers.OK, ""
+	if tlsConn, ok := conn.(*tls.Conn); ok && opts.cert.Certificate != "" {
+		certStatus, certMsg, err = opts.checkCertificate(tlsConn)
+		if err != nil {
+			return checkers.Unknown(err.Error())
+		}
+	}
+
 	if opts.Send != "" {
 		if opts.Verbose {
 			fmt.Fprintf(output, "Writing to the socket: %s\n", opts.Send)
@@ -323,6 +383,9 @@ func (opts *tcpOpts) run(output io.Writer) *checkers.Checker {
 	if opts.Critical > 0 && elapsedSeconds > opts.Critical {
 		chkSt = checkers.CRITICAL
 	}
+	if certStatus > chkSt {
+		chkSt = certStatus
+	}
 	msg := fmt.Sprintf("%.3f seconds response time on", elapsedSeconds)
 	if opts.Hostname != "" {
 		msg += " " + opts.Hostname
@@ -334,7 +397,16 @@ func (opts *tcpOpts) run(output io.Writer) *checkers.Checker {
 		msg += fmt.Sprintf(" [%s]", strings.Trim(res, "\r\n"))
 	}
 
+	certPerf := ""
+	if certMsg != "" {
+		certMsg, certPerf, _ = strings.Cut(certMsg, " | ")
+		msg += " - " + certMsg
+	}
+
 	msg += fmt.Sprintf(" | time=%fs;;;%f;%f", elapsedSeconds, opts.Warning, opts.Critical)
+	if certPerf != "" {
+		msg += " " + certPerf
+	}
 
 	return checkers.NewChecker(chkSt, msg)
 }
@@ -0,0 +1,379 @@
+package check_tcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mackerelio/checkers"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// ssh message number of the key exchange init packet, see RFC 4253 7.1
+	sshMsgKexInit = 20
+
+	// only the beginning of the connection is required to parse the key exchange init
+	sshMaxRecordedBytes = 64 * 1024
+)
+
+// errHostKeyReceived aborts the handshake once the server host key is known, so no authentication is attempted
+var errHostKeyReceived = errors.New("host key received")
+
+// sshClientVersionPrefix matches the protocol version of the identification string
+var sshClientVersionPrefix = regexp.MustCompile(`^SSH-\d+\.\d+-`)
+
+// weak algorithms, based on RFC 9142 and the openssh defaults
+var (
+	sshWeakKexAlgorithms = []string{
+		"diffie-hellman-group1-sha1",
+		"diffie-hellman-group-exchange-sha1",
+	}
+	sshWeakHostKeyAlgorithms = []string{
+		"ssh-rsa",
+		"ssh-dss",
+	}
+)
+
+type hostKeyOpts struct {
+	Fingerprint          []string `long:"fingerprint" description:"Expected SHA256 fingerprint of the host key, ex.: SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8. Can be used multiple times"`
+	KnownHosts           string   `long:"known-hosts" description:"Verify the host key against this known_hosts file"`
+	IgnoreWeakAlgorithms bool     `long:"ignore-weak-algorithms" description:"Do not warn if the server offers weak key exchange, host key, cipher or mac algorithms"`
+}
+
+type sshOpts struct {
+	tcpOpts
+	hostKeyOpts
+}
+
+// sshKexInit contains the algorithms offered by the server
+type sshKexInit struct {
+	KexAlgorithms     []string
+	HostKeyAlgorithms []string
+	Ciphers           []string
+	MACs              []string
+}
+
+// recordingConn keeps a copy of the first bytes read from the connection
+type recordingConn struct {
+	net.Conn
+	mutex    sync.Mutex
+	recorded bytes.Buffer
+}
+
+func (c *recordingConn) Read(data []byte) (int, error) {
+	size, err := c.Conn.Read(data)
+
+	c.mutex.Lock()
+	if c.recorded.Len() < sshMaxRecordedBytes {
+		c.recorded.Write(data[:size])
+	}
+	c.mutex.Unlock()
+
+	return size, err
+}
+
+func (c *recordingConn) Recorded() []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return bytes.Clone(c.recorded.Bytes())
+}
+
+// checkHostKey runs the ssh key exchange without authentication and checks the host key and the offered algorithms
+func (opts *sshOpts) checkHostKey(clientVersion string) (status checkers.Status, msg, longOutput string) {
+	failedStatus := checkers.CRITICAL
+	if opts.ErrWarning {
+		failedStatus = checkers.WARNING
+	}
+
+	proto := "tcp"
+	addr := net.JoinHostPort(opts.Hostname, strconv.Itoa(opts.Port))
+	if opts.UnixSock != "" {
+		proto = "unix"
+		addr = opts.UnixSock
+	}
+
+	// offer everything to get the host key from servers with weak configurations as well
+	supported := ssh.SupportedAlgorithms()
+	insecure := ssh.InsecureAlgorithms()
+	hostKeyAlgorithms := append(supported.HostKeys, insecure.HostKeys...)
+
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = time.Now().Add(time.Duration(opts.Timeout * float64(time.Second)))
+	}
+
+	hostKey, remoteAddr, recorded, err := opts.fetchHostKey(proto, addr, clientVersion, hostKeyAlgorithms, deadline)
+
+	kexInit, kexErr := parseSSHKexInit(recorded)
+	if kexErr == nil {
+		longOutput = kexInit.String()
+	}
+
+	if hostKey == nil {
+		return failedStatus, fmt.Sprintf("ssh handshake failed: %s", err.Error()), longOutput
+	}
+
+	fingerprint := ssh.FingerprintSHA256(hostKey)
+	msg = fmt.Sprintf("%s host key %s", hostKey.Type(), fingerprint)
+
+	status, reason := opts.verifyHostKey(addr, remoteAddr, hostKey)
+
+	// servers usually have multiple host keys, like openssh try the other offered key types before failing
+	if status == checkers.CRITICAL && kexErr == nil {
+		tried := []string{hostKey.Type()}
+		for _, algorithm := range kexInit.HostKeyAlgorithms {
+			keyType := sshKeyType(algorithm)
+			if slices.Contains(tried, keyType) || !slices.Contains(hostKeyAlgorithms, algorithm) {
+				continue
+			}
+			tried = append(tried, keyType)
+
+			otherKey, otherAddr, _, _ := opts.fetchHostKey(proto, addr, clientVersion, []string{algorithm}, deadline)
+			if otherKey == nil {
+				continue
+			}
+
+			if otherStatus, _ := opts.verifyHostKey(addr, otherAddr, otherKey); otherStatus == checkers.OK {
+				status = checkers.OK
+				msg = fmt.Sprintf("%s host key %s", otherKey.Type(), ssh.FingerprintSHA256(otherKey))
+
+				break
+			}
+		}
+	}
+
+	switch {
+	case status == checkers.UNKNOWN:
+		return status, reason, longOutput
+	case status != checkers.OK:
+		return status, msg + " " + reason, longOutput
+	}
+
+	if kexErr != nil {
+		return checkers.UNKNOWN, fmt.Sprintf("%s, cannot parse offered algorithms: %s", msg, kexErr.Error()), longOutput
+	}
+
+	if weak := kexInit.WeakAlgorithms(); len(weak) > 0 && !opts.IgnoreWeakAlgorithms {
+		return checkers.WARNING, fmt.Sprintf("%s, weak algorithms offered: %s", msg, strings.Join(weak, ", ")), longOutput
+	}
+
+	return checkers.OK, msg, longOutput
+}
+
+// fetchHostKey connects to the server and returns the host key using one of the given host key algorithms along with the recorded start of the connection
+func (opts *sshOpts) fetchHostKey(proto, addr, clientVersion string, hostKeyAlgorithms []string, deadline time.Time) (hostKey ssh.PublicKey, remoteAddr net.Addr, recorded []byte, err error) {
+	timeout := time.Duration(0)
+	if !deadline.IsZero() {
+		timeout = time.Until(deadline)
+		if timeout <= 0 {
+			return nil, nil, nil, fmt.Errorf("timeout while fetching host key")
+		}
+	}
+
+	conn, err := dial(proto, addr, opts.SSL, opts.NoCheckCertificate, timeout)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer conn.Close()
+	if !deadline.IsZero() {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	recorder := &recordingConn{Conn: conn}
+	config := &ssh.ClientConfig{
+		User:          "snclient",
+		ClientVersion: sshClientVersionPrefix.ReplaceAllString(clientVersion, "SSH-2.0-"),
+		Timeout:       timeout,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+
+			return errHostKeyReceived
+		},
+		HostKeyAlgorithms: hostKeyAlgorithms,
+	}
+
+	supported := ssh.SupportedAlgorithms()
+	insecure := ssh.InsecureAlgorithms()
+	config.KeyExchanges = append(supported.KeyExchanges, insecure.KeyExchanges...)
+	config.Ciphers = append(supported.Ciphers, insecure.Ciphers...)
+	config.MACs = append(supported.MACs, insecure.MACs...)
+
+	_, _, _, err = ssh.NewClientConn(recorder, addr, config)
+	if hostKey == nil && (err == nil || errors.Is(err, errHostKeyReceived)) {
+		err = fmt.Errorf("no host key received")
+	}
+
+	return hostKey, conn.RemoteAddr(), recorder.Recorded(), err
+}
+
+// verifyHostKey checks the host key against the pinned fingerprints and the known hosts file
+func (opts *sshOpts) verifyHostKey(addr string, remoteAddr net.Addr, hostKey ssh.PublicKey) (status checkers.Status, reason string) {
+	fingerprint := ssh.FingerprintSHA256(hostKey)
+	if len(opts.Fingerprint) > 0 && !slices.ContainsFunc(opts.Fingerprint, func(pinned string) bool {
+		return normalizeFingerprint(pinned) == fingerprint
+	}) {
+		return checkers.CRITICAL, "does not match any pinned fingerprint"
+	}
+
+	if opts.KnownHosts != "" {
+		callback, err := knownhosts.New(opts.KnownHosts)
+		if err != nil {
+			return checkers.UNKNOWN, fmt.Sprintf("cannot read known hosts file: %s", err.Error())
+		}
+
+		if err := callback(addr, remoteAddr, hostKey); err != nil {
+			return checkers.CRITICAL, knownHostsError(err, opts.KnownHosts)
+		}
+	}
+
+	return checkers.OK, ""
+}
+
+// sshKeyType returns the public key type used by the host key algorithm
+func sshKeyType(algorithm string) string {
+	switch algorithm {
+	case ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512:
+		return ssh.KeyAlgoRSA
+	default:
+		return algorithm
+	}
+}
+
+// normalizeFingerprint adds the SHA256: prefix and removes the base64 padding
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.TrimRight(strings.TrimSpace(fingerprint), "=")
+	if len(fingerprint) > 7 && strings.EqualFold(fingerprint[:7], "SHA256:") {
+		fingerprint = fingerprint[7:]
+	}
+
+	return "SHA256:" + fingerprint
+}
+
+func knownHostsError(err error, file string) string {
+	var keyErr *knownhosts.KeyError
+	var revokedErr *knownhosts.RevokedError
+	switch {
+	case errors.As(err, &revokedErr):
+		return fmt.Sprintf("is revoked in %s:%d", revokedErr.Revoked.Filename, revokedErr.Revoked.Line)
+	case errors.As(err, &keyErr) && len(keyErr.Want) == 0:
+		return fmt.Sprintf("not found in %s", file)
+	case errors.As(err, &keyErr):
+		return fmt.Sprintf("does not match %s:%d", keyErr.Want[0].Filename, keyErr.Want[0].Line)
+	default:
+		return fmt.Sprintf("cannot be verified: %s", err.Error())
+	}
+}
+
+// parseSSHKexInit extracts the key exchange init packet of the server from the raw connection data, see RFC 4253 4.2 and 7.1
+func parseSSHKexInit(data []byte) (*sshKexInit, error) {
+	// skip the identification string and any lines sent before it
+	for {
+		line, rest, found := bytes.Cut(data, []byte("\n"))
+		if !found {
+			return nil, fmt.Errorf("no identification string received")
+		}
+		data = rest
+		if bytes.HasPrefix(line, []byte("SSH-")) {
+			break
+		}
+	}
+
+	if len(data) < 5 {
+		return nil, fmt.Errorf("no key exchange init received")
+	}
+	packetLength := int(binary.BigEndian.Uint32(data))
+	paddingLength := int(data[4])
+	if packetLength < paddingLength+1 || len(data) < 4+packetLength {
+		return nil, fmt.Errorf("incomplete key exchange init packet")
+	}
+	payload := data[5 : 4+packetLength-paddingLength]
+
+	// message number and 16 byte cookie
+	if len(payload) < 17 || payload[0] != sshMsgKexInit {
+		return nil, fmt.Errorf("unexpected packet, expected key exchange init")
+	}
+	payload = payload[17:]
+
+	// kex, host key, ciphers c2s, ciphers s2c, macs c2s, macs s2c, compression c2s, compression s2c, languages c2s, languages s2c
+	lists := make([][]string, 0, 10)
+	for range 10 {
+		if len(payload) < 4 {
+			return nil, fmt.Errorf("truncated key exchange init packet")
+		}
+		size := int(binary.BigEndian.Uint32(payload))
+		if len(payload) < 4+size {
+			return nil, fmt.Errorf("truncated key exchange init packet")
+		}
+		list := []string{}
+		if size > 0 {
+			list = strings.Split(string(payload[4:4+size]), ",")
+		}
+		lists = append(lists, list)
+		payload = payload[4+size:]
+	}
+
+	return &sshKexInit{
+		KexAlgorithms:     lists[0],
+		HostKeyAlgorithms: lists[1],
+		Ciphers:           mergeNameLists(lists[2], lists[3]),
+		MACs:              mergeNameLists(lists[4], lists[5]),
+	}, nil
+}
+
+func mergeNameLists(first, second []string) []string {
+	merged := slices.Clone(first)
+	for _, name := range second {
+		if !slices.Contains(merged, name) {
+			merged = append(merged, name)
+		}
+	}
+
+	return merged
+}
+
+// WeakAlgorithms returns all offered algorithms which should not be used anymore
+func (k *sshKexInit) WeakAlgorithms() []string {
+	weak := []string{}
+	for _, name := range k.KexAlgorithms {
+		if slices.Contains(sshWeakKexAlgorithms, name) {
+			weak = append(weak, name)
+		}
+	}
+	for _, name := range k.HostKeyAlgorithms {
+		if slices.Contains(sshWeakHostKeyAlgorithms, name) {
+			weak = append(weak, name)
+		}
+	}
+	for _, name := range k.Ciphers {
+		if strings.HasSuffix(name, "-cbc") || strings.HasPrefix(name, "arcfour") {
+			weak = append(weak, name)
+		}
+	}
+	for _, name := range k.MACs {
+		if strings.HasPrefix(name, "hmac-md5") || strings.HasSuffix(name, "-96") {
+			weak = append(weak, name)
+		}
+	}
+
+	return weak
+}
+
+func (k *sshKexInit) String() string {
+	return fmt.Sprintf("kex algorithms: %s\nhost key algorithms: %s\nciphers: %s\nmacs: %s",
+		strings.Join(k.KexAlgorithms, ", "),
+		strings.Join(k.HostKeyAlgorithms, ", "),
+		strings.Join(k.Ciphers, ", "),
+		strings.Join(k.MACs, ", "),
+	)
+}
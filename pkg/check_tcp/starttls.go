@@ -0,0 +1,393 @@
+package check_tcp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/consol-monitoring/snclient/pkg/check_http"
+	"github.com/mackerelio/checkers"
+)
+
+const (
+	// default thresholds for the certificate check if --starttls is used without --certificate
+	defaultStartTLSCertificate = "30,14"
+
+	// ldap extended operation to start tls, see RFC 4511
+	ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+
+	// postgres SSLRequest code, see https://www.postgresql.org/docs/current/protocol-message-formats.html
+	postgresSSLRequestCode = 80877103
+)
+
+// defaultStartTLSPorts contains the default port for each starttls protocol
+var defaultStartTLSPorts = map[string]int{
+	"smtp":     25,
+	"imap":     143,
+	"pop3":     110,
+	"ftp":      21,
+	"ldap":     389,
+	"postgres": 5432,
+	"xmpp":     5222,
+}
+
+var errStartTLSNotSupported = errors.New("server does not support starttls")
+
+// startTLS negotiates tls on the plain connection with the given protocol and returns the tls connection after the handshake
+func startTLS(conn net.Conn, protocol, hostname string, timeout time.Duration) (*tls.Conn, error) {
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, fmt.Errorf("set deadline: %s", err.Error())
+		}
+	}
+
+	reader := bufio.NewReader(conn)
+
+	var err error
+	switch protocol {
+	case "smtp":
+		err = startTLSSMTP(conn, reader)
+	case "imap":
+		err = startTLSIMAP(conn, reader)
+	case "pop3":
+		err = startTLSLine(conn, reader, "STLS", "+OK")
+	case "ftp":
+		err = startTLSFTP(conn, reader)
+	case "ldap":
+		err = startTLSLDAP(conn, reader)
+	case "postgres":
+		err = startTLSPostgres(conn, reader)
+	case "xmpp":
+		err = startTLSXMPP(conn, reader, hostname)
+	default:
+		err = fmt.Errorf("unsupported starttls protocol: %s", protocol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("starttls %s: %w", protocol, err)
+	}
+
+	//nolint:gosec // certificate verification is done by the certificate check afterwards
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         hostname,
+		InsecureSkipVerify: true,
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("tls handshake failed: %s", err.Error())
+	}
+
+	// reset deadline, following reads and writes set their own
+	_ = conn.SetDeadline(time.Time{})
+
+	return tlsConn, nil
+}
+
+// readResponse reads a (multi-line) response with 3 digit status codes like smtp and ftp use
+func readResponse(reader *bufio.Reader) (code string, lines []string, err error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", lines, fmt.Errorf("read response: %s", err.Error())
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		if len(line) < 3 {
+			return "", lines, fmt.Errorf("invalid response: %s", line)
+		}
+		// continuation lines have a dash after the code
+		if len(line) == 3 || line[3] != '-' {
+			return line[:3], lines, nil
+		}
+	}
+}
+
+func expectResponse(reader *bufio.Reader, expectCode string) ([]string, error) {
+	code, lines, err := readResponse(reader)
+	if err != nil {
+		return lines, err
+	}
+	if code != expectCode {
+		return lines, fmt.Errorf("unexpected response: %s", strings.Join(lines, " "))
+	}
+
+	return lines, nil
+}
+
+func writeLine(conn net.Conn, line string) error {
+	if _, err := io.WriteString(conn, line+"\r\n"); err != nil {
+		return fmt.Errorf("write: %s", err.Error())
+	}
+
+	return nil
+}
+
+func startTLSSMTP(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := expectResponse(reader, "220"); err != nil {
+		return err
+	}
+	if err := writeLine(conn, "EHLO check_tcp"); err != nil {
+		return err
+	}
+	lines, err := expectResponse(reader, "250")
+	if err != nil {
+		return err
+	}
+	supported := false
+	for _, line := range lines {
+		if len(line) > 4 && strings.EqualFold(strings.TrimSpace(line[4:]), "STARTTLS") {
+			supported = true
+		}
+	}
+	if !supported {
+		return errStartTLSNotSupported
+	}
+	if err := writeLine(conn, "STARTTLS"); err != nil {
+		return err
+	}
+	_, err = expectResponse(reader, "220")
+
+	return err
+}
+
+func startTLSFTP(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := expectResponse(reader, "220"); err != nil {
+		return err
+	}
+	if err := writeLine(conn, "AUTH TLS"); err != nil {
+		return err
+	}
+	_, err := expectResponse(reader, "234")
+
+	return err
+}
+
+func startTLSIMAP(conn net.Conn, reader *bufio.Reader) error {
+	greeting, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read greeting: %s", err.Error())
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("unexpected greeting: %s", strings.TrimSpace(greeting))
+	}
+	if err := writeLine(conn, "a1 STARTTLS"); err != nil {
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("read response: %s", err.Error())
+		}
+		// skip untagged responses
+		if strings.HasPrefix(line, "* ") {
+			continue
+		}
+		if strings.HasPrefix(line, "a1 OK") {
+			return nil
+		}
+
+		return fmt.Errorf("unexpected response: %s", strings.TrimSpace(line))
+	}
+}
+
+// startTLSLine reads the greeting, sends the command and expects a response with the same prefix as the greeting
+func startTLSLine(conn net.Conn, reader *bufio.Reader, command, expectPrefix string) error {
+	greeting, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read greeting: %s", err.Error())
+	}
+	if !strings.HasPrefix(greeting, expectPrefix) {
+		return fmt.Errorf("unexpected greeting: %s", strings.TrimSpace(greeting))
+	}
+	if err := writeLine(conn, command); err != nil {
+		return err
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read response: %s", err.Error())
+	}
+	if !strings.HasPrefix(line, expectPrefix) {
+		return fmt.Errorf("unexpected response: %s", strings.TrimSpace(line))
+	}
+
+	return nil
+}
+
+func startTLSLDAP(conn net.Conn, reader *bufio.Reader) error {
+	// LDAPMessage { messageID 1, extendedReq { requestName ldapStartTLSOID } }
+	oid := append([]byte{0x80, byte(len(ldapStartTLSOID))}, ldapStartTLSOID...)
+	request := append([]byte{0x77, byte(len(oid))}, oid...)
+	message := append([]byte{0x02, 0x01, 0x01}, request...)
+	message = append([]byte{0x30, byte(len(message))}, message...)
+	if _, err := conn.Write(message); err != nil {
+		return fmt.Errorf("write: %s", err.Error())
+	}
+
+	// LDAPMessage { messageID, extendedResp { resultCode, ... } }
+	tag, body, err := readBER(reader)
+	if err != nil {
+		return err
+	}
+	if tag != 0x30 {
+		return fmt.Errorf("unexpected ldap response tag: 0x%02x", tag)
+	}
+	_, _, rest, err := parseBER(body) // messageID
+	if err != nil {
+		return err
+	}
+	respTag, resp, _, err := parseBER(rest)
+	if err != nil {
+		return err
+	}
+	if respTag != 0x78 {
+		return fmt.Errorf("unexpected ldap response tag: 0x%02x", respTag)
+	}
+	if len(resp) < 3 || resp[0] != 0x0a || resp[1] != 0x01 {
+		return errors.New("invalid ldap extended response")
+	}
+	if resp[2] != 0 {
+		return fmt.Errorf("%w (ldap result code %d)", errStartTLSNotSupported, resp[2])
+	}
+
+	return nil
+}
+
+// readBER reads a single BER element from the reader and returns its tag and content
+func readBER(reader *bufio.Reader) (tag byte, content []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return 0, nil, fmt.Errorf("read response: %s", err.Error())
+	}
+	length := int(header[1])
+	if length&0x80 != 0 {
+		numBytes := length & 0x7f
+		if numBytes == 0 || numBytes > 4 {
+			return 0, nil, errors.New("invalid ber length")
+		}
+		lengthBytes := make([]byte, numBytes)
+		if _, err = io.ReadFull(reader, lengthBytes); err != nil {
+			return 0, nil, fmt.Errorf("read response: %s", err.Error())
+		}
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	content = make([]byte, length)
+	if _, err = io.ReadFull(reader, content); err != nil {
+		return 0, nil, fmt.Errorf("read response: %s", err.Error())
+	}
+
+	return header[0], content, nil
+}
+
+// parseBER returns the tag and content of the first BER element in data and the remaining data
+func parseBER(data []byte) (tag byte, content, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errors.New("invalid ber element")
+	}
+	tag, length, offset := data[0], int(data[1]), 2
+	if length&0x80 != 0 {
+		numBytes := length & 0x7f
+		if numBytes == 0 || numBytes > 4 || len(data) < offset+numBytes {
+			return 0, nil, nil, errors.New("invalid ber length")
+		}
+		length = 0
+		for _, b := range data[offset : offset+numBytes] {
+			length = length<<8 | int(b)
+		}
+		offset += numBytes
+	}
+	if len(data) < offset+length {
+		return 0, nil, nil, errors.New("truncated ber element")
+	}
+
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
+
+func startTLSPostgres(conn net.Conn, reader *bufio.Reader) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], postgresSSLRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("write: %s", err.Error())
+	}
+
+	answer, err := reader.ReadByte()
+	if err != nil {
+		return fmt.Errorf("read response: %s", err.Error())
+	}
+	if answer != 'S' {
+		return errStartTLSNotSupported
+	}
+
+	return nil
+}
+
+func startTLSXMPP(conn net.Conn, reader *bufio.Reader, hostname string) error {
+	stream := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", hostname)
+	if _, err := io.WriteString(conn, stream); err != nil {
+		return fmt.Errorf("write: %s", err.Error())
+	}
+
+	features, err := readUntil(reader, "</stream:features>")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(features, "urn:ietf:params:xml:ns:xmpp-tls") {
+		return errStartTLSNotSupported
+	}
+
+	if _, err := io.WriteString(conn, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"); err != nil {
+		return fmt.Errorf("write: %s", err.Error())
+	}
+
+	answer, err := readUntil(reader, ">")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(answer, "<proceed") {
+		return fmt.Errorf("unexpected response: %s", answer)
+	}
+
+	return nil
+}
+
+// readUntil reads from the reader until the data ends with the given suffix
+func readUntil(reader *bufio.Reader, suffix string) (string, error) {
+	data := strings.Builder{}
+	for !strings.HasSuffix(data.String(), suffix) {
+		char, err := reader.ReadByte()
+		if err != nil {
+			return data.String(), fmt.Errorf("read response: %s", err.Error())
+		}
+		data.WriteByte(char)
+	}
+
+	return data.String(), nil
+}
+
+// checkCertificate validates the peer certificates of the tls connection
+func (opts *tcpOpts) checkCertificate(conn *tls.Conn) (checkers.Status, string, error) {
+	warnDays, critDays, err := check_http.ParseCertificateDays(opts.cert.Certificate)
+	if err != nil {
+		return checkers.UNKNOWN, "", fmt.Errorf("%w", err)
+	}
+
+	code, msg := check_http.CheckCertificateChain(&check_http.CertificateCheckOptions{
+		Hostname:                 opts.Hostname,
+		WarnDays:                 warnDays,
+		CritDays:                 critDays,
+		IgnoreCertificateChain:   opts.cert.IgnoreCertificateChain,
+		CheckCN:                  opts.cert.CheckCN,
+		CheckSAN:                 opts.cert.CheckSAN,
+		IgnoreSignatureAlgorithm: opts.cert.IgnoreSignatureAlgorithm,
+		Verbose:                  opts.Verbose,
+	}, conn.ConnectionState().PeerCertificates)
+
+	return checkers.Status(code), msg, nil
+}
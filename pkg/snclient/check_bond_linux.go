@@ -0,0 +1,316 @@
+package snclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
+)
+
+func init() {
+	AvailableChecks["check_bond"] = CheckEntry{"check_bond", NewCheckBond}
+}
+
+var (
+	bondProcPath   = "/proc/net/bonding"
+	bondSysNetPath = "/sys/class/net"
+)
+
+type CheckBond struct {
+	devices []string
+}
+
+// bondSlave contains the state of a single bond member interface
+type bondSlave struct {
+	name         string
+	miiStatus    string
+	speed        string
+	duplex       string
+	linkFailures int64
+}
+
+func NewCheckBond() CheckHandler {
+	return &CheckBond{}
+}
+
+func (l *CheckBond) Build() *CheckData {
+	return &CheckData{
+		name:         "check_bond",
+		description:  "Checks the state of linux bonding interfaces and their slaves.",
+		implemented:  Linux,
+		hasInventory: ListInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"device": {value: &l.devices, isFilter: true, description: "Show this bond interface only, ex.: bond0"},
+		},
+		defaultWarning:  "state = 'degraded'",
+		defaultCritical: "state = 'down'",
+		detailSyntax:    "${name} (${mode}) ${state} [${slaves_up}/${slaves}]",
+		okSyntax:        "%(status) - all %{count} bonds are ok",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no bond interfaces found",
+		attributes: []CheckAttribute{
+			{name: "name", description: "Name of the bond interface, ex.: bond0"},
+			{name: "mode", description: "Bonding mode, ex.: active-backup or 802.3ad"},
+			{name: "state", description: "Summarized state: ok, degraded (not all slaves are up) or down"},
+			{name: "mii_status", description: "MII status of the bond interface: up / down"},
+			{name: "active_slave", description: "Currently active slave (active-backup mode only)"},
+			{name: "slaves", description: "Number of configured slaves"},
+			{name: "slaves_up", description: "Number of slaves with MII status up"},
+			{name: "slaves_down", description: "Number of slaves which are not up"},
+			{name: "link_failures", description: "Sum of link failures of all slaves"},
+			{name: "slave_status", description: "Status of all slaves, ex.: eth0 up 1000Mbps full, eth1 down"},
+		},
+		listSorted: []string{"name"},
+		exampleDefault: `
+    check_bond
+    OK - all 2 bonds are ok |'bond0_slaves_up'=2;;;0;2 'bond0_link_failures'=0c ...
+
+    check_bond
+    WARNING - bond0 (active-backup) degraded [1/2] |'bond0_slaves_up'=1;;;0;2 'bond0_link_failures'=3c
+
+Show the state of each slave:
+
+    check_bond detail-syntax="${name}: ${slave_status}"
+    OK - bond0: eth0 up 1000Mbps full, eth1 up 1000Mbps full |...
+	`,
+		exampleArgs: `warn="state = 'degraded' || link_failures > 10" crit="state = 'down'"`,
+	}
+}
+
+func (l *CheckBond) Check(_ context.Context, _ *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	for _, name := range l.listBonds() {
+		if len(l.devices) > 0 && !slices.Contains(l.devices, name) {
+			continue
+		}
+
+		entry := l.readBond(name)
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+
+		check.listData = append(check.listData, entry)
+		l.addMetrics(check, entry)
+	}
+
+	return check.Finalize()
+}
+
+// listBonds returns all bond interfaces from /proc/net/bonding and /sys/class/net/*/bonding
+func (l *CheckBond) listBonds() []string {
+	bonds := []string{}
+	if files, err := os.ReadDir(bondProcPath); err == nil {
+		for _, file := range files {
+			bonds = append(bonds, file.Name())
+		}
+	}
+
+	if matches, err := filepath.Glob(filepath.Join(bondSysNetPath, "*", "bonding")); err == nil {
+		for _, match := range matches {
+			name := filepath.Base(filepath.Dir(match))
+			if !slices.Contains(bonds, name) {
+				bonds = append(bonds, name)
+			}
+		}
+	}
+	sort.Strings(bonds)
+
+	return bonds
+}
+
+func (l *CheckBond) readBond(name string) map[string]string {
+	entry := map[string]string{
+		"name":         name,
+		"mode":         "",
+		"state":        "",
+		"mii_status":   "",
+		"active_slave": "",
+	}
+
+	slaves, err := l.parseProcBond(filepath.Join(bondProcPath, name), entry)
+	if err != nil {
+		log.Debugf("%s, using sysfs", err.Error())
+		slaves = l.readSysfsBond(name, entry)
+	}
+
+	up := 0
+	failures := int64(0)
+	status := make([]string, 0, len(slaves))
+	for _, slave := range slaves {
+		if slave.miiStatus == "up" {
+			up++
+			status = append(status, strings.TrimSpace(fmt.Sprintf("%s up %s %s", slave.name, slave.speed, slave.duplex)))
+		} else {
+			status = append(status, fmt.Sprintf("%s %s", slave.name, slave.miiStatus))
+		}
+		failures += slave.linkFailures
+	}
+
+	entry["slaves"] = fmt.Sprintf("%d", len(slaves))
+	entry["slaves_up"] = fmt.Sprintf("%d", up)
+	entry["slaves_down"] = fmt.Sprintf("%d", len(slaves)-up)
+	entry["link_failures"] = fmt.Sprintf("%d", failures)
+	entry["slave_status"] = strings.Join(status, ", ")
+
+	switch {
+	case entry["mii_status"] != "up" || up == 0:
+		entry["state"] = "down"
+	case up < len(slaves):
+		entry["state"] = "degraded"
+	default:
+		entry["state"] = "ok"
+	}
+
+	return entry
+}
+
+// parseProcBond parses /proc/net/bonding/<bond>, the bond settings are followed by one block per slave
+func (l *CheckBond) parseProcBond(file string, entry map[string]string) ([]*bondSlave, error) {
+	bondFile, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %s", file, err.Error())
+	}
+	defer bondFile.Close()
+
+	slaves := []*bondSlave{}
+	var slave *bondSlave
+	scanner := bufio.NewScanner(bondFile)
+	for scanner.Scan() {
+		key, val, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+
+		switch key {
+		case "Bonding Mode":
+			// ex.: fault-tolerance (active-backup) or IEEE 802.3ad Dynamic link aggregation
+			entry["mode"] = l.normalizeMode(val)
+		case "Currently Active Slave":
+			if val != "None" {
+				entry["active_slave"] = val
+			}
+		case "Slave Interface":
+			slave = &bondSlave{name: val, miiStatus: "down"}
+			slaves = append(slaves, slave)
+		case "MII Status":
+			if slave == nil {
+				entry["mii_status"] = val
+			} else {
+				slave.miiStatus = val
+			}
+		case "Speed":
+			if slave != nil && val != "Unknown" {
+				slave.speed = strings.ReplaceAll(val, " ", "")
+			}
+		case "Duplex":
+			if slave != nil && val != "Unknown" {
+				slave.duplex = val
+			}
+		case "Link Failure Count":
+			if slave != nil {
+				slave.linkFailures = convert.Int64(val)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %s", file, err.Error())
+	}
+
+	return slaves, nil
+}
+
+// readSysfsBond reads the bond state from /sys/class/net/<bond>/bonding, ex.: if /proc/net/bonding is not available in containers
+func (l *CheckBond) readSysfsBond(name string, entry map[string]string) []*bondSlave {
+	bondPath := filepath.Join(bondSysNetPath, name, "bonding")
+
+	// ex.: active-backup 1
+	if mode := strings.Fields(l.readSysfs(bondPath, "mode")); len(mode) > 0 {
+		entry["mode"] = mode[0]
+	}
+	entry["active_slave"] = l.readSysfs(bondPath, "active_slave")
+	entry["mii_status"] = l.readSysfs(bondPath, "mii_status")
+
+	slaves := []*bondSlave{}
+	for _, slaveName := range strings.Fields(l.readSysfs(bondPath, "slaves")) {
+		slavePath := filepath.Join(bondSysNetPath, slaveName)
+		slave := &bondSlave{
+			name:         slaveName,
+			miiStatus:    l.readSysfs(filepath.Join(slavePath, "bonding_slave"), "mii_status"),
+			duplex:       l.readSysfs(slavePath, "duplex"),
+			linkFailures: convert.Int64(l.readSysfs(filepath.Join(slavePath, "bonding_slave"), "link_failure_count")),
+		}
+		if slave.miiStatus == "" {
+			slave.miiStatus = "down"
+		}
+		// speed is -1 if unknown
+		if speed := l.readSysfs(slavePath, "speed"); convert.Int64(speed) > 0 {
+			slave.speed = speed + "Mbps"
+		}
+		if slave.duplex == "unknown" {
+			slave.duplex = ""
+		}
+		slaves = append(slaves, slave)
+	}
+
+	return slaves
+}
+
+func (l *CheckBond) readSysfs(path, name string) string {
+	data, err := os.ReadFile(filepath.Join(path, name))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// normalizeMode converts the mode description from /proc/net/bonding into the sysfs mode name
+func (l *CheckBond) normalizeMode(mode string) string {
+	if strings.Contains(mode, "802.3ad") {
+		return "802.3ad"
+	}
+
+	// ex.: fault-tolerance (active-backup)
+	if start := strings.Index(mode, "("); start >= 0 {
+		if end := strings.Index(mode[start:], ")"); end > 0 {
+			return mode[start+1 : start+end]
+		}
+	}
+
+	return mode
+}
+
+func (l *CheckBond) addMetrics(check *CheckData, entry map[string]string) {
+	slaves := convert.Float64(entry["slaves"])
+	check.result.Metrics = append(check.result.Metrics,
+		&CheckMetric{
+			Name:          entry["name"] + "_slaves_up",
+			ThresholdName: "slaves_up",
+			Value:         convert.Int64(entry["slaves_up"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+			Max:           &slaves,
+		},
+		&CheckMetric{
+			Name:          entry["name"] + "_link_failures",
+			ThresholdName: "link_failures",
+			Unit:          "c",
+			Value:         convert.Int64(entry["link_failures"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+		},
+	)
+}
@@ -0,0 +1,81 @@
+package snclient
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBond(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	origProc, origSys := bondProcPath, bondSysNetPath
+	bondProcPath = filepath.Join(tmpDir, "proc")
+	bondSysNetPath = filepath.Join(tmpDir, "sys")
+	defer func() { bondProcPath, bondSysNetPath = origProc, origSys }()
+
+	writeTestFile(t, filepath.Join(bondProcPath, "bond0"), `Ethernet Channel Bonding Driver: v6.8.0
+
+Bonding Mode: fault-tolerance (active-backup)
+Primary Slave: None
+Currently Active Slave: eth0
+MII Status: up
+MII Polling Interval (ms): 100
+Up Delay (ms): 0
+Down Delay (ms): 0
+
+Slave Interface: eth0
+MII Status: up
+Speed: 1000 Mbps
+Duplex: full
+Link Failure Count: 0
+Permanent HW addr: 52:54:00:12:34:56
+Slave queue ID: 0
+
+Slave Interface: eth1
+MII Status: down
+Speed: Unknown
+Duplex: Unknown
+Link Failure Count: 3
+Permanent HW addr: 52:54:00:12:34:57
+Slave queue ID: 0
+`)
+	writeTestFile(t, filepath.Join(bondSysNetPath, "bond0", "bonding", "mode"), "active-backup 1\n")
+
+	// bond1 is only available in sysfs
+	writeTestFile(t, filepath.Join(bondSysNetPath, "bond1", "bonding", "mode"), "802.3ad 4\n")
+	writeTestFile(t, filepath.Join(bondSysNetPath, "bond1", "bonding", "mii_status"), "up\n")
+	writeTestFile(t, filepath.Join(bondSysNetPath, "bond1", "bonding", "slaves"), "eth2 eth3\n")
+	for _, slave := range []string{"eth2", "eth3"} {
+		writeTestFile(t, filepath.Join(bondSysNetPath, slave, "bonding_slave", "mii_status"), "up\n")
+		writeTestFile(t, filepath.Join(bondSysNetPath, slave, "bonding_slave", "link_failure_count"), "1\n")
+		writeTestFile(t, filepath.Join(bondSysNetPath, slave, "speed"), "10000\n")
+		writeTestFile(t, filepath.Join(bondSysNetPath, slave, "duplex"), "full\n")
+	}
+
+	res := snc.RunCheck("check_bond", []string{})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - warning(bond0 (active-backup) degraded [1/2]) |"+
+		"'bond0_slaves_up'=1;;;0;2 'bond0_link_failures'=3c 'bond1_slaves_up'=2;;;0;2 'bond1_link_failures'=2c",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_bond", []string{"device=bond1", "ok-syntax=${list}", "detail-syntax=${name} ${mode}: ${slave_status}"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "bond1 802.3ad: eth2 up 10000Mbps full, eth3 up 10000Mbps full |'bond1_slaves_up'=2;;;0;2 'bond1_link_failures'=2c",
+		string(res.BuildPluginOutput()), "output matches")
+
+	writeTestFile(t, filepath.Join(bondSysNetPath, "eth2", "bonding_slave", "mii_status"), "down\n")
+	writeTestFile(t, filepath.Join(bondSysNetPath, "eth3", "bonding_slave", "mii_status"), "down\n")
+	writeTestFile(t, filepath.Join(bondSysNetPath, "bond1", "bonding", "mii_status"), "down\n")
+	res = snc.RunCheck("check_bond", []string{"device=bond1"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - bond1 (802.3ad) down [0/2] |'bond1_slaves_up'=0;;;0;2 'bond1_link_failures'=2c",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_bond", []string{"device=bond9"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Equalf(t, "UNKNOWN - no bond interfaces found", string(res.BuildPluginOutput()), "output matches")
+}
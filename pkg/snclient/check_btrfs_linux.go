@@ -0,0 +1,348 @@
+package snclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
+)
+
+func init() {
+	AvailableChecks["check_btrfs"] = CheckEntry{"check_btrfs", NewCheckBtrfs}
+}
+
+var (
+	btrfsMountsFile = "/proc/self/mounts"
+
+	// btrfs is executed as root if possible, so only use it from system folders
+	btrfsPaths = []string{
+		"/usr/bin/btrfs",
+		"/usr/sbin/btrfs",
+		"/sbin/btrfs",
+		"/bin/btrfs",
+	}
+
+	reBtrfsUsageGroup  = regexp.MustCompile(`^(Data|Metadata|System),\S+:\s+Size:(\d+),\s+Used:(\d+)`)
+	reBtrfsDeviceStats = regexp.MustCompile(`^\[(.+)\]\.(\w+)\s+(\d+)$`)
+
+	// unescapes octal sequences used in /proc/mounts
+	btrfsMountUnescaper = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+)
+
+var btrfsDeviceStats = []string{"write_io_errs", "read_io_errs", "flush_io_errs", "corruption_errs", "generation_errs"}
+
+type CheckBtrfs struct {
+	snc   *Agent
+	paths []string
+}
+
+func NewCheckBtrfs() CheckHandler {
+	return &CheckBtrfs{}
+}
+
+func (l *CheckBtrfs) Build() *CheckData {
+	return &CheckData{
+		name:         "check_btrfs",
+		description:  "Checks the device error counters and chunk allocation of btrfs filesystems.",
+		implemented:  Linux,
+		hasInventory: NoCallInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"path": {value: &l.paths, isFilter: true, description: "Check btrfs filesystem mounted at this path only (default: all mounted btrfs filesystems)"},
+		},
+		defaultWarning:  "errors > 0 || used_pct > 80 || ((data_used_pct > 90 || metadata_used_pct > 80) && unallocated_pct < 10)",
+		defaultCritical: "used_pct > 90 || ((data_used_pct > 95 || metadata_used_pct > 90) && unallocated_pct < 5)",
+		detailSyntax:    "${path} (used: ${used_pct}%, data: ${data_used_pct}%, metadata: ${metadata_used_pct}%, unallocated: ${unallocated_pct}%, errors: ${errors})",
+		okSyntax:        "%(status) - all %{count} btrfs filesystems are ok",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no btrfs filesystems found",
+		attributes: []CheckAttribute{
+			{name: "path", description: "Mount point of the filesystem"},
+			{name: "device", description: "Mounted device"},
+			{name: "size", description: "Total size of all devices", unit: UByte},
+			{name: "used", description: "Used bytes on all devices (including raid copies)", unit: UByte},
+			{name: "used_pct", description: "Used space in percent", unit: UPercent},
+			{name: "unallocated", description: "Bytes not yet allocated to data, metadata or system chunks", unit: UByte},
+			{name: "unallocated_pct", description: "Unallocated space in percent", unit: UPercent},
+			{name: "data_size", description: "Size of all data chunks", unit: UByte},
+			{name: "data_used", description: "Used bytes in data chunks", unit: UByte},
+			{name: "data_used_pct", description: "Usage of data chunks in percent", unit: UPercent},
+			{name: "metadata_size", description: "Size of all metadata chunks", unit: UByte},
+			{name: "metadata_used", description: "Used bytes in metadata chunks", unit: UByte},
+			{name: "metadata_used_pct", description: "Usage of metadata chunks in percent", unit: UPercent},
+			{name: "write_io_errs", description: "Sum of write errors of all devices"},
+			{name: "read_io_errs", description: "Sum of read errors of all devices"},
+			{name: "flush_io_errs", description: "Sum of flush errors of all devices"},
+			{name: "corruption_errs", description: "Sum of checksum errors of all devices"},
+			{name: "generation_errs", description: "Sum of generation errors of all devices"},
+			{name: "errors", description: "Sum of all device error counters"},
+			{name: "faulty_devices", description: "Comma separated list of devices with errors, ex.: /dev/sdb (read_io_errs:3)"},
+		},
+		exampleDefault: `
+    check_btrfs
+    OK - all 1 btrfs filesystems are ok |'/_used_pct'=30%;80;90;0;100 '/_data_used_pct'=50%;;;0;100 ...
+
+A filesystem can run out of space although df still shows free space, if all space is allocated to data chunks and the
+metadata chunks are full. Therefore the data/metadata usage is only considered critical if there is no unallocated space left.
+
+The btrfs command requires root permissions, it is run as root if snclient has the required capabilities
+or runs as root user.
+	`,
+		exampleArgs: `path=/ warn="errors > 0 || used_pct > 85"`,
+	}
+}
+
+func (l *CheckBtrfs) Check(ctx context.Context, snc *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	l.snc = snc
+
+	btrfs := l.findBtrfs()
+	if btrfs == "" {
+		return nil, fmt.Errorf("btrfs not found in %s", strings.Join(btrfsPaths, ", "))
+	}
+
+	mounts, err := l.getMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mount := range mounts {
+		if len(l.paths) > 0 && !slices.Contains(l.paths, mount[0]) {
+			continue
+		}
+
+		entry, err := l.getFilesystem(ctx, btrfs, mount[0], mount[1])
+		if err != nil {
+			entry = map[string]string{
+				"path":   mount[0],
+				"device": mount[1],
+				"_error": err.Error(),
+			}
+		}
+
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+
+		check.listData = append(check.listData, entry)
+		l.addMetrics(check, entry)
+	}
+
+	return check.Finalize()
+}
+
+func (l *CheckBtrfs) findBtrfs() string {
+	for _, path := range btrfsPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// getMounts returns path and device tuples of mounted btrfs filesystems, subvolumes of the same filesystem are returned once
+func (l *CheckBtrfs) getMounts() ([][2]string, error) {
+	file, err := os.Open(btrfsMountsFile)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %s", btrfsMountsFile, err.Error())
+	}
+	defer file.Close()
+
+	mounts := [][2]string{}
+	devices := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[2] != "btrfs" {
+			continue
+		}
+
+		device := btrfsMountUnescaper.Replace(fields[0])
+		path := btrfsMountUnescaper.Replace(fields[1])
+		if devices[device] && !slices.Contains(l.paths, path) {
+			continue
+		}
+		devices[device] = true
+		mounts = append(mounts, [2]string{path, device})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %s", btrfsMountsFile, err.Error())
+	}
+
+	return mounts, nil
+}
+
+// runBtrfs runs btrfs as root if possible and returns the output
+func (l *CheckBtrfs) runBtrfs(ctx context.Context, btrfs, args string) (string, error) {
+	command := fmt.Sprintf("%s %s", btrfs, args)
+
+	var output, stderr string
+	var exitCode int64
+	var err error
+	if os.Geteuid() == 0 || HasCapabilities() {
+		output, stderr, exitCode, err = l.snc.execCommandAsRoot(ctx, command, l.snc.getBuiltinCmdTimeout())
+	} else {
+		output, stderr, exitCode, err = l.snc.execCommand(ctx, command, l.snc.getBuiltinCmdTimeout())
+	}
+	if err != nil {
+		return "", fmt.Errorf("btrfs %s failed: %s\n%s", args, err.Error(), stderr)
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("btrfs %s failed (exit code %d): %s", args, exitCode, strings.TrimSpace(output+"\n"+stderr))
+	}
+
+	return output, nil
+}
+
+func (l *CheckBtrfs) getFilesystem(ctx context.Context, btrfs, path, device string) (map[string]string, error) {
+	if containsNastyCharacters(path, SystemCmdNastyCharacters) {
+		return nil, fmt.Errorf("path contains invalid characters: %s", path)
+	}
+
+	entry := map[string]string{
+		"path":           path,
+		"device":         device,
+		"faulty_devices": "",
+	}
+
+	usage, err := l.runBtrfs(ctx, btrfs, fmt.Sprintf("filesystem usage -b %q", path))
+	if err != nil {
+		return nil, err
+	}
+	l.parseUsage(entry, usage)
+
+	stats, err := l.runBtrfs(ctx, btrfs, fmt.Sprintf("device stats %q", path))
+	if err != nil {
+		return nil, err
+	}
+	l.parseDeviceStats(entry, stats)
+
+	return entry, nil
+}
+
+// parseUsage parses the output of btrfs filesystem usage -b
+func (l *CheckBtrfs) parseUsage(entry map[string]string, output string) {
+	overall := map[string]float64{}
+	groups := map[string][2]float64{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if matches := reBtrfsUsageGroup.FindStringSubmatch(line); matches != nil {
+			groups[strings.ToLower(matches[1])] = [2]float64{convert.Float64(matches[2]), convert.Float64(matches[3])}
+
+			continue
+		}
+
+		// ex.: Device unallocated:   9663676416
+		key, val, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(val)
+		if len(fields) == 0 {
+			continue
+		}
+		if num, err := convert.Float64E(fields[0]); err == nil {
+			overall[key] = num
+		}
+	}
+
+	size := overall["Device size"]
+	entry["size"] = fmt.Sprintf("%.0f", size)
+	entry["used"] = fmt.Sprintf("%.0f", overall["Used"])
+	entry["unallocated"] = fmt.Sprintf("%.0f", overall["Device unallocated"])
+	entry["used_pct"] = l.percent(overall["Used"], size)
+	entry["unallocated_pct"] = l.percent(overall["Device unallocated"], size)
+
+	for _, name := range []string{"data", "metadata"} {
+		group, ok := groups[name]
+		entry[name+"_size"] = fmt.Sprintf("%.0f", group[0])
+		entry[name+"_used"] = fmt.Sprintf("%.0f", group[1])
+		entry[name+"_used_pct"] = ""
+		if ok {
+			entry[name+"_used_pct"] = l.percent(group[1], group[0])
+		}
+	}
+}
+
+func (l *CheckBtrfs) percent(val, total float64) string {
+	if total <= 0 {
+		return "0"
+	}
+
+	return fmt.Sprintf("%.1f", val*100/total)
+}
+
+// parseDeviceStats parses the output of btrfs device stats, ex.: [/dev/sda1].write_io_errs    0
+func (l *CheckBtrfs) parseDeviceStats(entry map[string]string, output string) {
+	sums := map[string]int64{}
+	devices := []string{}
+	deviceErrors := map[string][]string{}
+	for _, line := range strings.Split(output, "\n") {
+		matches := reBtrfsDeviceStats.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+
+		device, name, value := matches[1], matches[2], convert.Int64(matches[3])
+		sums[name] += value
+		if !slices.Contains(devices, device) {
+			devices = append(devices, device)
+		}
+		if value > 0 {
+			deviceErrors[device] = append(deviceErrors[device], fmt.Sprintf("%s:%d", name, value))
+		}
+	}
+
+	var total int64
+	for _, name := range btrfsDeviceStats {
+		entry[name] = fmt.Sprintf("%d", sums[name])
+		total += sums[name]
+	}
+	entry["errors"] = fmt.Sprintf("%d", total)
+
+	faulty := []string{}
+	for _, device := range devices {
+		if len(deviceErrors[device]) > 0 {
+			faulty = append(faulty, fmt.Sprintf("%s (%s)", device, strings.Join(deviceErrors[device], " ")))
+		}
+	}
+	entry["faulty_devices"] = strings.Join(faulty, ", ")
+}
+
+func (l *CheckBtrfs) addMetrics(check *CheckData, entry map[string]string) {
+	for _, name := range []string{"used_pct", "data_used_pct", "metadata_used_pct", "unallocated_pct"} {
+		if entry[name] == "" {
+			continue
+		}
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["path"] + "_" + name,
+			ThresholdName: name,
+			Unit:          "%",
+			Value:         convert.Float64(entry[name]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+			Max:           &Hundred,
+		})
+	}
+
+	if entry["errors"] != "" {
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["path"] + "_errors",
+			ThresholdName: "errors",
+			Value:         convert.Int64(entry["errors"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		})
+	}
+}
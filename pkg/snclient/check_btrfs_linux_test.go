@@ -0,0 +1,116 @@
+package snclient
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorded (and shortened) output of: btrfs filesystem usage -b /
+const btrfsUsageRoot = `Overall:
+    Device size:                  21474836480
+    Device allocated:             11811160064
+    Device unallocated:            9663676416
+    Device missing:                         0
+    Device slack:                           0
+    Used:                          6442450944
+    Free (estimated):             14763950080      (min: 9931866112)
+    Free (statfs, df):            14763950080
+    Data ratio:                          1.00
+    Metadata ratio:                      2.00
+    Global reserve:                  16777216      (used: 0)
+    Multiple profiles:                     no
+
+Data,single: Size:10737418240, Used:5368709120 (50.00%)
+   /dev/sda2   10737418240
+
+Metadata,DUP: Size:536870912, Used:268435456 (50.00%)
+   /dev/sda2   1073741824
+
+System,DUP: Size:8388608, Used:16384 (0.20%)
+   /dev/sda2     16777216
+
+Unallocated:
+   /dev/sda2   9663676416`
+
+// recorded (and shortened) output of: btrfs filesystem usage -b /data
+const btrfsUsageData = `Overall:
+    Device size:                 107374182400
+    Device allocated:            107374182400
+    Device unallocated:                     0
+    Used:                         75161927680
+
+Data,RAID1: Size:52613349376, Used:36507222016 (69.39%)
+   /dev/sdb    52613349376
+   /dev/sdc    52613349376
+
+Metadata,RAID1: Size:1073741824, Used:1046478848 (97.46%)
+   /dev/sdb     1073741824
+   /dev/sdc     1073741824`
+
+const btrfsStatsRoot = `[/dev/sda2].write_io_errs    0
+[/dev/sda2].read_io_errs     0
+[/dev/sda2].flush_io_errs    0
+[/dev/sda2].corruption_errs  0
+[/dev/sda2].generation_errs  0`
+
+const btrfsStatsData = `[/dev/sdb].write_io_errs    0
+[/dev/sdb].read_io_errs     0
+[/dev/sdb].flush_io_errs    0
+[/dev/sdb].corruption_errs  0
+[/dev/sdb].generation_errs  0
+[/dev/sdc].write_io_errs    2
+[/dev/sdc].read_io_errs     3
+[/dev/sdc].flush_io_errs    0
+[/dev/sdc].corruption_errs  0
+[/dev/sdc].generation_errs  0`
+
+func TestCheckBtrfs(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	origMounts, origPaths := btrfsMountsFile, btrfsPaths
+	btrfsMountsFile = filepath.Join(tmpDir, "mounts")
+	defer func() { btrfsMountsFile, btrfsPaths = origMounts, origPaths }()
+
+	// /home is a subvolume of the root filesystem and checked only once
+	writeTestFile(t, btrfsMountsFile, `/dev/sda2 / btrfs rw,relatime,ssd,space_cache=v2,subvolid=256,subvol=/@ 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda2 /home btrfs rw,relatime,ssd,space_cache=v2,subvolid=257,subvol=/@home 0 0
+/dev/sdb /data btrfs rw,relatime,space_cache=v2,subvolid=5,subvol=/ 0 0
+/dev/sda1 /boot ext4 rw,relatime 0 0
+`)
+
+	btrfs := MockSystemUtilityArgs(t, "btrfs", 0, [][2]string{
+		{"filesystem usage -b /data", btrfsUsageData},
+		{"filesystem usage -b /", btrfsUsageRoot},
+		{"device stats /data", btrfsStatsData},
+		{"device stats /", btrfsStatsRoot},
+	})
+	btrfsPaths = []string{btrfs}
+
+	res := snc.RunCheck("check_btrfs", []string{"path=/"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - all 1 btrfs filesystems are ok |"+
+		"'/_used_pct'=30%;80;90;0;100 '/_data_used_pct'=50%;90;95;0;100 '/_metadata_used_pct'=50%;80;90;0;100 "+
+		"'/_unallocated_pct'=45%;10:;5:;0;100 '/_errors'=0;0;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	// metadata is full and nothing left to allocate, although data chunks still have space
+	res = snc.RunCheck("check_btrfs", []string{})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()),
+		"CRITICAL - critical(/data (used: 70.0%, data: 69.4%, metadata: 97.5%, unallocated: 0.0%, errors: 5)) |",
+		"output matches")
+	assert.NotContainsf(t, string(res.BuildPluginOutput()), "/home", "subvolume is skipped")
+
+	res = snc.RunCheck("check_btrfs", []string{"path=/data", "crit=none", "detail-syntax=${path} ${faulty_devices}"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "WARNING - /data /dev/sdc (write_io_errs:2 read_io_errs:3) |", "output matches")
+
+	res = snc.RunCheck("check_btrfs", []string{"path=/boot"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "UNKNOWN - no btrfs filesystems found", "output matches")
+}
@@ -0,0 +1,679 @@
+package snclient
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec // sha1 is used by the jks format itself
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/consol-monitoring/snclient/pkg/convert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func init() {
+	AvailableChecks["check_certificate"] = CheckEntry{"check_certificate", NewCheckCertificate}
+}
+
+const (
+	jksMagic   = 0xFEEDFEED
+	jceksMagic = 0xCECECECE
+
+	jksTagPrivateKey  = 1
+	jksTagTrustedCert = 2
+)
+
+// oidJKSKeyProtector is the sun proprietary key protection algorithm used for private keys in jks files
+var oidJKSKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+type CheckCertificate struct {
+	snc      *Agent
+	paths    []string
+	pathList CommaStringList
+	password string
+
+	// keyFiles contains the configured key file for certificates taken from the agent configuration
+	keyFiles map[string]string
+}
+
+// certificateBundle contains certificates and private keys which belong together, ex.: a pem file or a jks key entry
+type certificateBundle struct {
+	alias  string
+	format string
+	certs  []*x509.Certificate
+	keys   []crypto.PrivateKey
+}
+
+func NewCheckCertificate() CheckHandler {
+	return &CheckCertificate{
+		pathList: CommaStringList{},
+		keyFiles: map[string]string{},
+	}
+}
+
+func (l *CheckCertificate) Build() *CheckData {
+	return &CheckData{
+		name:         "check_certificate",
+		description:  "Checks certificates from local pem, der, pkcs#12 and jks files.",
+		implemented:  ALL,
+		hasInventory: NoInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"path":     {value: &l.paths, description: "Path or glob pattern (ex.: /etc/ssl/**/*.crt) of certificate files (default: certificates from the agent configuration)", isFilter: true},
+			"file":     {value: &l.paths, description: "Alias for path", isFilter: true},
+			"paths":    {value: &l.pathList, description: "A comma separated list of paths", isFilter: true},
+			"password": {value: &l.password, description: "Password for pkcs#12 and jks files"},
+		},
+		defaultWarning:  "days_left < 30",
+		defaultCritical: "days_left < 14 || key_match = 0 || error != ''",
+		detailSyntax:    "${file}: {{ IF error != '' }}${error}{{ ELSE }}${name} expires in ${days_left} days{{ END }}",
+		okSyntax:        "%(status) - all %{count} certificates are ok",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no certificates found",
+		attributes: []CheckAttribute{
+			{name: "file", description: "Path to the certificate file"},
+			{name: "format", description: "File format: pem, der, pkcs12 or jks"},
+			{name: "index", description: "Position of the certificate in the file, starting at 0"},
+			{name: "alias", description: "Alias of the jks entry"},
+			{name: "name", description: "Alias or common name of the certificate"},
+			{name: "subject", description: "Subject of the certificate"},
+			{name: "issuer", description: "Issuer of the certificate"},
+			{name: "sans", description: "Comma separated list of subject alternative names"},
+			{name: "serial", description: "Serial number as hex string"},
+			{name: "fingerprint", description: "SHA256 fingerprint as hex string"},
+			{name: "is_ca", description: "Certificate is a ca certificate: 0 / 1"},
+			{name: "not_before", description: "Unix timestamp of the start of the validity", unit: UDate},
+			{name: "not_after", description: "Unix timestamp of the end of the validity", unit: UDate},
+			{name: "days_left", description: "Number of days until the certificate expires"},
+			{name: "key_type", description: "Public key type: RSA, ECDSA or Ed25519"},
+			{name: "key_size", description: "Public key size in bits"},
+			{name: "key_match", description: "Private key matches the certificate: 0 / 1 (empty if no private key was found)"},
+			{name: "error", description: "Error message if the file cannot be read, all other attributes are empty in that case"},
+		},
+		exampleDefault: `
+Without arguments the certificates from the agent configuration are checked:
+
+    check_certificate
+    OK - all 1 certificates are ok |'server.crt snclient days_left'=3648;30:;14:
+
+Check all certificates below /etc/pki, the private key is taken from the same file or a .key file with the same name:
+
+    check_certificate path="/etc/pki/**/*.crt" path="/etc/pki/**/*.pem"
+    WARNING - warning(/etc/pki/tls/certs/web.crt: web.example.com expires in 21 days) |...
+
+Java keystores and pkcs#12 files need a password to read the private key:
+
+    check_certificate path=/opt/app/keystore.jks password=changeit
+
+Certificates from jks files can be read without password, the key_match is empty in that case.
+	`,
+		exampleArgs: `path="/etc/ssl/private/*.pem" warn="days_left < 30" crit="days_left < 14 || key_match = 0"`,
+	}
+}
+
+func (l *CheckCertificate) Check(_ context.Context, snc *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	l.snc = snc
+	l.paths = append(l.paths, l.pathList...)
+
+	files := []string{}
+	if len(l.paths) == 0 {
+		files = l.defaultCertificates()
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no certificate found in the agent configuration, use path=... to specify certificate files")
+		}
+	}
+
+	for _, pattern := range l.paths {
+		matches, err := doublestar.FilepathGlob(pattern, doublestar.WithFilesOnly())
+		if err != nil {
+			return nil, fmt.Errorf("could not get files for pattern %s: %s", pattern, err.Error())
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files found for search pattern: '%s'", pattern)
+		}
+		files = append(files, matches...)
+	}
+
+	slices.Sort(files)
+	files = slices.Compact(files)
+
+	// unreadable files are added as entry with an error, so they neither hide other certificates nor disappear silently
+	for _, file := range files {
+		bundles, err := l.readFile(file)
+		if err != nil {
+			l.addError(check, file, err)
+
+			continue
+		}
+
+		l.addBundles(check, file, bundles)
+	}
+
+	if check.HasThreshold("days_left") {
+		for _, entry := range check.listData {
+			if entry["error"] != "" {
+				continue
+			}
+			check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+				ThresholdName: "days_left",
+				Name:          filepath.Base(entry["file"]) + " " + entry["name"] + " days_left",
+				Value:         convert.Int64(entry["days_left"]),
+				Warning:       check.warnThreshold,
+				Critical:      check.critThreshold,
+			})
+		}
+	}
+
+	return check.Finalize()
+}
+
+// defaultCertificates returns the existing certificate files from the agent configuration
+func (l *CheckCertificate) defaultCertificates() []string {
+	sections := []string{"/settings/default"}
+	for _, listener := range AvailableListeners {
+		sections = append(sections, listener.ConfigKey)
+	}
+
+	files := []string{}
+	for _, name := range sections {
+		section := l.snc.config.Section(name)
+		certFile, ok := section.GetString("certificate")
+		if !ok || certFile == "" {
+			continue
+		}
+		if _, err := os.Stat(certFile); err != nil {
+			log.Tracef("skipping certificate %s from %s: %s", certFile, name, err.Error())
+
+			continue
+		}
+
+		if keyFile, ok := section.GetString("certificate key"); ok && keyFile != "" {
+			l.keyFiles[certFile] = keyFile
+		}
+		files = append(files, certFile)
+	}
+
+	return files
+}
+
+// readFile detects the file format and returns all certificates contained
+func (l *CheckCertificate) readFile(file string) ([]*certificateBundle, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file: %s", err.Error())
+	}
+
+	ext := strings.ToLower(filepath.Ext(file))
+
+	switch {
+	case len(data) >= 4 && (binary.BigEndian.Uint32(data) == jksMagic || binary.BigEndian.Uint32(data) == jceksMagic):
+		return readJKS(data, l.password)
+	case ext == ".p12" || ext == ".pfx":
+		return readPKCS12(data, l.password)
+	case bytes.Contains(data, []byte("-----BEGIN")):
+		bundle := readPEMBlocks(data, "pem")
+		l.addKeyFile(file, bundle)
+
+		return []*certificateBundle{bundle}, nil
+	}
+
+	certs, err := x509.ParseCertificates(data)
+	if err != nil {
+		// might be a pkcs#12 file without the usual extension
+		if bundles, p12Err := readPKCS12(data, l.password); p12Err == nil {
+			return bundles, nil
+		}
+
+		return nil, fmt.Errorf("unknown certificate format: %s", err.Error())
+	}
+
+	bundle := &certificateBundle{format: "der", certs: certs}
+	l.addKeyFile(file, bundle)
+
+	return []*certificateBundle{bundle}, nil
+}
+
+// addKeyFile adds the private key from the configured key file or a .key file next to the certificate
+func (l *CheckCertificate) addKeyFile(file string, bundle *certificateBundle) {
+	if len(bundle.keys) > 0 || len(bundle.certs) == 0 {
+		return
+	}
+
+	keyFile, ok := l.keyFiles[file]
+	if !ok {
+		keyFile = strings.TrimSuffix(file, filepath.Ext(file)) + ".key"
+		if keyFile == file {
+			return
+		}
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		log.Tracef("no private key for %s: %s", file, err.Error())
+
+		return
+	}
+
+	keys := readPEMBlocks(data, "pem").keys
+	if len(keys) == 0 {
+		if key, err := parsePrivateKey("PRIVATE KEY", data); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	bundle.keys = append(bundle.keys, keys...)
+}
+
+func (l *CheckCertificate) addBundles(check *CheckData, file string, bundles []*certificateBundle) {
+	index := 0
+	for _, bundle := range bundles {
+		keyMatch := make([]string, len(bundle.certs))
+		if len(bundle.keys) > 0 {
+			matched := false
+			for i, cert := range bundle.certs {
+				for _, key := range bundle.keys {
+					if privateKeyMatches(cert, key) {
+						keyMatch[i] = "1"
+						matched = true
+					}
+				}
+			}
+			// the first certificate is expected to be the one belonging to the key
+			if !matched && len(bundle.certs) > 0 {
+				keyMatch[0] = "0"
+			}
+		}
+
+		for i, cert := range bundle.certs {
+			entry := l.buildEntry(file, bundle, cert)
+			entry["index"] = fmt.Sprintf("%d", index)
+			entry["key_match"] = keyMatch[i]
+			index++
+
+			if !check.MatchMapCondition(check.filter, entry, true) {
+				continue
+			}
+			check.listData = append(check.listData, entry)
+		}
+	}
+}
+
+// addError adds an entry for a file which cannot be read
+func (l *CheckCertificate) addError(check *CheckData, file string, err error) {
+	entry := map[string]string{
+		"file":  file,
+		"name":  filepath.Base(file),
+		"error": err.Error(),
+	}
+	for _, attribute := range check.attributes {
+		if _, ok := entry[attribute.name]; !ok {
+			entry[attribute.name] = ""
+		}
+	}
+
+	if !check.MatchMapCondition(check.filter, entry, true) {
+		return
+	}
+	check.listData = append(check.listData, entry)
+}
+
+func (l *CheckCertificate) buildEntry(file string, bundle *certificateBundle, cert *x509.Certificate) map[string]string {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	sans := []string{}
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	name := bundle.alias
+	if name == "" {
+		name = cert.Subject.CommonName
+	}
+	if name == "" {
+		name = cert.SerialNumber.Text(16)
+	}
+
+	isCA := "0"
+	if cert.IsCA {
+		isCA = "1"
+	}
+
+	entry := map[string]string{
+		"file":        file,
+		"format":      bundle.format,
+		"alias":       bundle.alias,
+		"name":        name,
+		"subject":     cert.Subject.String(),
+		"issuer":      cert.Issuer.String(),
+		"sans":        strings.Join(sans, ", "),
+		"serial":      cert.SerialNumber.Text(16),
+		"fingerprint": hex.EncodeToString(fingerprint[:]),
+		"is_ca":       isCA,
+		"not_before":  fmt.Sprintf("%d", cert.NotBefore.Unix()),
+		"not_after":   fmt.Sprintf("%d", cert.NotAfter.Unix()),
+		"days_left":   fmt.Sprintf("%d", int64(time.Until(cert.NotAfter).Hours()/24)),
+		"error":       "",
+	}
+	entry["key_type"], entry["key_size"] = publicKeyInfo(cert)
+
+	return entry
+}
+
+// readPEMBlocks returns all certificates and unencrypted private keys from pem data
+func readPEMBlocks(data []byte, format string) *certificateBundle {
+	bundle := &certificateBundle{format: format}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "CERTIFICATE", "TRUSTED CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				log.Debugf("skipping invalid certificate: %s", err.Error())
+
+				continue
+			}
+			bundle.certs = append(bundle.certs, cert)
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			key, err := parsePrivateKey(block.Type, block.Bytes)
+			if err != nil {
+				log.Debugf("skipping invalid private key: %s", err.Error())
+
+				continue
+			}
+			bundle.keys = append(bundle.keys, key)
+		}
+	}
+
+	return bundle
+}
+
+// readPKCS12 returns certificates and keys from pkcs#12 data, files without private key are read as trust store
+func readPKCS12(data []byte, password string) ([]*certificateBundle, error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		certs, trustErr := pkcs12.DecodeTrustStore(data, password)
+		if trustErr != nil {
+			return nil, fmt.Errorf("cannot decode pkcs#12 file: %s", err.Error())
+		}
+
+		return []*certificateBundle{{format: "pkcs12", certs: certs}}, nil
+	}
+
+	bundle := &certificateBundle{format: "pkcs12", certs: append([]*x509.Certificate{cert}, caCerts...)}
+	if key != nil {
+		bundle.keys = append(bundle.keys, key)
+	}
+
+	return []*certificateBundle{bundle}, nil
+}
+
+// readJKS returns all certificates from a java keystore. Certificates are stored unencrypted,
+// the password is only required to decrypt private keys.
+func readJKS(data []byte, password string) ([]*certificateBundle, error) {
+	reader := bytes.NewReader(data)
+
+	var header struct {
+		Magic   uint32
+		Version uint32
+		Count   uint32
+	}
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("invalid keystore header: %s", err.Error())
+	}
+
+	if header.Version != 1 && header.Version != 2 {
+		return nil, fmt.Errorf("unsupported keystore version: %d", header.Version)
+	}
+
+	bundles := []*certificateBundle{}
+	for range header.Count {
+		var tag uint32
+		if err := binary.Read(reader, binary.BigEndian, &tag); err != nil {
+			return nil, fmt.Errorf("invalid keystore entry: %s", err.Error())
+		}
+
+		alias, err := readJKSString(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		// skip creation timestamp
+		if _, err = reader.Seek(8, io.SeekCurrent); err != nil {
+			return nil, fmt.Errorf("invalid keystore entry: %s", err.Error())
+		}
+
+		bundle := &certificateBundle{alias: alias, format: "jks"}
+		switch tag {
+		case jksTagPrivateKey:
+			encryptedKey, err := readJKSBytes(reader)
+			if err != nil {
+				return nil, err
+			}
+
+			var chainLength uint32
+			if err = binary.Read(reader, binary.BigEndian, &chainLength); err != nil {
+				return nil, fmt.Errorf("invalid keystore entry: %s", err.Error())
+			}
+			for range chainLength {
+				cert, err := readJKSCertificate(reader, header.Version)
+				if err != nil {
+					return nil, err
+				}
+				bundle.certs = append(bundle.certs, cert)
+			}
+
+			if password != "" && header.Magic == jksMagic {
+				key, err := decryptJKSKey(encryptedKey, password)
+				if err != nil {
+					return nil, fmt.Errorf("cannot decrypt private key %s: %s", alias, err.Error())
+				}
+				bundle.keys = append(bundle.keys, key)
+			}
+		case jksTagTrustedCert:
+			cert, err := readJKSCertificate(reader, header.Version)
+			if err != nil {
+				return nil, err
+			}
+			bundle.certs = append(bundle.certs, cert)
+		default:
+			// jceks secret keys are java serialized objects which cannot be skipped
+			return nil, fmt.Errorf("unsupported keystore entry type %d for %s", tag, alias)
+		}
+
+		bundles = append(bundles, bundle)
+	}
+
+	return bundles, nil
+}
+
+func readJKSString(reader *bytes.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return "", fmt.Errorf("invalid keystore entry: %s", err.Error())
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", fmt.Errorf("invalid keystore entry: %s", err.Error())
+	}
+
+	return string(buf), nil
+}
+
+func readJKSBytes(reader *bytes.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return nil, fmt.Errorf("invalid keystore entry: %s", err.Error())
+	}
+	if int64(length) > int64(reader.Len()) {
+		return nil, fmt.Errorf("invalid keystore entry: length %d exceeds file size", length)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return nil, fmt.Errorf("invalid keystore entry: %s", err.Error())
+	}
+
+	return buf, nil
+}
+
+func readJKSCertificate(reader *bytes.Reader, version uint32) (*x509.Certificate, error) {
+	if version == 2 {
+		certType, err := readJKSString(reader)
+		if err != nil {
+			return nil, err
+		}
+		if certType != "X.509" {
+			return nil, fmt.Errorf("unsupported certificate type in keystore: %s", certType)
+		}
+	}
+
+	data, err := readJKSBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate in keystore: %s", err.Error())
+	}
+
+	return cert, nil
+}
+
+// decryptJKSKey decrypts a private key protected by the sun jks key protector:
+// salt (20 bytes) + key xor'ed with a sha1 key stream + sha1 checksum (20 bytes)
+func decryptJKSKey(data []byte, password string) (crypto.PrivateKey, error) {
+	var info struct {
+		Algorithm pkix.AlgorithmIdentifier
+		Data      []byte
+	}
+	if _, err := asn1.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("invalid encrypted key: %s", err.Error())
+	}
+
+	if !info.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+		return nil, fmt.Errorf("unsupported key protection algorithm: %s", info.Algorithm.Algorithm.String())
+	}
+
+	if len(info.Data) < 2*sha1.Size {
+		return nil, errors.New("encrypted key too short")
+	}
+
+	passwordBytes := jksPassword(password)
+	salt := info.Data[:sha1.Size]
+	encrypted := info.Data[sha1.Size : len(info.Data)-sha1.Size]
+	checksum := info.Data[len(info.Data)-sha1.Size:]
+
+	plain := make([]byte, len(encrypted))
+	stream := salt
+	for offset := 0; offset < len(encrypted); offset += sha1.Size {
+		hash := sha1.New() //nolint:gosec // required by the jks format
+		hash.Write(passwordBytes)
+		hash.Write(stream)
+		stream = hash.Sum(nil)
+
+		for i := 0; i < sha1.Size && offset+i < len(encrypted); i++ {
+			plain[offset+i] = encrypted[offset+i] ^ stream[i]
+		}
+	}
+
+	hash := sha1.New() //nolint:gosec // required by the jks format
+	hash.Write(passwordBytes)
+	hash.Write(plain)
+	if subtle.ConstantTimeCompare(hash.Sum(nil), checksum) != 1 {
+		return nil, errors.New("wrong password")
+	}
+
+	return parsePrivateKey("PRIVATE KEY", plain)
+}
+
+// jksPassword returns the password as utf-16 big endian bytes like java does
+func jksPassword(password string) []byte {
+	buf := []byte{}
+	for _, char := range utf16.Encode([]rune(password)) {
+		buf = append(buf, byte(char>>8), byte(char))
+	}
+
+	return buf
+}
+
+func parsePrivateKey(blockType string, data []byte) (crypto.PrivateKey, error) {
+	var key crypto.PrivateKey
+	var err error
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(data)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(data)
+	default:
+		// pkcs#12 keys are converted into pkcs#1 / sec1 but still use the generic block type
+		key, err = x509.ParsePKCS8PrivateKey(data)
+		if err != nil {
+			if rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(data); rsaErr == nil {
+				key, err = rsaKey, nil
+			} else if ecKey, ecErr := x509.ParseECPrivateKey(data); ecErr == nil {
+				key, err = ecKey, nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse private key: %s", err.Error())
+	}
+
+	return key, nil
+}
+
+// privateKeyMatches returns true if the private key belongs to the certificate public key
+func privateKeyMatches(cert *x509.Certificate, key crypto.PrivateKey) bool {
+	var public crypto.PublicKey
+	switch privateKey := key.(type) {
+	case *rsa.PrivateKey:
+		public = privateKey.Public()
+	case *ecdsa.PrivateKey:
+		public = privateKey.Public()
+	case ed25519.PrivateKey:
+		public = privateKey.Public()
+	default:
+		return false
+	}
+
+	certKey, ok := cert.PublicKey.(interface{ Equal(x crypto.PublicKey) bool })
+	if !ok {
+		return false
+	}
+
+	return certKey.Equal(public)
+}
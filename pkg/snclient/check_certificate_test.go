@@ -0,0 +1,218 @@
+package snclient
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // sha1 is used by the jks format itself
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestCheckCertificate(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	testDir := t.TempDir()
+
+	caKey, caCert := testCertificate(t, "Test CA", 365*24*time.Hour, nil, nil)
+	leafKey, leafCert := testCertificate(t, "leaf.example.com", 20*24*time.Hour+time.Hour, caCert, caKey)
+	otherKey, _ := testCertificate(t, "other.example.com", time.Hour, nil, nil)
+
+	bundle := testPEM(t, "CERTIFICATE", leafCert.Raw) + testPEM(t, "CERTIFICATE", caCert.Raw) + testPrivateKeyPEM(t, leafKey)
+	writeTestCertificateFile(t, filepath.Join(testDir, "bundle.pem"), []byte(bundle))
+	writeTestCertificateFile(t, filepath.Join(testDir, "web.crt"), []byte(testPEM(t, "CERTIFICATE", leafCert.Raw)))
+	writeTestCertificateFile(t, filepath.Join(testDir, "web.key"), []byte(testPrivateKeyPEM(t, otherKey)))
+	writeTestCertificateFile(t, filepath.Join(testDir, "ca", "ca.der"), caCert.Raw)
+	writeTestCertificateFile(t, filepath.Join(testDir, "keystore.jks"), testJKS(t, "changeit", leafKey, leafCert, caCert))
+
+	p12, err := pkcs12.Modern.Encode(leafKey, leafCert, []*x509.Certificate{caCert}, "changeit")
+	require.NoErrorf(t, err, "pkcs12 encoded")
+	writeTestCertificateFile(t, filepath.Join(testDir, "store", "keystore.p12"), p12)
+	trustStore, err := pkcs12.Modern.EncodeTrustStore([]*x509.Certificate{caCert}, "changeit")
+	require.NoErrorf(t, err, "pkcs12 truststore encoded")
+	writeTestCertificateFile(t, filepath.Join(testDir, "store", "truststore.p12"), trustStore)
+	writeTestCertificateFile(t, filepath.Join(testDir, "store", "broken.p12"), []byte("garbage"))
+
+	res := snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "bundle.pem")})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Regexpf(t, `^WARNING - warning\(.*bundle.pem: leaf.example.com expires in 20 days\) \|'bundle.pem leaf.example.com days_left'=20;30:;14: 'bundle.pem Test CA days_left'=364;30:;14:$`,
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "bundle.pem"), "warn=none", "crit=key_match = 1"})
+	assert.Equalf(t, CheckExitCritical, res.State, "private key matches the leaf certificate")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "critical(", "output matches")
+	assert.NotContainsf(t, string(res.BuildPluginOutput()), "Test CA expires", "ca certificate has no key_match")
+
+	res = snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "*.crt")})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Regexpf(t, `^CRITICAL - .*web.crt: leaf.example.com expires in 20 days \|`,
+		string(res.BuildPluginOutput()), "key from web.key does not match")
+
+	res = snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "**", "*.der"), "warn=none",
+		"crit=format = der and is_ca = 1 and key_type = ECDSA and key_size = 256 and subject = 'CN=Test CA'"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "ca.der: Test CA expires in 364 days", "output matches")
+
+	res = snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "keystore.jks"), "warn=none", "crit=none"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - all 3 certificates are ok", string(res.BuildPluginOutput()), "jks certificates can be read without password")
+
+	res = snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "keystore.jks"), "password=changeit", "warn=none", "crit=key_match = 1",
+		"detail-syntax=${format} ${alias} ${index} ${sans}"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - critical(jks leaf 0 leaf.example.com)", string(res.BuildPluginOutput()), "jks private key matches")
+
+	res = snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "keystore.jks"), "password=wrong"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "keystore.jks: cannot decrypt private key leaf: wrong password", "output matches")
+
+	res = snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "store", "keystore.p12"), "password=changeit", "warn=none", "crit=key_match = 1",
+		"detail-syntax=${format} ${index} ${sans}"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - critical(pkcs12 0 leaf.example.com)", string(res.BuildPluginOutput()), "modern pkcs12 private key matches")
+
+	res = snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "store", "*.p12"), "password=changeit", "warn=none"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Regexpf(t, `^CRITICAL - critical\(.*broken.p12: cannot decode pkcs#12 file: .*\) \|'keystore.p12 leaf.example.com days_left'=20;;14: 'keystore.p12 Test CA days_left'=364;;14: 'truststore.p12 Test CA days_left'=364;;14:$`,
+		string(res.BuildPluginOutput()), "broken file is reported along with the other certificates")
+
+	res = snc.RunCheck("check_certificate", []string{"path=" + filepath.Join(testDir, "missing.pem")})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "no files found for search pattern", "output matches")
+}
+
+func testCertificate(t *testing.T, name string, lifetime time.Duration, parent *x509.Certificate, parentKey crypto.Signer) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(lifetime),
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		template.IsCA = true
+		parent = template
+		parentKey = key
+	} else {
+		template.DNSNames = []string{name}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return key, cert
+}
+
+func testPEM(t *testing.T, blockType string, data []byte) string {
+	t.Helper()
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}))
+}
+
+func testPrivateKeyPEM(t *testing.T, key crypto.PrivateKey) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return testPEM(t, "PRIVATE KEY", der)
+}
+
+func writeTestCertificateFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+// testJKS creates a jks keystore with a private key entry "leaf" and a trusted certificate entry "ca"
+func testJKS(t *testing.T, password string, key crypto.PrivateKey, leaf, ca *x509.Certificate) []byte {
+	t.Helper()
+
+	plain, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	// protect the key like the sun jks key protector does
+	passwordBytes := jksPassword(password)
+	salt := make([]byte, sha1.Size)
+	_, err = rand.Read(salt)
+	require.NoError(t, err)
+
+	protected := append([]byte{}, salt...)
+	stream := salt
+	for offset := 0; offset < len(plain); offset += sha1.Size {
+		hash := sha1.New() //nolint:gosec // required by the jks format
+		hash.Write(passwordBytes)
+		hash.Write(stream)
+		stream = hash.Sum(nil)
+		for i := 0; i < sha1.Size && offset+i < len(plain); i++ {
+			protected = append(protected, plain[offset+i]^stream[i])
+		}
+	}
+	checksum := sha1.Sum(append(passwordBytes, plain...)) //nolint:gosec // required by the jks format
+	protected = append(protected, checksum[:]...)
+
+	encryptedKey, err := asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		Data      []byte
+	}{pkix.AlgorithmIdentifier{Algorithm: oidJKSKeyProtector, Parameters: asn1.NullRawValue}, protected})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	write := func(data any) {
+		require.NoError(t, binary.Write(buf, binary.BigEndian, data))
+	}
+	writeString := func(str string) {
+		write(uint16(len(str)))
+		buf.WriteString(str)
+	}
+	writeCert := func(cert *x509.Certificate) {
+		writeString("X.509")
+		write(uint32(len(cert.Raw)))
+		buf.Write(cert.Raw)
+	}
+
+	write([]uint32{jksMagic, 2, 2})
+
+	write(uint32(jksTagPrivateKey))
+	writeString("leaf")
+	write(time.Now().UnixMilli())
+	write(uint32(len(encryptedKey)))
+	buf.Write(encryptedKey)
+	write(uint32(2))
+	writeCert(leaf)
+	writeCert(ca)
+
+	write(uint32(jksTagTrustedCert))
+	writeString("ca")
+	write(time.Now().UnixMilli())
+	writeCert(ca)
+
+	// keystore integrity checksum, not verified by the check
+	buf.Write(make([]byte, sha1.Size))
+
+	return buf.Bytes()
+}
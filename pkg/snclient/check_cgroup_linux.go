@@ -0,0 +1,440 @@
+package snclient
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/consol-monitoring/snclient/pkg/convert"
+	"github.com/consol-monitoring/snclient/pkg/utils"
+)
+
+func init() {
+	AvailableChecks["check_cgroup"] = CheckEntry{"check_cgroup", NewCheckCgroup}
+}
+
+const (
+	CgroupRateDuration = 30 * time.Second
+)
+
+// cgroupPressureResources contains the resources with pressure stall information
+var cgroupPressureResources = []string{"cpu", "memory", "io"}
+
+type CheckCgroup struct {
+	snc   *Agent
+	paths []string
+	units []string
+}
+
+func NewCheckCgroup() CheckHandler {
+	return &CheckCgroup{}
+}
+
+func (l *CheckCgroup) Build() *CheckData {
+	attributes := []CheckAttribute{
+		{name: "cgroup", description: "Path of the cgroup relative to the cgroup root, ex.: system.slice/nginx.service"},
+		{name: "name", description: "Name of the cgroup, ex.: nginx.service"},
+		{name: "depth", description: "Nesting level of the cgroup, top level cgroups have depth 1"},
+		{name: "memory_current", description: "Current memory usage", unit: UByte},
+		{name: "memory_max", description: "Memory limit (empty if unlimited)", unit: UByte},
+		{name: "memory_pct", description: "Memory usage in percent of the limit (empty if unlimited)", unit: UPercent},
+		{name: "memory_high", description: "Number of times the memory usage exceeded the high boundary"},
+		{name: "memory_oom", description: "Number of times the memory usage hit the limit and allocation failed"},
+		{name: "memory_oom_kill", description: "Number of processes killed by the oom killer"},
+		{name: "cpu_usage", description: "CPU usage in percent of a single cpu (calculated over the last " + CgroupRateDuration.String() + ")", unit: UPercent},
+		{name: "cpu_usage_usec", description: "Total cpu time consumed in microseconds"},
+		{name: "cpu_max", description: "CPU limit in number of cpus (empty if unlimited)"},
+		{name: "cpu_max_pct", description: "CPU usage in percent of the cpu limit (empty if unlimited)", unit: UPercent},
+		{name: "cpu_periods", description: "Number of enforcement periods"},
+		{name: "cpu_throttled", description: "Number of periods the cgroup has been throttled"},
+		{name: "cpu_throttled_usec", description: "Total time the cgroup has been throttled in microseconds"},
+		{name: "cpu_throttled_pct", description: "Percentage of throttled enforcement periods", unit: UPercent},
+		{name: "pids_current", description: "Number of processes"},
+		{name: "pids_max", description: "Process limit (empty if unlimited)"},
+		{name: "pids_pct", description: "Number of processes in percent of the limit (empty if unlimited)", unit: UPercent},
+		{name: "io_rbytes", description: "Total bytes read from all block devices", unit: UByte},
+		{name: "io_wbytes", description: "Total bytes written to all block devices", unit: UByte},
+		{name: "io_rios", description: "Total read operations on all block devices"},
+		{name: "io_wios", description: "Total write operations on all block devices"},
+	}
+	for _, resource := range cgroupPressureResources {
+		for _, kind := range []string{"some", "full"} {
+			for _, avg := range []string{"avg10", "avg60", "avg300"} {
+				attributes = append(attributes, CheckAttribute{
+					name:        fmt.Sprintf("%s_%s_%s", resource, kind, avg),
+					description: fmt.Sprintf("Pressure stall information: percentage of time %s tasks were stalled on %s (%s)", kind, resource, avg),
+					unit:        UPercent,
+				})
+			}
+		}
+	}
+
+	return &CheckData{
+		name:         "check_cgroup",
+		description:  "Checks resource usage and pressure of cgroup v2 groups like systemd slices, services or containers.",
+		implemented:  Linux,
+		hasInventory: ListInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"path": {value: &l.paths, isFilter: true, description: "Glob pattern of cgroups relative to the cgroup root, ex.: system.slice/*.service (default: */*)"},
+			"unit": {value: &l.units, isFilter: true, description: "Show this systemd unit only, ex.: nginx.service. The .service suffix may be omitted"},
+		},
+		defaultWarning:  "memory_pct > 80 || pids_pct > 80 || memory_full_avg60 > 10",
+		defaultCritical: "memory_pct > 90 || pids_pct > 90 || memory_full_avg60 > 30",
+		detailSyntax:    "${cgroup} memory ${memory_current | h}B cpu {{ IF cpu_usage != '' }}${cpu_usage | fmt=%.1f}%{{ ELSE }}unknown{{ END }}",
+		okSyntax:        "%(status) - all %{count} cgroups are ok",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no cgroups found",
+		attributes:      attributes,
+		listSorted:      []string{"cgroup"},
+		exampleDefault: `
+    check_cgroup
+    OK - all 23 cgroups are ok |'system.slice_memory'=1723105280B;;;0 'system.slice_cpu_usage'=3.2%;;;0 ...
+
+By default all top level cgroups and their direct children are checked. Check a single service:
+
+    check_cgroup unit=nginx
+    OK - all 1 cgroups are ok |'system.slice/nginx.service_memory'=18243584B;;;0 ...
+
+Check all docker containers for cpu throttling and cpu pressure:
+
+    check_cgroup path="system.slice/docker-*.scope" warn="cpu_throttled_pct > 10 || cpu_some_avg60 > 20"
+
+The cpu usage is calculated from values collected by the system task, it is unknown until the cgroup has been seen twice.
+The system task only collects cgroups up to the 'cgroup depth' configured in /settings/system/default (default: 2).
+	`,
+		exampleArgs: `path="system.slice/*.service" warn="memory_pct > 80" crit="memory_pct > 90"`,
+	}
+}
+
+func (l *CheckCgroup) Check(_ context.Context, snc *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	l.snc = snc
+
+	if _, err := os.Stat(filepath.Join(cgroupPath, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("no cgroup v2 hierarchy found at %s: %s", cgroupPath, err.Error())
+	}
+
+	patterns := make([]string, 0, len(l.paths))
+	for _, pattern := range l.paths {
+		patterns = append(patterns, strings.Trim(pattern, "/"))
+	}
+	if len(patterns) == 0 && len(l.units) == 0 {
+		patterns = []string{"*", "*/*"}
+	}
+
+	units := make([]string, 0, len(l.units))
+	for _, unit := range l.units {
+		if !strings.Contains(unit, ".") {
+			unit += ".service"
+		}
+		units = append(units, unit)
+	}
+
+	// only walk as deep as required by the patterns, units are located in slices and service managers
+	maxDepth := cgroupMaxDepth(patterns)
+	err := filepath.WalkDir(cgroupPath, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Debugf("cannot read %s: %s", path, err.Error())
+
+			return nil
+		}
+		if !dirEntry.IsDir() || path == cgroupPath {
+			return nil
+		}
+
+		name := cgroupName(cgroupPath, path)
+		if l.matchCgroup(name, patterns, units) {
+			entry := l.readCgroup(path)
+			if check.MatchMapCondition(check.filter, entry, true) {
+				check.listData = append(check.listData, entry)
+				l.addMetrics(check, entry)
+			}
+		}
+
+		if maxDepth >= 0 && strings.Count(name, "/")+1 >= maxDepth {
+			if len(units) == 0 || (!strings.HasSuffix(name, ".slice") && !strings.HasSuffix(name, ".service")) {
+				return fs.SkipDir
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %s", cgroupPath, err.Error())
+	}
+
+	return check.Finalize()
+}
+
+// cgroupMaxDepth returns the deepest level matched by any of the patterns or -1 if a pattern matches any depth
+func cgroupMaxDepth(patterns []string) int {
+	maxDepth := 0
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "**") {
+			return -1
+		}
+		maxDepth = max(maxDepth, strings.Count(pattern, "/")+1)
+	}
+
+	return maxDepth
+}
+
+// matchCgroup returns true if the cgroup matches any of the path patterns or systemd units
+func (l *CheckCgroup) matchCgroup(name string, patterns, units []string) bool {
+	for _, unit := range units {
+		if filepath.Base(name) == unit {
+			return true
+		}
+	}
+
+	for _, pattern := range patterns {
+		matched, err := doublestar.Match(pattern, name)
+		if err != nil {
+			log.Debugf("invalid cgroup pattern %s: %s", pattern, err.Error())
+
+			continue
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *CheckCgroup) readCgroup(path string) map[string]string {
+	name := cgroupName(cgroupPath, path)
+	entry := map[string]string{
+		"cgroup": name,
+		"name":   filepath.Base(name),
+		"depth":  fmt.Sprintf("%d", strings.Count(name, "/")+1),
+	}
+
+	// memory
+	entry["memory_current"] = l.readValue(path, "memory.current")
+	entry["memory_max"] = l.readValue(path, "memory.max")
+	entry["memory_pct"] = l.percent(entry["memory_current"], entry["memory_max"])
+	events := readCgroupKeyValue(filepath.Join(path, "memory.events"))
+	entry["memory_high"] = events["high"]
+	entry["memory_oom"] = events["oom"]
+	entry["memory_oom_kill"] = events["oom_kill"]
+
+	// cpu
+	stat := readCgroupKeyValue(filepath.Join(path, "cpu.stat"))
+	entry["cpu_usage_usec"] = stat["usage_usec"]
+	entry["cpu_periods"] = stat["nr_periods"]
+	entry["cpu_throttled"] = stat["nr_throttled"]
+	entry["cpu_throttled_usec"] = stat["throttled_usec"]
+	entry["cpu_throttled_pct"] = l.percent(stat["nr_throttled"], stat["nr_periods"])
+	entry["cpu_usage"] = ""
+	if counter := l.snc.Counter.Get("cgroup", name); counter != nil {
+		rate, err := counter.GetRate(CgroupRateDuration)
+		if err == nil && rate >= 0 {
+			// usage_usec per second
+			entry["cpu_usage"] = strconv.FormatFloat(utils.ToPrecision(rate/1e4, 2), 'f', -1, 64)
+		}
+	}
+	entry["cpu_max"] = l.readCPUMax(path)
+	entry["cpu_max_pct"] = ""
+	if entry["cpu_usage"] != "" && entry["cpu_max"] != "" {
+		entry["cpu_max_pct"] = l.percent(entry["cpu_usage"], fmt.Sprintf("%f", convert.Float64(entry["cpu_max"])*100))
+	}
+
+	// pids
+	entry["pids_current"] = l.readValue(path, "pids.current")
+	entry["pids_max"] = l.readValue(path, "pids.max")
+	entry["pids_pct"] = l.percent(entry["pids_current"], entry["pids_max"])
+
+	// io
+	for key, val := range l.readIOStat(path) {
+		entry["io_"+key] = val
+	}
+
+	// pressure stall information
+	for _, resource := range cgroupPressureResources {
+		for key, val := range l.readPressure(path, resource) {
+			entry[resource+"_"+key] = val
+		}
+	}
+
+	return entry
+}
+
+// readValue returns the content of single value cgroup files, unlimited values (max) are returned as empty string
+func (l *CheckCgroup) readValue(path, file string) string {
+	data, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return ""
+	}
+
+	val := strings.TrimSpace(string(data))
+	if val == "max" {
+		return ""
+	}
+
+	return val
+}
+
+// readCPUMax returns the cpu quota in number of cpus from cpu.max, ex.: "50000 100000" is 0.5 cpus
+func (l *CheckCgroup) readCPUMax(path string) string {
+	fields := strings.Fields(l.readValue(path, "cpu.max"))
+	if len(fields) != 2 || fields[0] == "max" {
+		return ""
+	}
+
+	quota, err := convert.Float64E(fields[0])
+	if err != nil {
+		return ""
+	}
+	period, err := convert.Float64E(fields[1])
+	if err != nil || period == 0 {
+		return ""
+	}
+
+	return strconv.FormatFloat(utils.ToPrecision(quota/period, 2), 'f', -1, 64)
+}
+
+// readIOStat sums up the io.stat values of all devices, ex.: 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+func (l *CheckCgroup) readIOStat(path string) map[string]string {
+	res := map[string]string{}
+	sums := map[string]int64{"rbytes": 0, "wbytes": 0, "rios": 0, "wios": 0}
+	data, err := os.ReadFile(filepath.Join(path, "io.stat"))
+	if err != nil {
+		for key := range sums {
+			res[key] = ""
+		}
+
+		return res
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, field := range strings.Fields(line) {
+			key, val, found := strings.Cut(field, "=")
+			if _, ok := sums[key]; found && ok {
+				sums[key] += convert.Int64(val)
+			}
+		}
+	}
+	for key, val := range sums {
+		res[key] = fmt.Sprintf("%d", val)
+	}
+
+	return res
+}
+
+// readPressure parses <resource>.pressure files, ex.: some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func (l *CheckCgroup) readPressure(path, resource string) map[string]string {
+	res := map[string]string{}
+	for _, kind := range []string{"some", "full"} {
+		for _, avg := range []string{"avg10", "avg60", "avg300"} {
+			res[kind+"_"+avg] = ""
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(path, resource+".pressure"))
+	if err != nil {
+		return res
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, val, found := strings.Cut(field, "=")
+			if _, ok := res[fields[0]+"_"+key]; found && ok {
+				res[fields[0]+"_"+key] = val
+			}
+		}
+	}
+
+	return res
+}
+
+// percent returns the usage in percent of the limit or an empty string if there is no limit
+func (l *CheckCgroup) percent(current, limit string) string {
+	if current == "" || limit == "" {
+		return ""
+	}
+
+	lim := convert.Float64(limit)
+	if lim <= 0 {
+		return ""
+	}
+
+	return strconv.FormatFloat(utils.ToPrecision(convert.Float64(current)*100/lim, 2), 'f', -1, 64)
+}
+
+func (l *CheckCgroup) addMetrics(check *CheckData, entry map[string]string) {
+	if entry["memory_current"] != "" {
+		metric := &CheckMetric{
+			Name:          entry["cgroup"] + "_memory",
+			ThresholdName: "memory_current",
+			Unit:          "B",
+			Value:         convert.Int64(entry["memory_current"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		}
+		if entry["memory_max"] != "" {
+			maxVal := convert.Float64(entry["memory_max"])
+			metric.Max = &maxVal
+		}
+		check.result.Metrics = append(check.result.Metrics, metric)
+	}
+
+	for _, name := range []string{"memory_pct", "cpu_usage", "cpu_throttled_pct", "pids_pct"} {
+		if entry[name] == "" {
+			continue
+		}
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["cgroup"] + "_" + name,
+			ThresholdName: name,
+			Unit:          "%",
+			Value:         convert.Float64(entry[name]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		})
+	}
+
+	if entry["pids_current"] != "" {
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["cgroup"] + "_pids",
+			ThresholdName: "pids_current",
+			Value:         convert.Int64(entry["pids_current"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		})
+	}
+
+	for _, resource := range cgroupPressureResources {
+		for _, kind := range []string{"some", "full"} {
+			name := resource + "_" + kind + "_avg60"
+			if entry[name] == "" {
+				continue
+			}
+			check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+				Name:          entry["cgroup"] + "_" + name,
+				ThresholdName: name,
+				Unit:          "%",
+				Value:         convert.Float64(entry[name]),
+				Warning:       check.warnThreshold,
+				Critical:      check.critThreshold,
+				Min:           &Zero,
+				Max:           &Hundred,
+			})
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package snclient
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCgroup(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	origPath := cgroupPath
+	cgroupPath = tmpDir
+	defer func() { cgroupPath = origPath }()
+
+	writeTestFile(t, filepath.Join(tmpDir, "cgroup.controllers"), "cpuset cpu io memory pids\n")
+
+	slice := filepath.Join(tmpDir, "system.slice")
+	writeTestFile(t, filepath.Join(slice, "memory.current"), "1073741824\n")
+	writeTestFile(t, filepath.Join(slice, "memory.max"), "max\n")
+	writeTestFile(t, filepath.Join(slice, "pids.current"), "212\n")
+	writeTestFile(t, filepath.Join(slice, "pids.max"), "max\n")
+	writeTestFile(t, filepath.Join(slice, "cpu.stat"), "usage_usec 81234567\nuser_usec 61234567\nsystem_usec 20000000\n")
+
+	nginx := filepath.Join(slice, "nginx.service")
+	writeTestFile(t, filepath.Join(nginx, "memory.current"), "471859200\n")
+	writeTestFile(t, filepath.Join(nginx, "memory.max"), "524288000\n")
+	writeTestFile(t, filepath.Join(nginx, "memory.events"), "low 0\nhigh 0\nmax 12\noom 2\noom_kill 1\noom_group_kill 0\n")
+	writeTestFile(t, filepath.Join(nginx, "pids.current"), "9\n")
+	writeTestFile(t, filepath.Join(nginx, "pids.max"), "100\n")
+	writeTestFile(t, filepath.Join(nginx, "cpu.max"), "50000 100000\n")
+	writeTestFile(t, filepath.Join(nginx, "cpu.stat"), "usage_usec 1000000\nuser_usec 800000\nsystem_usec 200000\n"+
+		"nr_periods 200\nnr_throttled 50\nthrottled_usec 123456\n")
+	writeTestFile(t, filepath.Join(nginx, "io.stat"), "8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0\n"+
+		"8:16 rbytes=40800 wbytes=0 rios=8 wios=0 dbytes=0 dios=0\n")
+	writeTestFile(t, filepath.Join(nginx, "memory.pressure"), "some avg10=1.50 avg60=12.25 avg300=4.00 total=123456\n"+
+		"full avg10=0.00 avg60=0.50 avg300=0.10 total=2345\n")
+
+	// nested cgroups are not checked by default
+	writeTestFile(t, filepath.Join(slice, "nginx.service", "worker", "memory.current"), "1024\n")
+
+	res := snc.RunCheck("check_cgroup", []string{})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - warning(system.slice/nginx.service memory 471.86 MB cpu unknown) |"+
+		"'system.slice_memory'=1073741824B;;;0 'system.slice_pids'=212;;;0 "+
+		"'system.slice/nginx.service_memory'=471859200B;;;0;524288000 'system.slice/nginx.service_memory_pct'=90%;80;90;0 "+
+		"'system.slice/nginx.service_cpu_throttled_pct'=25%;;;0 'system.slice/nginx.service_pids_pct'=9%;80;90;0 "+
+		"'system.slice/nginx.service_pids'=9;;;0 'system.slice/nginx.service_memory_some_avg60'=12.25%;;;0;100 "+
+		"'system.slice/nginx.service_memory_full_avg60'=0.5%;10;30;0;100",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_cgroup", []string{"unit=nginx", "warn=none", "crit=none", "ok-syntax=${list}",
+		"detail-syntax=${memory_oom_kill} ${cpu_max} ${io_rbytes} ${io_wios} ${memory_some_avg300}"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "1 0.5 1500000 353 4.00 |", "output matches")
+
+	// cpu usage is calculated from the counters of the system task
+	snc.Counter.Create("cgroup", "system.slice/nginx.service", time.Minute, time.Second)
+	snc.Counter.Set("cgroup", "system.slice/nginx.service", float64(0))
+	time.Sleep(100 * time.Millisecond)
+	snc.Counter.Set("cgroup", "system.slice/nginx.service", float64(1000000))
+	res = snc.RunCheck("check_cgroup", []string{"path=system.slice/*", "warn=cpu_usage > 100", "crit=cpu_max_pct > 100"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "CRITICAL - system.slice/nginx.service memory 471.86 MB cpu ", "output matches")
+
+	// units are found in nested slices, nested cgroups with ** patterns
+	writeTestFile(t, filepath.Join(tmpDir, "user.slice", "user-1000.slice", "user@1000.service", "app.slice", "backup.service", "memory.current"), "2048\n")
+	res = snc.RunCheck("check_cgroup", []string{"unit=backup", "warn=none", "crit=none", "ok-syntax=${list}", "detail-syntax=${cgroup} ${depth}"})
+	assert.Containsf(t, string(res.BuildPluginOutput()), "user.slice/user-1000.slice/user@1000.service/app.slice/backup.service 5 |", "nested unit found")
+
+	res = snc.RunCheck("check_cgroup", []string{"path=**/worker", "warn=none", "crit=none", "ok-syntax=${list}", "detail-syntax=${cgroup}"})
+	assert.Containsf(t, string(res.BuildPluginOutput()), "system.slice/nginx.service/worker |", "nested cgroup found")
+
+	res = snc.RunCheck("check_cgroup", []string{"path=docker/*"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Equalf(t, "UNKNOWN - no cgroups found", string(res.BuildPluginOutput()), "output matches")
+
+	// the system task collects cgroups up to the configured depth only
+	writeTestFile(t, filepath.Join(nginx, "worker", "cpu.stat"), "usage_usec 500\n")
+	handler := &CheckSystemHandler{snc: snc, bufferLength: time.Minute, metricsInterval: time.Second, cgroupDepth: 2, cgroupPath: tmpDir}
+	handler.addLinuxCgroupStats()
+	assert.NotNilf(t, snc.Counter.Get("cgroup", "system.slice"), "top level cgroup collected")
+	assert.NotNilf(t, snc.Counter.Get("cgroup", "system.slice/nginx.service"), "second level cgroup collected")
+	assert.Nilf(t, snc.Counter.Get("cgroup", "system.slice/nginx.service/worker"), "nested cgroup skipped")
+}
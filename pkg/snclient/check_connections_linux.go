@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/consol-monitoring/snclient/pkg/convert"
@@ -69,8 +70,8 @@ func (l *CheckConnections) getProcStats(file string) ([]uint64, error) {
 		if log.IsV(LogVerbosityTrace2) {
 			// debug full entry
 			s := tcpStates(convert.UInt16(state))
-			fromA, fromP := l.parseHexIP(fields[1])
-			toA, toP := l.parseHexIP(fields[2])
+			fromA, fromP := parseProcNetAddress(fields[1])
+			toA, toP := parseProcNetAddress(fields[2])
 			log.Tracef("from: %30s:%-7d to: %30s:%-7d uid: %6s state: %s", fromA, fromP, toA, toP, fields[5], s.String())
 		}
 	}
@@ -82,13 +83,14 @@ func (l *CheckConnections) getProcStats(file string) ([]uint64, error) {
 	return counter, nil
 }
 
-func (l *CheckConnections) parseHexIP(raw string) (address string, port uint64) {
+// parseProcNetAddress parses addresses from /proc/net/tcp{,6} and /proc/net/udp{,6}, ex.: 0100007F:0016
+func parseProcNetAddress(raw string) (address string, port uint64) {
 	fields := strings.Split(raw, ":")
 	if len(fields) != 2 {
 		return raw, 0
 	}
 
-	port, err := convert.UInt64E(fields[1])
+	port, err := strconv.ParseUint(fields[1], 16, 16)
 	if err != nil {
 		log.Tracef("port parse error for address %s: %s", raw, err.Error())
 
@@ -96,15 +98,17 @@ func (l *CheckConnections) parseHexIP(raw string) (address string, port uint64)
 	}
 
 	ipBytes, err := hex.DecodeString(fields[0])
-	if err != nil {
-		log.Tracef("ip parse error for address %s: %s", raw, err.Error())
+	if err != nil || len(ipBytes)%4 != 0 {
+		log.Tracef("ip parse error for address %s", raw)
 
 		return raw, 0
 	}
 
-	// Reverse bytes because the IP is stored in little-endian
-	for i, j := 0, len(ipBytes)-1; i < j; i, j = i+1, j-1 {
-		ipBytes[i], ipBytes[j] = ipBytes[j], ipBytes[i]
+	// the ip is stored as 32bit words in host byte order (little-endian)
+	for word := 0; word < len(ipBytes); word += 4 {
+		for i, j := word, word+3; i < j; i, j = i+1, j-1 {
+			ipBytes[i], ipBytes[j] = ipBytes[j], ipBytes[i]
+		}
 	}
 
 	// Convert to an IP address
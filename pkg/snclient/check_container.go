@@ -0,0 +1,433 @@
+package snclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
+	"github.com/consol-monitoring/snclient/pkg/utils"
+)
+
+func init() {
+	AvailableChecks["check_container"] = CheckEntry{"check_container", NewCheckContainer}
+}
+
+// containerStatsConcurrency limits the number of parallel stats requests, each one takes about a second
+const containerStatsConcurrency = 10
+
+// containerSockets contains the default engine api sockets of docker and podman, the first existing one is used
+var containerSockets = []string{
+	"/var/run/docker.sock",
+	"/run/podman/podman.sock",
+	"${XDG_RUNTIME_DIR}/podman/podman.sock",
+	"${HOME}/.docker/run/docker.sock",
+}
+
+type CheckContainer struct {
+	socket     string
+	containers []string
+	stats      bool
+}
+
+// containerListEntry is a single container from /containers/json
+type containerListEntry struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Created int64             `json:"Created"`
+	Labels  map[string]string `json:"Labels"`
+}
+
+// containerInspect contains the used parts of /containers/<id>/json
+type containerInspect struct {
+	RestartCount int64 `json:"RestartCount"`
+	State        struct {
+		Status     string `json:"Status"`
+		OOMKilled  bool   `json:"OOMKilled"`
+		ExitCode   int64  `json:"ExitCode"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+		Health     *struct {
+			Status        string `json:"Status"`
+			FailingStreak int64  `json:"FailingStreak"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// containerStats contains the used parts of /containers/<id>/stats
+type containerStats struct {
+	CPUStats    containerCPUStats `json:"cpu_stats"`
+	PreCPUStats containerCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+}
+
+type containerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage uint64 `json:"total_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint64 `json:"online_cpus"`
+}
+
+func NewCheckContainer() CheckHandler {
+	return &CheckContainer{}
+}
+
+func (l *CheckContainer) Build() *CheckData {
+	return &CheckData{
+		name:          "check_container",
+		description:   "Checks the state of docker or podman containers using the engine api socket.",
+		implemented:   Linux | Darwin,
+		hasInventory:  ListInventory,
+		inventoryName: "containers",
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"socket":    {value: &l.socket, description: "Path to the engine api unix socket (default: $DOCKER_HOST or the first existing docker / podman socket)"},
+			"container": {value: &l.containers, isFilter: true, description: "Show this container only, matches name or id"},
+			"stats":     {value: &l.stats, description: "Fetch cpu and memory statistics of running containers (takes about a second)"},
+		},
+		defaultWarning:  "state in ('paused', 'restarting') || restart_count > 5",
+		defaultCritical: "state = 'dead' || health = 'unhealthy' || (state = 'exited' && exit_code != 0) || oom_killed = 1",
+		detailSyntax:    "${name} ${state} (health: ${health})",
+		okSyntax:        "%(status) - all %{count} containers are ok",
+		topSyntax:       "%(status) - ${problem_list || 'found ${count} containers'}",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no containers found",
+		attributes: []CheckAttribute{
+			{name: "id", description: "Short container id"},
+			{name: "name", description: "Name of the container"},
+			{name: "image", description: "Image of the container"},
+			{name: "state", description: "State of the container: created, running, paused, restarting, exited, removing or dead"},
+			{name: "status", description: "Human readable status, ex.: Up 2 hours (healthy)"},
+			{name: "health", description: "Health check status: healthy, unhealthy, starting or none"},
+			{name: "failing_streak", description: "Number of consecutive failed health checks"},
+			{name: "restart_count", description: "Number of restarts"},
+			{name: "exit_code", description: "Exit code of the last run"},
+			{name: "oom_killed", description: "Flag whether the last run has been killed by the oom killer: 0 / 1"},
+			{name: "created", description: "Unix timestamp of the container creation", unit: UTimestamp},
+			{name: "started", description: "Unix timestamp of the last start (empty if never started)", unit: UTimestamp},
+			{name: "finished", description: "Unix timestamp of the last stop (empty if never stopped)", unit: UTimestamp},
+			{name: "uptime", description: "Seconds since the container has been started (empty if not running)", unit: UDuration},
+			{name: "compose_project", description: "Docker compose project from the com.docker.compose.project label"},
+			{name: "compose_service", description: "Docker compose service from the com.docker.compose.service label"},
+			{name: "cpu", description: "CPU usage in percent of a single cpu (requires stats)", unit: UPercent},
+			{name: "memory", description: "Memory usage without page cache (requires stats)", unit: UByte},
+			{name: "memory_limit", description: "Memory limit (requires stats)", unit: UByte},
+			{name: "memory_pct", description: "Memory usage in percent of the limit (requires stats)", unit: UPercent},
+		},
+		listSorted: []string{"name"},
+		exampleDefault: `
+    check_container
+    OK - all 7 containers are ok |'web_restarts'=0;5;;0 'db_restarts'=0;5;;0 ...
+
+Make sure all containers of a docker compose project are running and healthy:
+
+    check_container filter="compose_project = 'shop'" crit="state != 'running' || health not in ('healthy', 'none')"
+    CRITICAL - shop-db-1 exited (health: none) |...
+
+Alert on memory usage of running containers:
+
+    check_container stats filter="state = 'running'" warn="memory_pct > 80" crit="memory_pct > 90"
+
+The agent needs permission to access the socket, ex.: by adding the snclient user to the docker group.
+Rootless podman sockets can be used with the socket argument: check_container socket=/run/user/1000/podman/podman.sock
+	`,
+		exampleArgs: `filter="compose_project = 'shop'" crit="health = 'unhealthy'"`,
+	}
+}
+
+func (l *CheckContainer) Check(ctx context.Context, _ *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	socket := l.findSocket()
+	if socket == "" {
+		return nil, fmt.Errorf("no docker or podman socket found, use the socket argument to set the path")
+	}
+
+	// inventory calls skip the argument parsing, so there is no timeout set yet
+	timeout := check.timeout
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout.Seconds()
+	}
+
+	client := &http.Client{
+		Timeout: time.Duration(timeout * float64(time.Second)),
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	containers := []containerListEntry{}
+	if err := l.apiGet(ctx, client, "/containers/json?all=1", &containers); err != nil {
+		return nil, err
+	}
+
+	entries := []map[string]string{}
+	for i := range containers {
+		container := &containers[i]
+		entry := l.buildEntry(container)
+		if len(l.containers) > 0 && !slices.Contains(l.containers, entry["name"]) && !slices.Contains(l.containers, entry["id"]) && !slices.Contains(l.containers, container.ID) {
+			continue
+		}
+
+		inspect := containerInspect{}
+		if err := l.apiGet(ctx, client, "/containers/"+url.PathEscape(container.ID)+"/json", &inspect); err != nil {
+			log.Debugf("inspect container %s: %s", entry["name"], err.Error())
+		} else {
+			l.addInspect(entry, &inspect)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if l.stats {
+		l.fetchStats(ctx, client, entries)
+	}
+
+	for _, entry := range entries {
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+		check.listData = append(check.listData, entry)
+		l.addMetrics(check, entry)
+	}
+
+	return check.Finalize()
+}
+
+// findSocket returns the socket argument, the DOCKER_HOST unix socket or the first existing default socket
+func (l *CheckContainer) findSocket() string {
+	if l.socket != "" {
+		return strings.TrimPrefix(l.socket, "unix://")
+	}
+
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		return strings.TrimPrefix(host, "unix://")
+	}
+
+	for _, socket := range containerSockets {
+		socket = os.ExpandEnv(socket)
+		if !filepath.IsAbs(socket) {
+			continue
+		}
+		if _, err := os.Stat(socket); err == nil {
+			return socket
+		}
+	}
+
+	return ""
+}
+
+// apiGet fetches the given engine api path and decodes the json response into result
+func (l *CheckContainer) apiGet(ctx context.Context, client *http.Client, path string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost"+path, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("new request: %s", err.Error())
+	}
+
+	log.Tracef("container api GET %s", path)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("container api request failed: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("container api read failed: %s", err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("container api request %s failed: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("container api json error: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (l *CheckContainer) buildEntry(container *containerListEntry) map[string]string {
+	name := container.ID
+	if len(container.Names) > 0 {
+		name = strings.TrimPrefix(container.Names[0], "/")
+	}
+	id := container.ID
+	if len(id) > 12 {
+		id = id[:12]
+	}
+
+	return map[string]string{
+		"id":              id,
+		"name":            name,
+		"image":           container.Image,
+		"state":           container.State,
+		"status":          container.Status,
+		"health":          "none",
+		"failing_streak":  "0",
+		"restart_count":   "0",
+		"exit_code":       "",
+		"oom_killed":      "0",
+		"created":         fmt.Sprintf("%d", container.Created),
+		"started":         "",
+		"finished":        "",
+		"uptime":          "",
+		"compose_project": container.Labels["com.docker.compose.project"],
+		"compose_service": container.Labels["com.docker.compose.service"],
+		"cpu":             "",
+		"memory":          "",
+		"memory_limit":    "",
+		"memory_pct":      "",
+	}
+}
+
+func (l *CheckContainer) addInspect(entry map[string]string, inspect *containerInspect) {
+	entry["restart_count"] = fmt.Sprintf("%d", inspect.RestartCount)
+	entry["exit_code"] = fmt.Sprintf("%d", inspect.State.ExitCode)
+	if inspect.State.OOMKilled {
+		entry["oom_killed"] = "1"
+	}
+	if inspect.State.Health != nil && inspect.State.Health.Status != "" {
+		entry["health"] = inspect.State.Health.Status
+		entry["failing_streak"] = fmt.Sprintf("%d", inspect.State.Health.FailingStreak)
+	}
+
+	if started := l.parseTime(inspect.State.StartedAt); started > 0 {
+		entry["started"] = fmt.Sprintf("%d", started)
+		if entry["state"] == "running" {
+			entry["uptime"] = fmt.Sprintf("%d", time.Now().Unix()-started)
+		}
+	}
+	if finished := l.parseTime(inspect.State.FinishedAt); finished > 0 {
+		entry["finished"] = fmt.Sprintf("%d", finished)
+	}
+}
+
+// parseTime returns the unix timestamp of engine api dates, unset dates (0001-01-01T00:00:00Z) return 0
+func (l *CheckContainer) parseTime(date string) int64 {
+	parsed, err := time.Parse(time.RFC3339Nano, date)
+	if err != nil || parsed.Year() <= 1 {
+		return 0
+	}
+
+	return parsed.Unix()
+}
+
+// fetchStats adds cpu and memory usage to running containers, stats are fetched in parallel since
+// the engine waits for a second sample to calculate the cpu usage. Parallel requests are limited to containerStatsConcurrency
+func (l *CheckContainer) fetchStats(ctx context.Context, client *http.Client, entries []map[string]string) {
+	results := make([]*containerStats, len(entries))
+	waitGroup := sync.WaitGroup{}
+	limit := make(chan struct{}, containerStatsConcurrency)
+	for i, entry := range entries {
+		if entry["state"] != "running" {
+			continue
+		}
+		waitGroup.Add(1)
+		limit <- struct{}{}
+		go func(i int, name string) {
+			defer func() {
+				<-limit
+				waitGroup.Done()
+			}()
+			stats := &containerStats{}
+			if err := l.apiGet(ctx, client, "/containers/"+url.PathEscape(name)+"/stats?stream=false", stats); err != nil {
+				log.Debugf("stats for container %s: %s", name, err.Error())
+
+				return
+			}
+			results[i] = stats
+		}(i, entry["name"])
+	}
+	waitGroup.Wait()
+
+	for i, stats := range results {
+		if stats == nil {
+			continue
+		}
+		entry := entries[i]
+
+		cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+		systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+		if cpuDelta >= 0 && systemDelta > 0 && stats.PreCPUStats.SystemUsage > 0 {
+			entry["cpu"] = strconv.FormatFloat(utils.ToPrecision(cpuDelta/systemDelta*float64(stats.CPUStats.OnlineCPUs)*100, 2), 'f', -1, 64)
+		}
+
+		// same as docker stats, page cache is not counted as used memory
+		usage := stats.MemoryStats.Usage
+		cache := stats.MemoryStats.Stats["inactive_file"]
+		if cache == 0 {
+			cache = stats.MemoryStats.Stats["total_inactive_file"]
+		}
+		if cache < usage {
+			usage -= cache
+		}
+		entry["memory"] = fmt.Sprintf("%d", usage)
+		if stats.MemoryStats.Limit > 0 {
+			entry["memory_limit"] = fmt.Sprintf("%d", stats.MemoryStats.Limit)
+			entry["memory_pct"] = strconv.FormatFloat(utils.ToPrecision(float64(usage)*100/float64(stats.MemoryStats.Limit), 2), 'f', -1, 64)
+		}
+	}
+}
+
+func (l *CheckContainer) addMetrics(check *CheckData, entry map[string]string) {
+	check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+		Name:          entry["name"] + "_restarts",
+		ThresholdName: "restart_count",
+		Value:         convert.Int64(entry["restart_count"]),
+		Warning:       check.warnThreshold,
+		Critical:      check.critThreshold,
+		Min:           &Zero,
+	})
+
+	if entry["cpu"] != "" {
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["name"] + "_cpu",
+			ThresholdName: "cpu",
+			Unit:          "%",
+			Value:         convert.Float64(entry["cpu"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		})
+	}
+
+	if entry["memory"] != "" {
+		metric := &CheckMetric{
+			Name:          entry["name"] + "_memory",
+			ThresholdName: "memory",
+			Unit:          "B",
+			Value:         convert.Int64(entry["memory"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		}
+		if entry["memory_limit"] != "" {
+			limit := convert.Float64(entry["memory_limit"])
+			metric.Max = &limit
+		}
+		check.result.Metrics = append(check.result.Metrics, metric)
+	}
+}
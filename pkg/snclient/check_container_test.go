@@ -0,0 +1,111 @@
+//go:build !windows
+
+package snclient
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckContainer(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	socket := startTestContainerAPI(t)
+
+	res := snc.RunCheck("check_container", []string{"socket=" + socket})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - critical(shop-db-1 running (health: unhealthy)) warning(worker restarting (health: none)) |"+
+		"'shop-web-1_restarts'=1;5;;0 'shop-db-1_restarts'=0;5;;0 'worker_restarts'=7;5;;0 'migrate_restarts'=0;5;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_container", []string{
+		"socket=" + socket, "filter=compose_project = 'shop'", "warn=none", "crit=health != 'healthy'",
+		"detail-syntax=${name} ${compose_service} ${health} ${failing_streak}",
+	})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - critical(shop-db-1 db unhealthy 3) |'shop-web-1_restarts'=1;;;0 'shop-db-1_restarts'=0;;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_container", []string{
+		"socket=" + socket, "container=shop-web-1", "stats", "ok-syntax=${list}",
+		"detail-syntax=${name} ${state} ${cpu}% ${memory_pct}% ${uptime | duration}",
+	})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "shop-web-1 running 50% 25% 01:00h |'shop-web-1_restarts'=1;5;;0 'shop-web-1_cpu'=50%;;;0 'shop-web-1_memory'=134217728B;;;0;536870912",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_container", []string{"socket=" + socket, "container=nothing"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Equalf(t, "UNKNOWN - no containers found", string(res.BuildPluginOutput()), "output matches")
+
+	// the inventory uses the default sockets
+	origSockets := containerSockets
+	containerSockets = []string{socket}
+	defer func() { containerSockets = origSockets }()
+	inv, err := snc.getInventoryEntry(t.Context(), "containers")
+	require.NoError(t, err)
+	assert.Lenf(t, inv, 4, "inventory contains all containers")
+
+	res = snc.RunCheck("check_container", []string{"socket=" + filepath.Join(t.TempDir(), "missing.sock")})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "container api request failed", "output matches")
+}
+
+// startTestContainerAPI starts a fake engine api on a unix socket and returns the socket path
+func startTestContainerAPI(t *testing.T) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	started := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/json", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[
+			{"Id":"a1b2c3d4e5f6a7b8c9d0","Names":["/shop-web-1"],"Image":"nginx:1.27","State":"running","Status":"Up 1 hour (healthy)","Created":1700000000,
+			 "Labels":{"com.docker.compose.project":"shop","com.docker.compose.service":"web"}},
+			{"Id":"b1b2c3d4e5f6a7b8c9d0","Names":["/shop-db-1"],"Image":"postgres:16","State":"running","Status":"Up 1 hour (unhealthy)","Created":1700000000,
+			 "Labels":{"com.docker.compose.project":"shop","com.docker.compose.service":"db"}},
+			{"Id":"c1b2c3d4e5f6a7b8c9d0","Names":["/worker"],"Image":"worker:latest","State":"restarting","Status":"Restarting (1) 5 seconds ago","Created":1700000000,"Labels":{}},
+			{"Id":"d1b2c3d4e5f6a7b8c9d0","Names":["/migrate"],"Image":"worker:latest","State":"exited","Status":"Exited (0) 2 hours ago","Created":1700000000,"Labels":{}}
+		]`)
+	})
+	mux.HandleFunc("/containers/a1b2c3d4e5f6a7b8c9d0/json", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"RestartCount":1,"State":{"Status":"running","ExitCode":0,"StartedAt":%q,"FinishedAt":"0001-01-01T00:00:00Z",
+			"Health":{"Status":"healthy","FailingStreak":0}}}`, started)
+	})
+	mux.HandleFunc("/containers/b1b2c3d4e5f6a7b8c9d0/json", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"RestartCount":0,"State":{"Status":"running","ExitCode":0,"StartedAt":%q,"FinishedAt":"0001-01-01T00:00:00Z",
+			"Health":{"Status":"unhealthy","FailingStreak":3}}}`, started)
+	})
+	mux.HandleFunc("/containers/c1b2c3d4e5f6a7b8c9d0/json", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"RestartCount":7,"State":{"Status":"restarting","ExitCode":1,"StartedAt":"2024-05-01T10:00:00Z","FinishedAt":"2024-05-01T10:00:05Z"}}`)
+	})
+	mux.HandleFunc("/containers/d1b2c3d4e5f6a7b8c9d0/json", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"RestartCount":0,"State":{"Status":"exited","ExitCode":0,"StartedAt":"2024-05-01T08:00:00Z","FinishedAt":"2024-05-01T08:00:30Z"}}`)
+	})
+	mux.HandleFunc("/containers/shop-web-1/stats", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{
+			"cpu_stats":{"cpu_usage":{"total_usage":2500000000},"system_cpu_usage":20000000000,"online_cpus":4},
+			"precpu_stats":{"cpu_usage":{"total_usage":2000000000},"system_cpu_usage":16000000000,"online_cpus":4},
+			"memory_stats":{"usage":150994944,"limit":536870912,"stats":{"inactive_file":16777216}}
+		}`)
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(func() { server.Close() })
+
+	return socket
+}
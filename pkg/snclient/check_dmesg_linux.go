@@ -0,0 +1,316 @@
+package snclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
+	"github.com/shirou/gopsutil/v4/host"
+)
+
+func init() {
+	AvailableChecks["check_dmesg"] = CheckEntry{"check_dmesg", NewCheckDmesg}
+}
+
+var (
+	dmesgKmsgFile   = "/dev/kmsg"
+	dmesgVMStatFile = "/proc/vmstat"
+	dmesgBootIDFile = "/proc/sys/kernel/random/boot_id"
+
+	// built-in patterns to classify kernel messages, first match wins
+	dmesgCategories = []struct {
+		name    string
+		pattern *regexp.Regexp
+	}{
+		{"oom_kill", regexp.MustCompile(`(?i)out of memory: kill|oom-kill:|invoked oom-killer|memory cgroup out of memory`)},
+		{"hung_task", regexp.MustCompile(`(?i)blocked for more than \d+ seconds|hung_task`)},
+		{"mce", regexp.MustCompile(`(?i)^mce:|machine check|hardware error|EDAC \S+: \d+ [CU]E `)},
+		{"io_error", regexp.MustCompile(`(?i)I/O error|critical medium error|blk_update_request|print_req_error|EXT4-fs error|metadata I/O error|BTRFS error|nvme\S*: .*timeout|ata\d+(\.\d+)?: (failed command|exception)`)},
+		{"segfault", regexp.MustCompile(`(?i)segfault at|general protection fault|traps: .* trap`)},
+	}
+
+	dmesgFacilities = map[int64]string{
+		0: "kern", 1: "user", 2: "mail", 3: "daemon", 4: "auth", 5: "syslog", 6: "lpr", 7: "news",
+		8: "uucp", 9: "cron", 10: "authpriv", 11: "ftp",
+		16: "local0", 17: "local1", 18: "local2", 19: "local3", 20: "local4", 21: "local5", 22: "local6", 23: "local7",
+	}
+
+	dmesgLevels = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+)
+
+// dmesgReadBufferSize must be large enough to hold a single /dev/kmsg record
+const dmesgReadBufferSize = 8192
+
+type CheckDmesg struct {
+	snc *Agent
+}
+
+func NewCheckDmesg() CheckHandler {
+	return &CheckDmesg{}
+}
+
+func (l *CheckDmesg) Build() *CheckData {
+	return &CheckData{
+		name:         "check_dmesg",
+		description:  "Checks new kernel messages from /dev/kmsg for oom kills, hung tasks, i/o errors, segfaults and machine check exceptions.",
+		implemented:  Linux,
+		hasInventory: NoInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		defaultFilter:   "category != 'other' || level in ('emerg', 'alert', 'crit')",
+		defaultWarning:  "category in ('oom_kill', 'hung_task', 'segfault')",
+		defaultCritical: "category in ('io_error', 'mce') || level in ('emerg', 'alert', 'crit')",
+		detailSyntax:    "${category}: ${message | cut=200}",
+		okSyntax:        "%(status) - %{count} new kernel message(s)",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptySyntax:     "%(status) - no new kernel messages",
+		emptyState:      CheckExitOK,
+		attributes: []CheckAttribute{
+			{name: "seq", description: "Sequence number of the kernel message"},
+			{name: "time", description: "Unix timestamp of the kernel message", unit: UTimestamp},
+			{name: "uptime", description: "Seconds since boot when the message was logged", unit: UDuration},
+			{name: "facility", description: "Syslog facility, ex.: kern, user or daemon"},
+			{name: "level", description: "Syslog level: emerg, alert, crit, err, warning, notice, info or debug"},
+			{name: "priority", description: "Numeric syslog level: 0 (emerg) - 7 (debug)"},
+			{name: "category", description: "Category from built-in patterns: oom_kill, hung_task, mce, io_error, segfault or other"},
+			{name: "message", description: "Message text"},
+		},
+		exampleDefault: `
+    check_dmesg
+    OK - no new kernel messages |'oom_kill'=0c
+
+    check_dmesg
+    WARNING - oom_kill: Out of memory: Killed process 1234 (java) total-vm:8123456kB, ... |'oom_kill'=1c
+
+Only messages logged since the last run are reported. The last sequence number is stored in the cache folder,
+so all checks share the same position and a message is only reported once.
+
+Alert on all kernel errors:
+
+    check_dmesg filter="facility = 'kern' && priority <= 3" warn="priority <= 3" crit="category = 'io_error'"
+
+Reading /dev/kmsg requires root permissions or the CAP_SYSLOG capability if kernel.dmesg_restrict is set.
+	`,
+		exampleArgs: `warn="category = 'segfault'" crit="category in ('oom_kill', 'io_error', 'mce')"`,
+	}
+}
+
+func (l *CheckDmesg) Check(_ context.Context, snc *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	l.snc = snc
+
+	bootID := l.readBootID()
+	lastSeq := l.loadSeq(bootID)
+
+	entries, newSeq, err := l.readKmsg(lastSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+		check.listData = append(check.listData, entry)
+	}
+
+	if newSeq != lastSeq {
+		l.saveSeq(bootID, newSeq)
+	}
+
+	if oomKill, ok := l.readVMStat("oom_kill"); ok {
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          "oom_kill",
+			ThresholdName: "oom_kill",
+			Unit:          "c",
+			Value:         oomKill,
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+		})
+	}
+
+	return check.Finalize()
+}
+
+// readKmsg returns all records with a sequence number greater than lastSeq (-1 reads all records)
+func (l *CheckDmesg) readKmsg(lastSeq int64) (entries []map[string]string, newSeq int64, err error) {
+	// use syscall directly, the go runtime poller would block on /dev/kmsg instead of returning EAGAIN
+	fd, err := syscall.Open(dmesgKmsgFile, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, lastSeq, fmt.Errorf("open %s: %s", dmesgKmsgFile, err.Error())
+	}
+	defer syscall.Close(fd)
+
+	var bootTime uint64
+	if bootTime, err = host.BootTime(); err != nil {
+		log.Debugf("failed to get boot time: %s", err.Error())
+	}
+
+	newSeq = lastSeq
+	buf := make([]byte, dmesgReadBufferSize)
+	carry := ""
+	for {
+		num, err := syscall.Read(fd, buf)
+		switch {
+		case errors.Is(err, syscall.EAGAIN):
+			// no more records
+			num = 0
+		case errors.Is(err, syscall.EPIPE):
+			// record has been overwritten while reading, continue with the next one
+			continue
+		case errors.Is(err, syscall.EINTR):
+			continue
+		case err != nil:
+			return nil, lastSeq, fmt.Errorf("read %s: %s", dmesgKmsgFile, err.Error())
+		}
+		if num <= 0 {
+			break
+		}
+
+		// /dev/kmsg returns one record per read, regular files (ex.: in tests) return arbitrary chunks
+		lines := strings.Split(carry+string(buf[:num]), "\n")
+		carry = lines[len(lines)-1]
+		for _, line := range lines[:len(lines)-1] {
+			entry := l.parseRecord(line, bootTime)
+			if entry == nil {
+				continue
+			}
+			seq := convert.Int64(entry["seq"])
+			if seq <= lastSeq {
+				continue
+			}
+			newSeq = seq
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, newSeq, nil
+}
+
+// parseRecord parses a single kmsg record, ex.: 6,1234,5678901,-;message text
+// continuation lines containing device information start with a space and are ignored
+func (l *CheckDmesg) parseRecord(line string, bootTime uint64) map[string]string {
+	if line == "" || strings.HasPrefix(line, " ") {
+		return nil
+	}
+
+	prefix, message, found := strings.Cut(line, ";")
+	if !found {
+		return nil
+	}
+	fields := strings.Split(prefix, ",")
+	if len(fields) < 3 {
+		return nil
+	}
+
+	prio, err := convert.Int64E(fields[0])
+	if err != nil {
+		return nil
+	}
+	seq, err := convert.Int64E(fields[1])
+	if err != nil {
+		return nil
+	}
+	usec := convert.Int64(fields[2])
+
+	facility, ok := dmesgFacilities[prio>>3]
+	if !ok {
+		facility = fmt.Sprintf("%d", prio>>3)
+	}
+	level := prio & 7
+
+	category := "other"
+	for _, cat := range dmesgCategories {
+		if cat.pattern.MatchString(message) {
+			category = cat.name
+
+			break
+		}
+	}
+
+	entry := map[string]string{
+		"seq":      fmt.Sprintf("%d", seq),
+		"time":     "",
+		"uptime":   fmt.Sprintf("%d", usec/1e6),
+		"facility": facility,
+		"level":    dmesgLevels[level],
+		"priority": fmt.Sprintf("%d", level),
+		"category": category,
+		"message":  message,
+	}
+	if bootTime > 0 {
+		entry["time"] = fmt.Sprintf("%d", int64(bootTime)+usec/1e6)
+	}
+
+	return entry
+}
+
+func (l *CheckDmesg) readBootID() string {
+	data, err := os.ReadFile(dmesgBootIDFile)
+	if err != nil {
+		log.Debugf("failed to read boot id: %s", err.Error())
+
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func (l *CheckDmesg) stateFile() string {
+	return filepath.Join(l.snc.getCacheFolder(), "dmesg.seq")
+}
+
+// loadSeq returns the last reported sequence number or -1 if there is none for the current boot
+func (l *CheckDmesg) loadSeq(bootID string) int64 {
+	data, err := os.ReadFile(l.stateFile())
+	if err != nil {
+		return -1
+	}
+
+	savedBootID, seq, found := strings.Cut(strings.TrimSpace(string(data)), " ")
+	if !found || savedBootID != bootID {
+		return -1
+	}
+
+	num, err := convert.Int64E(seq)
+	if err != nil {
+		return -1
+	}
+
+	return num
+}
+
+func (l *CheckDmesg) saveSeq(bootID string, seq int64) {
+	err := os.WriteFile(l.stateFile(), fmt.Appendf(nil, "%s %d\n", bootID, seq), 0o600)
+	if err != nil {
+		log.Warnf("failed to save dmesg sequence number: %s", err.Error())
+	}
+}
+
+// readVMStat returns a counter from /proc/vmstat
+func (l *CheckDmesg) readVMStat(name string) (int64, bool) {
+	file, err := os.Open(dmesgVMStatFile)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, val, found := strings.Cut(scanner.Text(), " ")
+		if found && key == name {
+			num, err := convert.Int64E(strings.TrimSpace(val))
+
+			return num, err == nil
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,56 @@
+package snclient
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDmesg(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	t.Setenv("CACHE_DIRECTORY", tmpDir)
+	origKmsg, origVMStat, origBootID := dmesgKmsgFile, dmesgVMStatFile, dmesgBootIDFile
+	dmesgKmsgFile = filepath.Join(tmpDir, "kmsg")
+	dmesgVMStatFile = filepath.Join(tmpDir, "vmstat")
+	dmesgBootIDFile = filepath.Join(tmpDir, "boot_id")
+	defer func() { dmesgKmsgFile, dmesgVMStatFile, dmesgBootIDFile = origKmsg, origVMStat, origBootID }()
+
+	writeTestFile(t, dmesgBootIDFile, "0f6c2a5e-52e4-4b0e-9d7a-3f1c5bba1111\n")
+	writeTestFile(t, dmesgVMStatFile, "nr_free_pages 1234\noom_kill 1\nnr_zone_active_file 42\n")
+	writeTestFile(t, dmesgKmsgFile, `6,1,0,-;Linux version 6.8.0-45-generic (buildd@lcy02-amd64-075)
+ SUBSYSTEM=cpu
+6,2,2301234,-;usb 1-1: new high-speed USB device number 2 using xhci_hcd
+3,3,8815210012,-;Out of memory: Killed process 4321 (java) total-vm:8123456kB, anon-rss:4012345kB
+6,4,9120030011,-;python3[9876]: segfault at 0 ip 00007f1c2a3b4c5d sp 00007ffd1234abcd error 4 in libc.so.6
+`)
+
+	res := snc.RunCheck("check_dmesg", []string{})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - warning(oom_kill: Out of memory: Killed process 4321 (java) total-vm:8123456kB, anon-rss:4012345kB, "+
+		"segfault: python3[9876]: segfault at 0 ip 00007f1c2a3b4c5d sp 00007ffd1234abcd error 4 in libc.so.6) |'oom_kill'=1c",
+		string(res.BuildPluginOutput()), "output matches")
+
+	// already reported messages are skipped on the next run
+	res = snc.RunCheck("check_dmesg", []string{})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - no new kernel messages |'oom_kill'=1c", string(res.BuildPluginOutput()), "output matches")
+
+	writeTestFile(t, dmesgKmsgFile, `6,4,9120030011,-;python3[9876]: segfault at 0 ip 00007f1c2a3b4c5d sp 00007ffd1234abcd error 4 in libc.so.6
+3,5,9200000000,-;blk_update_request: I/O error, dev sdb, sector 123456 op 0x0:(READ) flags 0x0 phys_seg 1 prio class 0
+4,6,9200000100,-;EXT4-fs warning (device sda1): ext4_dx_add_entry: Directory index full!
+`)
+	res = snc.RunCheck("check_dmesg", []string{"filter=none", "show-all", "detail-syntax=${seq} ${facility}.${level} ${category}"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - 5 kern.err io_error, 6 kern.warning other |'oom_kill'=1c",
+		string(res.BuildPluginOutput()), "output matches")
+
+	// sequence numbers start over after a reboot
+	writeTestFile(t, dmesgBootIDFile, "2d1e7f0a-0c1b-4e8a-b2b5-7e7e3c2b2222\n")
+	res = snc.RunCheck("check_dmesg", []string{"warn=none", "crit=category = 'segfault'"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "segfault: python3[9876]", "output matches")
+}
@@ -24,6 +24,24 @@ Check for specific type from specific server:
 
     check_dns -H consol.de -q MX -s 1.1.1.1
     OK - consol.de returns mail.consol.de. (MX)
+
+Validate the DNSSEC chain of trust up to the root zone and warn 7 days before a signature expires:
+
+    check_dns -H www.example.com --dnssec
+    OK - www.example.com returns 1.2.3.4 (A) - DNSSEC valid, 3 signatures verified, first signature expires in 12 days |time=0.004s;; dnssec_expiry_days=12;7;3
+
+Query an encrypted resolver with dns-over-https or dns-over-tls:
+
+    check_dns -H labs.consol.de -s https://cloudflare-dns.com/dns-query --transport doh
+    OK - labs.consol.de returns 94.185.89.33 (A) via DoH (TLS 1.3) |time=0.031s;; tls_handshake=0.012s tls_version=1.3
+
+    check_dns -H labs.consol.de -s 1.1.1.1 --transport dot
+    OK - labs.consol.de returns 94.185.89.33 (A) via DoT (TLS 1.3) |time=0.025s;; tls_handshake=0.011s tls_version=1.3
+
+Check that all authoritative nameservers of a zone return the same SOA serial:
+
+    check_dns -H example.com --soa-consistency
+    OK - example.com SOA serial 2024010101 is consistent on 2 nameservers |time=0.012s;; nameservers=2
 	`,
 		exampleArgs: `'-H' 'omd.consol.de'`,
 	}
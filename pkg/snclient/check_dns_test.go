@@ -3,11 +3,19 @@
 package snclient
 
 import (
+	"crypto"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
@@ -521,3 +529,353 @@ CheckBuiltinPlugins = enabled
 
 	StopTestAgent(t, snc)
 }
+
+// signTestRRset signs the rrset with the given zone key, the signature is valid for the given duration
+func signTestRRset(t *testing.T, key *dns.DNSKEY, privateKey crypto.PrivateKey, validity time.Duration, rrset ...dns.RR) *dns.RRSIG {
+	t.Helper()
+
+	signer, ok := privateKey.(crypto.Signer)
+	require.True(t, ok, "private key is a signer")
+
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(validity).Unix()),
+		KeyTag:     key.KeyTag(),
+		SignerName: key.Hdr.Name,
+		Algorithm:  key.Algorithm,
+	}
+	require.NoError(t, sig.Sign(signer, rrset))
+
+	return sig
+}
+
+func TestCheckDNSSEC(t *testing.T) {
+	config := `
+[/modules]
+CheckBuiltinPlugins = enabled
+	`
+	snc := StartTestAgent(t, config)
+
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	privateKey, err := key.Generate(256)
+	require.NoError(t, err)
+
+	otherKey := &dns.DNSKEY{Hdr: key.Hdr, Flags: 257, Protocol: 3, Algorithm: dns.ECDSAP256SHA256}
+	_, err = otherKey.Generate(256)
+	require.NoError(t, err)
+
+	rootKey := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: ".", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	rootPrivateKey, err := rootKey.Generate(256)
+	require.NoError(t, err)
+
+	delegation := key.ToDS(dns.SHA256)
+
+	// ample.com. is a valid zone, but its name is only a string suffix of example.com.
+	foreignKey := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "ample.com.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	foreignPrivateKey, err := foreignKey.Generate(256)
+	require.NoError(t, err)
+	foreignDelegation := foreignKey.ToDS(dns.SHA256)
+
+	newA := func(name, address string) dns.RR {
+		rr, rrErr := dns.NewRR(name + " 3600 IN A " + address)
+		require.NoError(t, rrErr)
+
+		return rr
+	}
+
+	signed := newA("www.example.com.", "1.2.3.4")
+	expiring := newA("expiring.example.com.", "1.2.3.5")
+	unsigned := newA("unsigned.example.com.", "1.2.3.6")
+	tampered := newA("tampered.example.com.", "1.2.3.7")
+	tamperedSig := signTestRRset(t, key, privateKey, 30*24*time.Hour, tampered)
+	tampered.(*dns.A).A = net.ParseIP("6.6.6.6")
+	foreign := newA("foreign.example.com.", "1.2.3.8")
+
+	answers := map[string][]dns.RR{
+		"www.example.com.":      {signed, signTestRRset(t, key, privateKey, 30*24*time.Hour, signed)},
+		"expiring.example.com.": {expiring, signTestRRset(t, key, privateKey, 5*24*time.Hour, expiring)},
+		"unsigned.example.com.": {unsigned},
+		"tampered.example.com.": {tampered, tamperedSig},
+		"example.com.":          {key, signTestRRset(t, key, privateKey, 60*24*time.Hour, key), delegation, signTestRRset(t, rootKey, rootPrivateKey, 5*24*time.Hour, delegation)},
+		"foreign.example.com.":  {foreign, signTestRRset(t, foreignKey, foreignPrivateKey, 30*24*time.Hour, foreign)},
+		"ample.com.":            {foreignKey, signTestRRset(t, foreignKey, foreignPrivateKey, 60*24*time.Hour, foreignKey), foreignDelegation, signTestRRset(t, rootKey, rootPrivateKey, 60*24*time.Hour, foreignDelegation)},
+		".":                     {rootKey, signTestRRset(t, rootKey, rootPrivateKey, 6*24*time.Hour, rootKey)},
+	}
+
+	port := startTestDNSServerHandler(t, "127.0.0.1:0", dns.HandlerFunc(func(writer dns.ResponseWriter, req *dns.Msg) {
+		reply := new(dns.Msg)
+		answer, ok := answers[req.Question[0].Name]
+		if !ok {
+			reply.SetRcode(req, dns.RcodeNameError)
+		} else {
+			reply.SetReply(req)
+			for _, rr := range answer {
+				rrType := rr.Header().Rrtype
+				if sig, isSig := rr.(*dns.RRSIG); isSig {
+					rrType = sig.TypeCovered
+				}
+				if rrType == req.Question[0].Qtype {
+					reply.Answer = append(reply.Answer, rr)
+				}
+			}
+		}
+		_ = writer.WriteMsg(reply)
+	}))
+
+	anchor := key.ToDS(dns.SHA256).String()
+	args := func(host string, extra ...string) []string {
+		return append([]string{"-H", host, "-s", "127.0.0.1", "-p", port, "--dnssec", "--trust-anchor", anchor}, extra...)
+	}
+
+	t.Run("valid chain", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", args("www.example.com."))
+		assert.Equalf(t, CheckExitOK, res.State, "state ok")
+		assert.Regexpf(
+			t,
+			`^OK - www\.example\.com\. returns 1\.2\.3\.4 \(A\) - DNSSEC valid, 2 signatures verified, first signature expires in 29 days \|time=[\d.]+s;; dnssec_expiry_days=29;7;3`,
+			string(res.BuildPluginOutput()),
+			"output matches",
+		)
+	})
+
+	t.Run("valid chain from root", func(t *testing.T) {
+		// the short lived signatures of the parent zones do not count for the expiry
+		res := snc.RunCheck("check_dns", []string{"-H", "www.example.com.", "-s", "127.0.0.1", "-p", port, "--dnssec", "--trust-anchor", rootKey.ToDS(dns.SHA256).String()})
+		assert.Equalf(t, CheckExitOK, res.State, "state ok")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "DNSSEC valid, 4 signatures verified, first signature expires in 29 days", "output matches")
+	})
+
+	t.Run("signature expires soon", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", args("expiring.example.com."))
+		assert.Equalf(t, CheckExitWarning, res.State, "state warning")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "first signature expires in 4 days", "output matches")
+
+		res = snc.RunCheck("check_dns", args("expiring.example.com.", "--dnssec-critical", "5"))
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+	})
+
+	t.Run("wrong trust anchor", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", []string{"-H", "www.example.com.", "-s", "127.0.0.1", "-p", port, "--dnssec", "--trust-anchor", otherKey.ToDS(dns.SHA256).String()})
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "DNSSEC validation failed: DNSKEY of example.com. does not match the trust anchor", "output matches")
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", args("unsigned.example.com."))
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "DNSSEC validation failed: no RRSIG for unsigned.example.com. A", "output matches")
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", args("tampered.example.com."))
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "DNSSEC validation failed: tampered.example.com. A: invalid signature from key", "output matches")
+	})
+
+	t.Run("foreign signer", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", []string{"-H", "foreign.example.com.", "-s", "127.0.0.1", "-p", port, "--dnssec", "--trust-anchor", rootKey.ToDS(dns.SHA256).String()})
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "DNSSEC validation failed: foreign.example.com. A is signed by ample.com. which is not a parent zone", "output matches")
+	})
+
+	t.Run("untrusted root", func(t *testing.T) {
+		// uses the default root trust anchors
+		res := snc.RunCheck("check_dns", []string{"-H", "www.example.com.", "-s", "127.0.0.1", "-p", port, "--dnssec"})
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "DNSSEC validation failed: DNSKEY of . does not match the trust anchor", "output matches")
+	})
+
+	StopTestAgent(t, snc)
+}
+
+// startTestSOAServers starts two authoritative nameservers for example.com on 127.0.0.1 and 127.0.0.2 with the given serials.
+// It returns the port both servers are listening on.
+func startTestSOAServers(t *testing.T, serial1, serial2 uint32) string {
+	t.Helper()
+
+	handler := func(address string, serial uint32) dns.Handler {
+		return dns.HandlerFunc(func(writer dns.ResponseWriter, req *dns.Msg) {
+			reply := new(dns.Msg)
+			reply.SetReply(req)
+			reply.Authoritative = true
+			switch req.Question[0].Qtype {
+			case dns.TypeNS:
+				for i, ns := range []string{"ns1.example.com.", "ns2.example.com."} {
+					rr, _ := dns.NewRR("example.com. 3600 IN NS " + ns)
+					reply.Answer = append(reply.Answer, rr)
+					rr, _ = dns.NewRR(fmt.Sprintf("%s 3600 IN A 127.0.0.%d", ns, i+1))
+					reply.Extra = append(reply.Extra, rr)
+				}
+			case dns.TypeSOA:
+				rr, _ := dns.NewRR(fmt.Sprintf("example.com. 3600 IN SOA %s hostmaster.example.com. %d 3600 600 86400 300", address, serial))
+				reply.Answer = append(reply.Answer, rr)
+			}
+			_ = writer.WriteMsg(reply)
+		})
+	}
+
+	port := startTestDNSServerHandler(t, "127.0.0.1:0", handler("ns1.example.com.", serial1))
+	pc, err := net.ListenPacket("udp", "127.0.0.2:"+port)
+	if err != nil {
+		t.Skipf("cannot listen on 127.0.0.2: %s", err)
+	}
+	_ = pc.Close()
+	startTestDNSServerHandler(t, "127.0.0.2:"+port, handler("ns2.example.com.", serial2))
+
+	return port
+}
+
+func TestCheckDNSSOAConsistency(t *testing.T) {
+	config := `
+[/modules]
+CheckBuiltinPlugins = enabled
+	`
+	snc := StartTestAgent(t, config)
+
+	t.Run("serials match", func(t *testing.T) {
+		port := startTestSOAServers(t, 2024010101, 2024010101)
+		res := snc.RunCheck("check_dns", []string{"-H", "example.com", "-s", "127.0.0.1", "-p", port, "--soa-consistency"})
+		assert.Equalf(t, CheckExitOK, res.State, "state ok")
+		assert.Regexpf(
+			t,
+			`^OK - example\.com SOA serial 2024010101 is consistent on 2 nameservers \|time=[\d.]+s;; nameservers=2$`,
+			string(res.BuildPluginOutput()),
+			"output matches",
+		)
+	})
+
+	t.Run("serials differ", func(t *testing.T) {
+		port := startTestSOAServers(t, 2024010101, 2024010102)
+		res := snc.RunCheck("check_dns", []string{"-H", "example.com", "-s", "127.0.0.1", "-p", port, "--soa-consistency"})
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+		output := string(res.BuildPluginOutput())
+		assert.Regexpf(t, `^CRITICAL - example\.com SOA serials differ \|`, output, "output matches")
+		assert.Containsf(t, output, "ns1.example.com. (127.0.0.1): 2024010101\nns2.example.com. (127.0.0.2): 2024010102", "serials are listed")
+	})
+
+	t.Run("dnssec and soa consistency", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", []string{"-H", "example.com", "--soa-consistency", "--dnssec"})
+		assert.Equalf(t, CheckExitUnknown, res.State, "state unknown")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "--dnssec and --soa-consistency cannot be used together", "output matches")
+	})
+
+	StopTestAgent(t, snc)
+}
+
+func TestCheckDNSTransport(t *testing.T) {
+	config := `
+[/modules]
+CheckBuiltinPlugins = enabled
+	`
+	snc := StartTestAgent(t, config)
+
+	answer := func(req *dns.Msg) *dns.Msg {
+		reply := new(dns.Msg)
+		reply.SetReply(req)
+		rr, err := dns.NewRR(req.Question[0].Name + " 60 IN A 1.2.3.4")
+		require.NoError(t, err)
+		reply.Answer = append(reply.Answer, rr)
+
+		return reply
+	}
+
+	dohServer := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		var packed []byte
+		var err error
+		switch req.Method {
+		case http.MethodGet:
+			packed, err = base64.RawURLEncoding.DecodeString(req.URL.Query().Get("dns"))
+		default:
+			packed, err = io.ReadAll(req.Body)
+		}
+		query := new(dns.Msg)
+		if err != nil || query.Unpack(packed) != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		reply, err := answer(query).Pack()
+		require.NoError(t, err)
+		writer.Header().Set("Content-Type", "application/dns-message")
+		_, _ = writer.Write(reply)
+	}))
+	dohServer.Config.ErrorLog = NewStandardLog("TRACE")
+	defer dohServer.Close()
+
+	// borrow the self-signed certificate of the httptest server for dns-over-tls
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: dohServer.TLS.Certificates, MinVersion: tls.VersionTLS12})
+	require.NoError(t, err)
+	dotServer := &dns.Server{
+		Listener: listener,
+		Handler: dns.HandlerFunc(func(writer dns.ResponseWriter, req *dns.Msg) {
+			_ = writer.WriteMsg(answer(req))
+		}),
+	}
+	go func() {
+		_ = dotServer.ActivateAndServe()
+	}()
+	defer func() { _ = dotServer.Shutdown() }()
+
+	tcpAddr, ok := listener.Addr().(*net.TCPAddr)
+	require.True(t, ok, "listener addr is a tcp addr")
+	dotPort := strconv.Itoa(tcpAddr.Port)
+
+	t.Run("dns over https post", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", []string{"-H", "doh.example.com.", "-s", dohServer.URL + "/dns-query", "--transport", "doh", "--insecure"})
+		assert.Equalf(t, CheckExitOK, res.State, "state ok")
+		assert.Regexpf(
+			t,
+			`^OK - doh\.example\.com\. returns 1\.2\.3\.4 \(A\) via DoH \(TLS 1\.3\) \|time=[\d.]+s;; tls_handshake=[\d.]+s tls_version=1\.3`,
+			string(res.BuildPluginOutput()),
+			"output matches",
+		)
+	})
+
+	t.Run("dns over https get with expected string", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", []string{"-H", "doh.example.com.", "-s", dohServer.URL + "/dns-query", "--transport", "doh", "--doh-method", "GET", "--insecure", "-e", "1.2.3.5"})
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+		assert.Regexpf(t, `^CRITICAL - doh\.example\.com\. returns 1\.2\.3\.4 \(A\) via DoH`, string(res.BuildPluginOutput()), "output matches")
+	})
+
+	t.Run("dns over https certificate verification", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", []string{"-H", "doh.example.com.", "-s", dohServer.URL + "/dns-query", "--transport", "doh"})
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "certificate signed by unknown authority", "output matches")
+	})
+
+	t.Run("dns over tls", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", []string{"-H", "dot.example.com.", "-s", "127.0.0.1", "-p", dotPort, "--transport", "dot", "--insecure", "-e", "1.2.3.4"})
+		assert.Equalf(t, CheckExitOK, res.State, "state ok")
+		assert.Regexpf(
+			t,
+			`^OK - dot\.example\.com\. returns 1\.2\.3\.4 \(A\) via DoT \(TLS 1\.3\) \|time=[\d.]+s;; tls_handshake=[\d.]+s tls_version=1\.3`,
+			string(res.BuildPluginOutput()),
+			"output matches",
+		)
+	})
+
+	t.Run("dns over tls certificate verification", func(t *testing.T) {
+		res := snc.RunCheck("check_dns", []string{"-H", "dot.example.com.", "-s", "127.0.0.1", "-p", dotPort, "--transport", "dot"})
+		assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+		assert.Containsf(t, string(res.BuildPluginOutput()), "certificate signed by unknown authority", "output matches")
+	})
+
+	StopTestAgent(t, snc)
+}
@@ -25,6 +25,46 @@ Check for specific string and response code:
     check_http -H omd.consol.de -S -u "/docs/snclient/" -e 200,304 -s "consol" -vvv
     HTTP OK - Status line output "HTTP/2.0 200 OK" matched "200,304", Response body matched "consol"...
 
+Check json or xml responses, numeric values are added as perfdata with optional thresholds:
+
+    check_http -H localhost -p 8080 --uri=/actuator/health --jsonpath '$.status == "UP"' --jsonpath 'count($.components.*) > 0'
+    HTTP OK - HTTP/1.1 200 OK - Response body matched: [jsonpath: '$.status == "UP"' , value: 'UP', ...
+
+    check_http -H localhost --uri=/status.xml --xpath 'queue=//queue/@size;100;500'
+    HTTP OK - HTTP/1.1 200 OK - Response body matched: [xpath: '//queue/@size' , value: '17'] ... queue=17;100;500;;
+
+Run a login transaction first, captured values can be used as ${name} and cookies are kept for all requests:
+
+    check_http -H localhost --uri=/dashboard -s "Welcome" \
+        --step 'POST /login|body=user=monitoring&password=secret|capture=token=jsonpath:$.token|expect=200' \
+        -k 'Authorization: Bearer ${token}'
+    HTTP OK - HTTP/1.1 200 OK - Transaction steps: [step1: 200] - Response body matched: [string: 'Welcome'] ...
+
+Steps can also be read from a yaml file with --step-file:
+
+    - name: login
+      method: POST
+      uri: /login
+      body: user=monitoring&password=secret
+      capture:
+        csrf: regex:name="csrf" value="([^"]+)"
+        session: cookie:JSESSIONID
+      expect: 200,302
+
+Each request phase is added as perfdata and can have its own thresholds, use -v to show the negotiated protocol and cipher:
+
+    check_http -H omd.consol.de -S --warning-tls 0.5 --warning-ttfb 1 --critical-ttfb 3 -v
+    HTTP OK - HTTP/2.0 200 OK - 573 bytes in 0.112s response time | time=0.112s;30.000;60.000;0; size=573B;;;0; time_dns=0.004s;;;0; ...
+    protocol: HTTP/2.0, tls version: TLS 1.3, cipher: TLS_AES_128_GCM_SHA256, alpn: h2
+
+Use a client certificate (pem or pkcs#12) and a bearer token from an oauth2 client credentials grant:
+
+    check_http -H api.example.com -S --client-cert /etc/snclient/client.p12 --client-cert-password-file /etc/snclient/client.pass \
+        --oauth2-token-url https://sso.example.com/token --oauth2-client-id monitoring --oauth2-client-secret-file /etc/snclient/oauth2.secret
+    HTTP OK - HTTP/1.1 200 OK - 1421 bytes in 0.082 second response time | ...
+
+Oauth2 tokens are cached until they expire.
+
 It can be a bit tricky to set the -u/--uri on windows, since the / is considered as start of
 a command line parameter.
 
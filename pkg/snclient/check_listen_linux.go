@@ -0,0 +1,270 @@
+package snclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
+)
+
+func init() {
+	AvailableChecks["check_listen"] = CheckEntry{"check_listen", NewCheckListen}
+}
+
+var listenProcPath = "/proc"
+
+type CheckListen struct {
+	ports     []string
+	processes []string
+	userCache map[string]string
+}
+
+func NewCheckListen() CheckHandler {
+	return &CheckListen{}
+}
+
+func (l *CheckListen) Build() *CheckData {
+	return &CheckData{
+		name:         "check_listen",
+		description:  "Checks listening sockets and established connections along with the owning process.",
+		implemented:  Linux,
+		hasInventory: ListInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"port":    {value: &l.ports, description: "Show sockets with this local port only"},
+			"process": {value: &l.processes, description: "Show sockets owned by this process name only"},
+		},
+		defaultFilter: "state = 'listen'",
+		detailSyntax:  "${protocol} ${local} ${process}",
+		okSyntax:      "%(status) - %{count} socket(s) found",
+		topSyntax:     "%(status) - %(problem_list)",
+		emptyState:    CheckExitCritical,
+		emptySyntax:   "%(status) - no sockets found with this filter.",
+		attributes: []CheckAttribute{
+			{name: "protocol", description: "Protocol: tcp or udp"},
+			{name: "inet", description: "Address family: ipv4 or ipv6"},
+			{name: "local", description: "Local address and port, ex.: 0.0.0.0:22"},
+			{name: "local_address", description: "Local ip address"},
+			{name: "local_port", description: "Local port"},
+			{name: "port", description: "Alias for local_port"},
+			{name: "remote", description: "Remote address and port"},
+			{name: "remote_address", description: "Remote ip address"},
+			{name: "remote_port", description: "Remote port"},
+			{name: "state", description: "Socket state, ex.: listen, established or time_wait (unconnected udp sockets are listen)"},
+			{name: "inode", description: "Inode number of the socket"},
+			{name: "uid", description: "User id of the socket owner"},
+			{name: "user", description: "User name of the socket owner"},
+			{name: "pid", description: "Process id of the owning process (requires root permissions for processes of other users)"},
+			{name: "process", description: "Process name of the owning process"},
+		},
+		exampleDefault: `
+    check_listen
+    OK - 12 socket(s) found |'count'=12;;;0
+
+Check if something listens on port 443:
+
+    check_listen port=443
+    OK - 1 socket(s) found |'count'=1;;;0
+
+Make sure port 22 is served by sshd:
+
+    check_listen port=22 crit="process != 'sshd'"
+    OK - 2 socket(s) found |'count'=2;;;0
+
+List established connections:
+
+    check_listen filter="state = 'established'" show-all
+    OK - tcp 10.0.0.1:22 sshd, tcp 10.0.0.1:49152 firefox |'count'=2;;;0
+	`,
+		exampleArgs: `port=22 crit="count == 0 || process != 'sshd'"`,
+	}
+}
+
+func (l *CheckListen) Check(_ context.Context, _ *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	l.userCache = map[string]string{}
+
+	sockets := []map[string]string{}
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		entries, err := l.getSockets(proto)
+		if err != nil {
+			// ipv6 may be disabled
+			if os.IsNotExist(err) && strings.HasSuffix(proto, "6") {
+				log.Debugf("skipping %s: %s", proto, err.Error())
+
+				continue
+			}
+
+			return nil, err
+		}
+		sockets = append(sockets, entries...)
+	}
+
+	l.addProcessInfo(sockets)
+
+	for _, entry := range sockets {
+		if len(l.ports) > 0 && !slices.Contains(l.ports, entry["local_port"]) {
+			continue
+		}
+		if len(l.processes) > 0 && !slices.Contains(l.processes, entry["process"]) {
+			continue
+		}
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+		check.listData = append(check.listData, entry)
+	}
+
+	check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+		ThresholdName: "count",
+		Name:          "count",
+		Value:         len(check.listData),
+		Warning:       check.warnThreshold,
+		Critical:      check.critThreshold,
+		Min:           &Zero,
+	})
+
+	return check.Finalize()
+}
+
+// getSockets parses /proc/net/<proto>
+func (l *CheckListen) getSockets(proto string) ([]map[string]string, error) {
+	file := filepath.Join(listenProcPath, "net", proto)
+	procFile, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", file, err)
+	}
+	defer procFile.Close()
+
+	protocol := strings.TrimSuffix(proto, "6")
+	inet := "ipv4"
+	if strings.HasSuffix(proto, "6") {
+		inet = "ipv6"
+	}
+
+	sockets := []map[string]string{}
+	fileScanner := bufio.NewScanner(procFile)
+	fileScanner.Scan() // skip first header line
+	for fileScanner.Scan() {
+		line := fileScanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			log.Debugf("corrupt %s line: %s", proto, line)
+
+			continue
+		}
+
+		localAddr, localPort := parseProcNetAddress(fields[1])
+		remoteAddr, remotePort := parseProcNetAddress(fields[2])
+		stateNum, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil {
+			log.Debugf("cannot parse %s state %s: %s", proto, fields[3], err.Error())
+
+			continue
+		}
+		tcpState := tcpStates(convert.UInt16(stateNum))
+		state := tcpState.String()
+		if protocol == "udp" {
+			// udp sockets use tcp states, unconnected sockets are in state close
+			state = "listen"
+			if tcpState == tcpEstablished {
+				state = "established"
+			}
+		}
+
+		sockets = append(sockets, map[string]string{
+			"protocol":       protocol,
+			"inet":           inet,
+			"local":          net.JoinHostPort(localAddr, fmt.Sprintf("%d", localPort)),
+			"local_address":  localAddr,
+			"local_port":     fmt.Sprintf("%d", localPort),
+			"port":           fmt.Sprintf("%d", localPort),
+			"remote":         net.JoinHostPort(remoteAddr, fmt.Sprintf("%d", remotePort)),
+			"remote_address": remoteAddr,
+			"remote_port":    fmt.Sprintf("%d", remotePort),
+			"state":          state,
+			"uid":            fields[7],
+			"user":           l.lookupUser(fields[7]),
+			"inode":          fields[9],
+			"pid":            "",
+			"process":        "",
+		})
+	}
+
+	if err := fileScanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan %s: %s", file, err.Error())
+	}
+
+	return sockets, nil
+}
+
+// addProcessInfo resolves the owning process of each socket by scanning /proc/*/fd
+func (l *CheckListen) addProcessInfo(sockets []map[string]string) {
+	inodes := map[string][]map[string]string{}
+	for _, entry := range sockets {
+		if entry["inode"] == "0" {
+			continue
+		}
+		inodes[entry["inode"]] = append(inodes[entry["inode"]], entry)
+	}
+	if len(inodes) == 0 {
+		return
+	}
+
+	fds, _ := filepath.Glob(filepath.Join(listenProcPath, "[0-9]*", "fd", "*"))
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil {
+			continue
+		}
+		inode, ok := strings.CutPrefix(link, "socket:[")
+		if !ok {
+			continue
+		}
+		entries, ok := inodes[strings.TrimSuffix(inode, "]")]
+		if !ok {
+			continue
+		}
+
+		procDir := filepath.Dir(filepath.Dir(fd))
+		pid := filepath.Base(procDir)
+		process := pid
+		comm, err := os.ReadFile(filepath.Join(procDir, "comm"))
+		if err == nil {
+			process = strings.TrimSpace(string(comm))
+		}
+
+		for _, entry := range entries {
+			// sockets shared between processes (ex.: forked workers) keep the first process found
+			if entry["pid"] != "" {
+				continue
+			}
+			entry["pid"] = pid
+			entry["process"] = process
+		}
+	}
+}
+
+func (l *CheckListen) lookupUser(uid string) string {
+	if name, ok := l.userCache[uid]; ok {
+		return name
+	}
+
+	name := uid
+	usr, err := user.LookupId(uid)
+	if err == nil {
+		name = usr.Username
+	}
+	l.userCache[uid] = name
+
+	return name
+}
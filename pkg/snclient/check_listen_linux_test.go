@@ -0,0 +1,83 @@
+package snclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckListen(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	origProcPath := listenProcPath
+	listenProcPath = tmpDir
+	defer func() { listenProcPath = origProcPath }()
+
+	// sshd listening on 0.0.0.0:22 and [::]:22 with one established connection,
+	// nginx on 127.0.0.1:443 and an unconnected udp socket on port 53
+	writeTestFile(t, filepath.Join(tmpDir, "net", "tcp"), `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:01BB 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 100 0 0 10 0
+   2: 0F02000A:0016 0202000A:C350 01 00000000:00000000 02:0004D3D4 00000000     0        0 1003 4 0000000000000000 20 4 31 10 -1
+`)
+	writeTestFile(t, filepath.Join(tmpDir, "net", "tcp6"), `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1004 1 0000000000000000 100 0 0 10 0
+`)
+	writeTestFile(t, filepath.Join(tmpDir, "net", "udp"), `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  100: 3500007F:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 1005 2 0000000000000000 0
+`)
+	writeTestFile(t, filepath.Join(tmpDir, "net", "udp6"), `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+`)
+
+	addTestProcess(t, tmpDir, "100", "sshd", "1001", "1004")
+	addTestProcess(t, tmpDir, "200", "nginx", "1002")
+	addTestProcess(t, tmpDir, "300", "sshd-session", "1003")
+	addTestProcess(t, tmpDir, "400", "systemd-resolve", "1005")
+
+	res := snc.RunCheck("check_listen", []string{})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - 4 socket(s) found |'count'=4;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_listen", []string{"port=22", "crit=count == 0 || process != 'sshd'"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - 2 socket(s) found |'count'=2;;0;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_listen", []string{"port=443", "crit=count == 0 || process != 'sshd'"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - tcp 127.0.0.1:443 nginx |'count'=1;;0;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_listen", []string{"port=8080"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - no sockets found with this filter. |'count'=0;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_listen", []string{"filter=protocol = 'udp'", "show-all", "detail-syntax=${protocol} ${local} ${process} (${pid})"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - udp 127.0.0.53:53 systemd-resolve (400) |'count'=1;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_listen", []string{"filter=state = 'established'", "show-all", "detail-syntax=${local} -> ${remote} ${process}"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - 10.0.2.15:22 -> 10.0.2.2:50000 sshd-session |'count'=1;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_listen", []string{"filter=inet = 'ipv6'", "show-all"})
+	assert.Equalf(t, "OK - tcp [::]:22 sshd |'count'=1;;;0", string(res.BuildPluginOutput()), "output matches")
+}
+
+// addTestProcess creates a fake /proc/<pid> folder with socket file descriptors
+func addTestProcess(t *testing.T, procPath, pid, comm string, inodes ...string) {
+	t.Helper()
+
+	writeTestFile(t, filepath.Join(procPath, pid, "comm"), comm+"\n")
+	for i, inode := range inodes {
+		fdPath := filepath.Join(procPath, pid, "fd")
+		err := os.MkdirAll(fdPath, 0o700)
+		require.NoError(t, err)
+		err = os.Symlink("socket:["+inode+"]", filepath.Join(fdPath, fmt.Sprintf("%d", i+3)))
+		require.NoError(t, err)
+	}
+}
@@ -2,10 +2,12 @@ package snclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/consol-monitoring/snclient/pkg/utils"
 	"github.com/shirou/gopsutil/v4/disk"
@@ -15,14 +17,24 @@ func init() {
 	AvailableChecks["check_mount"] = CheckEntry{"check_mount", NewCheckMount}
 }
 
+// mountNetworkFsTypes contains filesystems which are probed with a timeout to detect hung servers
+var mountNetworkFsTypes = []string{
+	"nfs", "nfs4", "cifs", "smb3", "smbfs", "ceph", "glusterfs", "fuse.glusterfs",
+	"fuse.sshfs", "fuse.s3fs", "davfs", "fuse.davfs2", "9p", "afs", "lustre",
+}
+
 type CheckMount struct {
 	mountPoints   []string
 	expectOptions string
 	expectFSType  string
+	fstab         bool
+	probeTimeout  float64
 }
 
 func NewCheckMount() CheckHandler {
-	return &CheckMount{}
+	return &CheckMount{
+		probeTimeout: 5,
+	}
 }
 
 func (l *CheckMount) Build() *CheckData {
@@ -35,15 +47,17 @@ func (l *CheckMount) Build() *CheckData {
 			State: CheckExitOK,
 		},
 		args: map[string]CheckArgument{
-			"mount":   {value: &l.mountPoints, description: "The mount point to check"},
-			"options": {value: &l.expectOptions, description: "The mount options to expect"},
-			"fstype":  {value: &l.expectFSType, description: "The fstype to expect"},
+			"mount":         {value: &l.mountPoints, description: "The mount point to check"},
+			"options":       {value: &l.expectOptions, description: "The mount options to expect"},
+			"fstype":        {value: &l.expectFSType, description: "The fstype to expect"},
+			"fstab":         {value: &l.fstab, description: "Compare all mounts from /etc/fstab and enabled systemd mount units with the mounted filesystems (Unix only)"},
+			"probe-timeout": {value: &l.probeTimeout, description: "Timeout in seconds for probing network filesystems, 0 disables the probe (default: 5)"},
 		},
 		detailSyntax:    "mount ${mount} ${issues}",
 		okSyntax:        "${status} - ${count} mount(s) found",
 		topSyntax:       "${status} - ${problem_list}",
 		defaultWarning:  "issues != ''",
-		defaultCritical: "issues like 'not mounted'",
+		defaultCritical: "issues like 'not mounted' || probe in ('timeout', 'failed')",
 		emptyState:      3,
 		emptySyntax:     "check_mount failed to find anything with this filter.",
 		attributes: []CheckAttribute{
@@ -52,6 +66,7 @@ func (l *CheckMount) Build() *CheckData {
 			{name: "device", description: "Device of this mount"},
 			{name: "fstype", description: "FS type for this mount"},
 			{name: "issues", description: "Issues found"},
+			{name: "probe", description: "Result of the network filesystem probe: ok, timeout, failed or empty if not probed"},
 		},
 		exampleDefault: `
 	check_mount mount=/ options=rw,relatime fstype=ext4
@@ -59,6 +74,18 @@ func (l *CheckMount) Build() *CheckData {
 
 	check_mount mount=X:
 	CRITICAL - mount X: not mounted
+
+Compare /etc/fstab with the mounted filesystems. Entries with noauto are skipped, missing entries with nofail are a warning only
+and mounted filesystems without fstab entry are reported as well:
+
+	check_mount fstab
+	CRITICAL - critical(mount /data not mounted) warning(mount /mnt/backup missing (nofail))
+
+Network filesystems like nfs or cifs are probed with statfs in the background. A server which does not answer within the
+probe-timeout results in a critical state instead of blocking the check:
+
+	check_mount mount=/mnt/nfs
+	CRITICAL - mount /mnt/nfs hung: statfs did not return within 5s
 	`,
 		exampleArgs: `'mount=/' 'options=rw,relatime'`,
 	}
@@ -66,8 +93,16 @@ func (l *CheckMount) Build() *CheckData {
 
 //nolint:funlen // no need to split this up
 func (l *CheckMount) Check(ctx context.Context, _ *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	if l.fstab {
+		if err := l.checkFstab(ctx, check); err != nil {
+			return nil, err
+		}
+
+		return check.Finalize()
+	}
+
 	if check.output != OutputInventory && len(l.mountPoints) == 0 && l.expectOptions == "" && l.expectFSType == "" {
-		return nil, fmt.Errorf("must specify at least one of mount/options/fstype")
+		return nil, fmt.Errorf("must specify at least one of mount/options/fstype/fstab")
 	}
 
 	for idx := range l.mountPoints {
@@ -123,6 +158,11 @@ func (l *CheckMount) Check(ctx context.Context, _ *Agent, check *CheckData, _ []
 		if l.expectFSType != "" && !strings.EqualFold(l.expectFSType, partition["fstype"]) {
 			issues = append(issues, fmt.Sprintf("expected fstype differs: %s != %s", l.expectFSType, partition["fstype"]))
 		}
+		if check.output != OutputInventory {
+			if issue := l.probeNetworkMount(entry); issue != "" {
+				issues = append(issues, issue)
+			}
+		}
 		if len(issues) > 0 {
 			entry["issues"] = strings.Join(issues, ", ")
 		}
@@ -139,6 +179,7 @@ func (l *CheckMount) Check(ctx context.Context, _ *Agent, check *CheckData, _ []
 					"fstype":  "",
 					"options": "",
 					"issues":  "not mounted",
+					"probe":   "",
 				}
 				check.listData = append(check.listData, entry)
 			}
@@ -199,6 +240,7 @@ func (l *CheckMount) getDrives(ctx context.Context, partitionMap map[string]bool
 			"fstype":  partition.Fstype,
 			"options": strings.Join(partition.Opts, ","),
 			"issues":  "",
+			"probe":   "",
 		}
 		drives = append(drives, entry)
 	}
@@ -214,3 +256,28 @@ func trimTrailingSeparator(path string) string {
 
 	return path
 }
+
+// probeNetworkMount sets the probe attribute for network filesystems and returns an issue if the probe failed
+func (l *CheckMount) probeNetworkMount(entry map[string]string) string {
+	entry["probe"] = ""
+	if l.probeTimeout <= 0 || !slices.Contains(mountNetworkFsTypes, entry["fstype"]) {
+		return ""
+	}
+
+	timeout := time.Duration(l.probeTimeout * float64(time.Second))
+	err := probeMount(entry["mount"], timeout)
+	switch {
+	case err == nil:
+		entry["probe"] = "ok"
+
+		return ""
+	case errors.Is(err, errMountProbeTimeout):
+		entry["probe"] = "timeout"
+
+		return "hung: " + err.Error()
+	default:
+		entry["probe"] = "failed"
+
+		return err.Error()
+	}
+}
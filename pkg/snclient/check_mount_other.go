@@ -3,9 +3,295 @@
 package snclient
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/consol-monitoring/snclient/pkg/utils"
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+var (
+	mountFstabFile      = "/etc/fstab"
+	mountSystemdUnitDir = "/etc/systemd/system"
+
+	errMountProbeTimeout = errors.New("statfs did not return")
+
+	// mountStatfs is used to probe network filesystems
+	mountStatfs = func(path string) error {
+		stat := syscall.Statfs_t{}
+
+		return syscall.Statfs(path, &stat)
+	}
+
+	// mountProbesPending contains probes which did not return yet, a hung mount is probed only once at a time
+	mountProbesPending sync.Map
 )
 
+// mountProbe is a single statfs call running in the background
+type mountProbe struct {
+	done chan struct{}
+	err  error
+}
+
+// fstabEntry is a mount expected from /etc/fstab or a systemd mount unit
+type fstabEntry struct {
+	device  string
+	mount   string
+	fstype  string
+	options []string
+}
+
 func (l *CheckMount) getVolumes(_ context.Context, _ *CheckData, _ map[string]bool) (drives []map[string]string, err error) {
 	return drives, nil
 }
+
+// probeMount runs statfs in a separate goroutine, because statfs on a hung nfs mount blocks uninterruptible
+func probeMount(path string, timeout time.Duration) error {
+	probe := &mountProbe{done: make(chan struct{})}
+	existing, loaded := mountProbesPending.LoadOrStore(path, probe)
+	if loaded {
+		// previous probe is still hanging, wait for it instead of starting another goroutine
+		if pending, ok := existing.(*mountProbe); ok {
+			probe = pending
+		}
+	} else {
+		go func() {
+			probe.err = mountStatfs(path)
+			mountProbesPending.Delete(path)
+			close(probe.done)
+		}()
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-probe.done:
+		if probe.err != nil {
+			return fmt.Errorf("statfs failed: %s", probe.err.Error())
+		}
+
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%w within %s", errMountProbeTimeout, timeout.String())
+	}
+}
+
+// checkFstab compares the expected mounts from fstab and systemd with the mounted filesystems
+func (l *CheckMount) checkFstab(ctx context.Context, check *CheckData) error {
+	expected, err := l.readFstab(mountFstabFile)
+	if err != nil {
+		return err
+	}
+	expected = append(expected, l.readSystemdMounts(mountSystemdUnitDir)...)
+
+	partitions, err := disk.PartitionsWithContext(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to get mounts: %s", err.Error())
+	}
+	mounted := map[string]*disk.PartitionStat{}
+	for i := range partitions {
+		mounted[trimTrailingSeparator(partitions[i].Mountpoint)] = &partitions[i]
+	}
+
+	entries := []map[string]string{}
+	known := map[string]bool{}
+	for _, exp := range expected {
+		if known[exp.mount] {
+			continue
+		}
+		known[exp.mount] = true
+
+		entry := map[string]string{
+			"mount":   exp.mount,
+			"device":  utils.ReplaceCommonPasswordPattern(exp.device),
+			"fstype":  exp.fstype,
+			"options": strings.Join(exp.options, ","),
+			"issues":  "",
+			"probe":   "",
+		}
+
+		partition, ok := mounted[exp.mount]
+		switch {
+		case ok:
+			entry["device"] = utils.ReplaceCommonPasswordPattern(partition.Device)
+			entry["fstype"] = partition.Fstype
+			entry["options"] = strings.Join(partition.Opts, ",")
+			if exp.fstype != "" && exp.fstype != "auto" && exp.fstype != "none" && !strings.HasPrefix(partition.Fstype, exp.fstype) {
+				entry["issues"] = fmt.Sprintf("expected fstype differs: %s != %s", exp.fstype, partition.Fstype)
+			}
+		case l.hasOption(exp.options, "noauto"):
+			log.Tracef("skipped fstab entry: %s - noauto", exp.mount)
+
+			continue
+		case l.hasOption(exp.options, "nofail"):
+			entry["issues"] = "missing (nofail)"
+		default:
+			entry["issues"] = "not mounted"
+		}
+		entries = append(entries, entry)
+	}
+
+	// mounted filesystems without fstab entry
+	drives, err := l.getDrives(ctx, map[string]bool{})
+	if err != nil {
+		return err
+	}
+	for _, drive := range drives {
+		if known[drive["mount"]] {
+			continue
+		}
+		drive["issues"] = "not in fstab"
+		entries = append(entries, drive)
+	}
+
+	for _, entry := range entries {
+		if entry["issues"] == "" || entry["issues"] == "not in fstab" {
+			if issue := l.probeNetworkMount(entry); issue != "" {
+				entry["issues"] = strings.TrimPrefix(entry["issues"]+", "+issue, ", ")
+			}
+		}
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+		check.listData = append(check.listData, entry)
+	}
+
+	return nil
+}
+
+// readFstab returns all filesystem entries from fstab, swap and pseudo entries are skipped
+func (l *CheckMount) readFstab(file string) ([]fstabEntry, error) {
+	fstab, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %s", file, err.Error())
+	}
+	defer fstab.Close()
+
+	entries := []fstabEntry{}
+	scanner := bufio.NewScanner(fstab)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		if fields[2] == "swap" || fields[1] == "none" || fields[1] == "swap" {
+			continue
+		}
+
+		entry := fstabEntry{
+			device: l.unescapeFstab(fields[0]),
+			mount:  trimTrailingSeparator(l.unescapeFstab(fields[1])),
+			fstype: fields[2],
+		}
+		if len(fields) > 3 {
+			entry.options = strings.Split(fields[3], ",")
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %s", file, err.Error())
+	}
+
+	return entries, nil
+}
+
+// unescapeFstab replaces octal escapes like \040 for spaces
+func (l *CheckMount) unescapeFstab(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	res := strings.Builder{}
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+4 <= len(field) {
+			if num, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				res.WriteByte(byte(num))
+				i += 3
+
+				continue
+			}
+		}
+		res.WriteByte(field[i])
+	}
+
+	return res.String()
+}
+
+// readSystemdMounts returns mount units which are enabled by a .wants or .requires folder of a target
+func (l *CheckMount) readSystemdMounts(unitDir string) []fstabEntry {
+	links := []string{}
+	for _, pattern := range []string{"*.wants/*.mount", "*.requires/*.mount"} {
+		matches, err := filepath.Glob(filepath.Join(unitDir, pattern))
+		if err != nil {
+			continue
+		}
+		links = append(links, matches...)
+	}
+
+	entries := []fstabEntry{}
+	for _, link := range links {
+		data, err := os.ReadFile(link)
+		if err != nil {
+			log.Debugf("cannot read mount unit %s: %s", link, err.Error())
+
+			continue
+		}
+
+		entry := fstabEntry{}
+		section := ""
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "[") {
+				section = line
+
+				continue
+			}
+			key, val, found := strings.Cut(line, "=")
+			if !found || section != "[Mount]" {
+				continue
+			}
+			val = strings.TrimSpace(val)
+			switch strings.TrimSpace(key) {
+			case "What":
+				entry.device = val
+			case "Where":
+				entry.mount = trimTrailingSeparator(val)
+			case "Type":
+				entry.fstype = val
+			case "Options":
+				entry.options = strings.Split(val, ",")
+			}
+		}
+		if entry.mount != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func (l *CheckMount) hasOption(options []string, option string) bool {
+	for _, opt := range options {
+		if opt == option {
+			return true
+		}
+	}
+
+	return false
+}
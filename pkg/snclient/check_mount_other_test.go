@@ -0,0 +1,103 @@
+//go:build !windows
+
+package snclient
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountFstab(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	origFstab, origUnitDir := mountFstabFile, mountSystemdUnitDir
+	mountFstabFile = filepath.Join(tmpDir, "fstab")
+	mountSystemdUnitDir = filepath.Join(tmpDir, "systemd")
+	defer func() { mountFstabFile, mountSystemdUnitDir = origFstab, origUnitDir }()
+
+	writeTestFile(t, mountFstabFile, `# /etc/fstab: static file system information.
+/dev/root                 /                   auto  defaults         0 1
+UUID=1234-abcd            /snc_test\040data   ext4  defaults         0 2
+server:/export            /snc_test_nfs       nfs   defaults,nofail  0 0
+/dev/sdb1                 /snc_test_usb       vfat  noauto,user      0 0
+/swapfile                 none                swap  sw               0 0
+`)
+	writeTestFile(t, filepath.Join(mountSystemdUnitDir, "remote-fs.target.wants", "snc_test_unit.mount"), `[Unit]
+Description=Test Mount
+
+[Mount]
+What=/dev/sdc1
+Where=/snc_test_unit
+Type=xfs
+Options=defaults
+
+[Install]
+WantedBy=remote-fs.target
+`)
+
+	entries, err := (&CheckMount{}).readFstab(mountFstabFile)
+	require.NoError(t, err)
+	require.Lenf(t, entries, 4, "swap entries are skipped")
+	assert.Equalf(t, "/snc_test data", entries[1].mount, "octal escapes are replaced")
+
+	res := snc.RunCheck("check_mount", []string{"fstab", "filter=mount like 'snc_test'"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - critical(mount /snc_test data not mounted, mount /snc_test_unit not mounted) "+
+		"warning(mount /snc_test_nfs missing (nofail))",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_mount", []string{"fstab", "filter=mount = '/'"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - 1 mount(s) found", string(res.BuildPluginOutput()), "output matches")
+}
+
+func TestMountProbe(t *testing.T) {
+	origStatfs := mountStatfs
+	defer func() { mountStatfs = origStatfs }()
+
+	release := make(chan struct{})
+	started := atomic.Int32{}
+	mountStatfs = func(_ string) error {
+		started.Add(1)
+		<-release
+
+		return nil
+	}
+
+	err := probeMount("/snc_test_hung", 50*time.Millisecond)
+	require.Error(t, err)
+	assert.ErrorIsf(t, err, errMountProbeTimeout, "timeout error")
+	assert.Equalf(t, "statfs did not return within 50ms", err.Error(), "error matches")
+
+	// the pending probe is reused instead of starting another statfs call
+	err = probeMount("/snc_test_hung", 50*time.Millisecond)
+	require.Error(t, err)
+	assert.Equalf(t, int32(1), started.Load(), "statfs called once")
+
+	close(release)
+	require.NoError(t, probeMount("/snc_test_hung", time.Second))
+
+	mountStatfs = func(_ string) error {
+		return syscall.ESTALE
+	}
+	err = probeMount("/snc_test_stale", time.Second)
+	require.Error(t, err)
+	assert.Containsf(t, err.Error(), "statfs failed: ", "error matches")
+
+	check := &CheckMount{probeTimeout: 1}
+	entry := map[string]string{"mount": "/snc_test_stale", "fstype": "nfs4"}
+	assert.Equalf(t, err.Error(), check.probeNetworkMount(entry), "issue matches")
+	assert.Equalf(t, "failed", entry["probe"], "probe failed")
+
+	entry = map[string]string{"mount": "/", "fstype": "ext4"}
+	assert.Emptyf(t, check.probeNetworkMount(entry), "local filesystems are not probed")
+	assert.Emptyf(t, entry["probe"], "probe is empty")
+}
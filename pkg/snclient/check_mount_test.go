@@ -39,7 +39,7 @@ func TestMountNoMountArgument(t *testing.T) {
 	// mount= left empty means all mounts are checked
 	res := snc.RunCheck("check_mount", []string{})
 	assert.Equalf(t, CheckExitUnknown, res.State, "state UNKNOWN")
-	assert.Equalf(t, "UNKNOWN - must specify at least one of mount/options/fstype", string(res.BuildPluginOutput()), "output matches")
+	assert.Equalf(t, "UNKNOWN - must specify at least one of mount/options/fstype/fstab", string(res.BuildPluginOutput()), "output matches")
 
 	StopTestAgent(t, snc)
 }
@@ -2,9 +2,23 @@ package snclient
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"slices"
+	"time"
 )
 
+var errMountProbeTimeout = errors.New("statfs did not return")
+
+// probeMount is not implemented on windows, network shares are not probed
+func probeMount(_ string, _ time.Duration) error {
+	return nil
+}
+
+func (l *CheckMount) checkFstab(_ context.Context, _ *CheckData) error {
+	return fmt.Errorf("fstab is not supported on windows")
+}
+
 // getVolumes retrieves volumes and their details, excluding specified partitions, and returns a list of drives and any potential errors.
 //
 // ctx - The context of the operation.
@@ -56,6 +70,7 @@ func (l *CheckMount) getVolumes(ctx context.Context, check *CheckData, partition
 			"fstype":  partition["fstype"],
 			"options": partition["opts"],
 			"issues":  "",
+			"probe":   "",
 		}
 		drives = append(drives, entry)
 	}
@@ -0,0 +1,143 @@
+package snclient
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/consol-monitoring/snclient/pkg/utils"
+)
+
+func init() {
+	AvailableChecks["check_netstat"] = CheckEntry{"check_netstat", NewCheckNetstat}
+}
+
+const (
+	NetstatRateDuration = 30 * time.Second
+)
+
+// netstatDefaultCounters are added as performance data if no counter argument is set
+var netstatDefaultCounters = []string{
+	"Tcp.ActiveOpens",
+	"Tcp.PassiveOpens",
+	"Tcp.AttemptFails",
+	"Tcp.EstabResets",
+	"Tcp.RetransSegs",
+	"Tcp.InErrs",
+	"Tcp.OutRsts",
+	"TcpExt.ListenOverflows",
+	"TcpExt.ListenDrops",
+	"Udp.InErrors",
+	"Udp.RcvbufErrors",
+	"Udp.SndbufErrors",
+	"Icmp.InErrors",
+	"Icmp.OutErrors",
+}
+
+type CheckNetstat struct {
+	snc      *Agent
+	counters []string
+}
+
+func NewCheckNetstat() CheckHandler {
+	return &CheckNetstat{}
+}
+
+func (l *CheckNetstat) Build() *CheckData {
+	return &CheckData{
+		name:         "check_netstat",
+		description:  "Checks network protocol statistics like tcp retransmits, listen queue overflows and udp buffer errors.",
+		implemented:  Linux,
+		hasInventory: NoInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"counter": {value: &l.counters, description: "Add performance data for this counter, ex.: TcpExt.TCPTimeouts. Can be used multiple times (default: tcp, udp and icmp errors)"},
+		},
+		defaultWarning:  "TcpExt.ListenOverflows_rate > 1 || Udp.RcvbufErrors_rate > 1",
+		defaultCritical: "TcpExt.ListenOverflows_rate > 10 || Udp.RcvbufErrors_rate > 10",
+		detailSyntax: "retransmits: ${Tcp.RetransSegs_rate}/s, listen drops: ${TcpExt.ListenDrops_rate}/s, " +
+			"udp receive buffer errors: ${Udp.RcvbufErrors_rate}/s",
+		topSyntax:  "%(status) - ${list}",
+		emptyState: CheckExitUnknown,
+		attributes: []CheckAttribute{
+			{name: "<protocol>.<counter>", description: "Absolute value of a counter from /proc/net/snmp, /proc/net/netstat or /proc/net/snmp6, ex.: TcpExt.ListenDrops or Udp6.InErrors"},
+			{name: "<protocol>.<counter>_rate", description: "Rate per second of a counter (calculated over the last " + NetstatRateDuration.String() + "), ex.: TcpExt.ListenDrops_rate"},
+		},
+		extraFilterAttributes: []*regexp.Regexp{
+			regexp.MustCompile(`^\w+\.\w+$`),
+		},
+		exampleDefault: `
+    check_netstat
+    OK - retransmits: 0.4/s, listen drops: 0/s, udp receive buffer errors: 0/s |'Tcp.ActiveOpens_rate'=2.13/s ...
+
+Alert on tcp retransmits and timeouts:
+
+    check_netstat counter=TcpExt.TCPTimeouts warn="Tcp.RetransSegs_rate > 10 || TcpExt.TCPTimeouts_rate > 1" crit="Tcp.RetransSegs_rate > 100"
+
+Rates are calculated from values collected by the system task, all rates are 0 until the counters have been collected twice.
+	`,
+		exampleArgs: `warn="Tcp.RetransSegs_rate > 10" crit="TcpExt.ListenDrops_rate > 0"`,
+	}
+}
+
+func (l *CheckNetstat) Check(_ context.Context, snc *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	l.snc = snc
+
+	values := readNetstatCounters(netstatFiles)
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no protocol statistics found in %v", netstatFiles)
+	}
+
+	entry := map[string]string{}
+	for key, val := range values {
+		entry[key] = strconv.FormatFloat(val, 'f', -1, 64)
+		entry[key+"_rate"] = strconv.FormatFloat(utils.ToPrecision(l.getRate(key), 2), 'f', -1, 64)
+	}
+	check.listData = append(check.listData, entry)
+
+	counters := l.counters
+	if len(counters) == 0 {
+		counters = netstatDefaultCounters
+	}
+	for _, key := range counters {
+		if _, ok := values[key]; !ok {
+			log.Debugf("unknown netstat counter: %s", key)
+
+			continue
+		}
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          key + "_rate",
+			ThresholdName: key + "_rate",
+			Unit:          "/s",
+			Value:         utils.ToPrecision(l.getRate(key), 2),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		})
+	}
+
+	return check.Finalize()
+}
+
+func (l *CheckNetstat) getRate(key string) float64 {
+	counter := l.snc.Counter.Get("netstat", key)
+	if counter == nil {
+		return 0
+	}
+
+	rate, err := counter.GetRate(NetstatRateDuration)
+	if err != nil {
+		log.Debugf("Error when getting the netstat counter with name: %s, error: %s", key, err.Error())
+	}
+
+	// counters might be reset, ex.: by network namespace changes
+	if rate < 0 {
+		rate = 0
+	}
+
+	return rate
+}
@@ -0,0 +1,62 @@
+package snclient
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNetstat(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	origFiles := netstatFiles
+	netstatFiles = []string{filepath.Join(tmpDir, "snmp"), filepath.Join(tmpDir, "netstat"), filepath.Join(tmpDir, "snmp6")}
+	defer func() { netstatFiles = origFiles }()
+
+	writeTestFile(t, netstatFiles[0], `Ip: Forwarding DefaultTTL InReceives InHdrErrors
+Ip: 1 64 123456 0
+Icmp: InMsgs InErrors OutMsgs OutErrors
+Icmp: 120 3 118 0
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts
+Tcp: 1 200 120000 -1 1500 300 12 7 25 98765 87654 321 0 45
+Udp: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors SndbufErrors
+Udp: 5000 10 2 4800 2 0
+`)
+	writeTestFile(t, netstatFiles[1], `TcpExt: SyncookiesSent ListenOverflows ListenDrops TCPTimeouts
+TcpExt: 0 17 17 4
+IpExt: InNoRoutes InOctets
+IpExt: 0 98765432
+`)
+	writeTestFile(t, netstatFiles[2], "Ip6InReceives                   \t4321\nIcmp6OutType136                 \t5\nUdp6InErrors                    \t1\n")
+
+	counters := readNetstatCounters(netstatFiles)
+	assert.InDeltaf(t, float64(17), counters["TcpExt.ListenDrops"], 0, "TcpExt.ListenDrops")
+	assert.InDeltaf(t, float64(-1), counters["Tcp.MaxConn"], 0, "Tcp.MaxConn")
+	assert.InDeltaf(t, float64(5), counters["Icmp6.OutType136"], 0, "Icmp6.OutType136")
+	assert.InDeltaf(t, float64(1), counters["Udp6.InErrors"], 0, "Udp6.InErrors")
+
+	res := snc.RunCheck("check_netstat", []string{"counter=TcpExt.ListenDrops", "counter=Udp6.InErrors"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - retransmits: 0/s, listen drops: 0/s, udp receive buffer errors: 0/s |"+
+		"'TcpExt.ListenDrops_rate'=0/s;;;0 'Udp6.InErrors_rate'=0/s;;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_netstat", []string{"counter=Tcp.RetransSegs", "warn=Tcp.RetransSegs > 100", "crit=Udp6.InErrors > 1"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - retransmits: 0/s, listen drops: 0/s, udp receive buffer errors: 0/s |'Tcp.RetransSegs_rate'=0/s;;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	// rates are calculated from the counters of the system task
+	snc.Counter.Create("netstat", "TcpExt.ListenOverflows", time.Minute, time.Second)
+	snc.Counter.Set("netstat", "TcpExt.ListenOverflows", float64(0))
+	time.Sleep(100 * time.Millisecond)
+	snc.Counter.Set("netstat", "TcpExt.ListenOverflows", float64(17))
+	res = snc.RunCheck("check_netstat", []string{"counter=TcpExt.ListenOverflows"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "'TcpExt.ListenOverflows_rate'=", "output matches")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "/s;1;10;0", "output matches")
+}
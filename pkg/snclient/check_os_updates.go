@@ -37,11 +37,11 @@ func (l *CheckOSUpdates) Build() *CheckData {
 		hasInventory: NoCallInventory,
 		result:       &CheckResult{},
 		args: map[string]CheckArgument{
-			"-s|--system": {value: &l.system, description: "Package system: auto, apt, yum, osx and windows (default: auto)"},
+			"-s|--system": {value: &l.system, description: "Package system: auto, apt, yum, dnf5, zypper, apk, pacman, snap, flatpak, osx and windows (default: auto)"},
 			"-u|--update": {value: &l.update, description: "Update package list (if supported, ex.: apt-get update)"},
 			"-m|--max-metadata-age": {
 				value:       &l.maxMetadataAge,
-				description: "Fail with UNKNOWN if the repository metadata (apt/yum/dnf) is older than this duration, ex.: 24h (default: disabled)",
+				description: "Fail with UNKNOWN if the repository metadata (apt/yum/dnf/zypper/apk/pacman) is older than this duration, ex.: 24h (default: disabled)",
 			},
 		},
 		defaultWarning:  "count > 0",
@@ -71,8 +71,13 @@ If you only want to be notified about security related updates:
     check_os_updates warn=none crit='count_security > 0'
     CRITICAL - 1 security updates / 3 updates available. |'security'=1;;0;0 'updates'=3;0;;0
 
-On DNF/APT systems, **--update** refreshes repository metadata in a private cache
-owned by the SNClient service user unless started as root user.
+On DNF/APT/Zypper/APK systems, **--update** refreshes repository metadata in a private cache
+owned by the SNClient service user unless started as root user. On Arch Linux **--update**
+uses checkupdates from pacman-contrib.
+
+Snap and Flatpak application updates are added to the list of updates if snapd
+or flatpak are installed. Zypper lists security patches instead of packages
+as security updates.
 
 The DNF check returns **UNKNOWN** if an enabled repository is unavailable, because
 otherwise an incomplete repository set could be reported as having no updates.
@@ -90,7 +95,7 @@ func (l *CheckOSUpdates) Check(ctx context.Context, snc *Agent, check *CheckData
 	addedOsBackendCount, osBackendAddErr := l.addOSBackends(ctx, check)
 
 	if addedOsBackendCount == 0 {
-		return nil, fmt.Errorf("no suitable package system found, supported systems are apt, yum, osx and windows. found errors: %w", osBackendAddErr)
+		return nil, fmt.Errorf("no suitable package system found, supported systems are apt, yum, dnf5, zypper, apk, pacman, snap, flatpak, osx and windows. found errors: %w", osBackendAddErr)
 	}
 	if osBackendAddErr != nil {
 		return nil, osBackendAddErr
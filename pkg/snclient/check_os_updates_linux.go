@@ -2,6 +2,7 @@ package snclient
 
 import (
 	"context"
+	"encoding/xml"
 	"fmt"
 	"io/fs"
 	"os"
@@ -18,53 +19,78 @@ var (
 	reAPTSecurity = regexp.MustCompile(`(Debian-Security:|Ubuntu:[^/]*/[^-]*-security)`)
 	reAPTEntry    = regexp.MustCompile(`^Inst\s+(\S+)\s+\[([^\[]+)\]\s+\((\S+)\s+(.*)\s+\[(\S+)\]\)`)
 	reYUMEntry    = regexp.MustCompile(`^(\S+)\.(\S+)\s+(\S+)\s+(\S+)`)
+	reAPKEntry    = regexp.MustCompile(`^(\S+)\s+(\S+)\s+\{\S+\}\s+\(.*\)\s+\[upgradable from: (\S+)\]`)
+	rePacmanEntry = regexp.MustCompile(`^(\S+)\s+(\S+)\s+->\s+(\S+)`)
 
 	// system wide locations of the package manager metadata, overridden in tests
-	aptSystemListsDir  = "/var/lib/apt/lists"
-	yumSystemCacheDirs = []string{"/var/cache/dnf", "/var/cache/yum"}
+	aptSystemListsDir     = "/var/lib/apt/lists"
+	yumSystemCacheDirs    = []string{"/var/cache/dnf", "/var/cache/yum"}
+	dnf5SystemCacheDirs   = []string{"/var/cache/libdnf5"}
+	zypperSystemCacheDir  = "/var/cache/zypp"
+	apkSystemCacheDir     = "/var/cache/apk"
+	pacmanSystemSyncDir   = "/var/lib/pacman/sync"
+	snapdSystemSocketPath = "/run/snapd.socket"
 )
 
 func (l *CheckOSUpdates) addOSBackends(ctx context.Context, check *CheckData) (int, error) {
-	addedCount := 0
-	var err error
-	err = nil
-
-	aptAdded, aptErr := l.addAPT(ctx, check)
-	if aptAdded {
-		addedCount++
-	}
-	if aptErr != nil {
-		err = fmt.Errorf("error when adding apt: %w", aptErr)
+	backends := []struct {
+		name string
+		add  func(context.Context, *CheckData) (bool, error)
+	}{
+		{"apt", l.addAPT},
+		{"yum", l.addYUM},
+		{"dnf5", l.addDNF5},
+		{"zypper", l.addZypper},
+		{"apk", l.addAPK},
+		{"pacman", l.addPacman},
+		{"snap", l.addSnap},
+		{"flatpak", l.addFlatpak},
 	}
 
-	yumAdded, yumErr := l.addYUM(ctx, check)
-	if yumAdded {
-		addedCount++
-	}
-	if yumErr != nil {
+	addedCount := 0
+	var err error
+	for _, backend := range backends {
+		added, addErr := backend.add(ctx, check)
+		if added {
+			addedCount++
+		}
+		if addErr == nil {
+			continue
+		}
 		if err == nil {
-			err = fmt.Errorf("error when adding yum: %w", yumErr)
+			err = fmt.Errorf("error when adding %s: %w", backend.name, addErr)
 		} else {
-			err = fmt.Errorf("%w | error when adding yum: %w", err, yumErr)
+			err = fmt.Errorf("%w | error when adding %s: %w", err, backend.name, addErr)
 		}
 	}
 
 	return addedCount, err
 }
 
-// get packages from apt
-func (l *CheckOSUpdates) addAPT(ctx context.Context, check *CheckData) (bool, error) {
+// usePkgSystem returns true if the package system should be checked. Either
+// because it has been selected explicitly or because auto detection found
+// one of the given files.
+func (l *CheckOSUpdates) usePkgSystem(name string, detectFiles ...string) bool {
 	switch l.system {
+	case name:
+		return true
 	case "auto":
 		if runtime.GOOS != "linux" {
-			return false, nil
+			return false
 		}
-		_, err := os.Stat("/usr/bin/apt-get")
-		if os.IsNotExist(err) {
-			return false, nil
+		for _, file := range detectFiles {
+			if _, err := os.Stat(file); err == nil {
+				return true
+			}
 		}
-	case "apt":
-	default:
+	}
+
+	return false
+}
+
+// get packages from apt
+func (l *CheckOSUpdates) addAPT(ctx context.Context, check *CheckData) (bool, error) {
+	if !l.usePkgSystem("apt", "/usr/bin/apt-get") {
 		return false, nil
 	}
 
@@ -171,29 +197,38 @@ func (l *CheckOSUpdates) parseAPT(output string, check *CheckData) {
 
 // get packages from yum
 func (l *CheckOSUpdates) addYUM(ctx context.Context, check *CheckData) (bool, error) {
-	switch l.system {
-	case "auto":
-		if runtime.GOOS != "linux" {
-			return false, nil
-		}
-		_, err := os.Stat("/usr/bin/yum")
-		if os.IsNotExist(err) {
-			return false, nil
-		}
-	case "yum":
-	default:
+	if !l.usePkgSystem("yum", "/usr/bin/yum") {
+		return false, nil
+	}
+
+	// yum is a symlink to dnf5 on recent fedora releases, which is handled by the dnf5 backend
+	if l.system == "auto" && l.usePkgSystem("dnf5", "/usr/bin/dnf5") {
 		return false, nil
 	}
 
+	return true, l.checkYUMUpdates(ctx, check, "yum", "dnf", yumSystemCacheDirs)
+}
+
+// get packages from dnf5
+func (l *CheckOSUpdates) addDNF5(ctx context.Context, check *CheckData) (bool, error) {
+	if !l.usePkgSystem("dnf5", "/usr/bin/dnf5") {
+		return false, nil
+	}
+
+	return true, l.checkYUMUpdates(ctx, check, "dnf5", "dnf5", dnf5SystemCacheDirs)
+}
+
+// checkYUMUpdates runs check-update with the given yum compatible command, which is yum, dnf or dnf5.
+func (l *CheckOSUpdates) checkYUMUpdates(ctx context.Context, check *CheckData, yumCmd, cacheSubRoot string, systemCacheDirs []string) error {
 	// normally answer from cache only
 	yumOpts := " --cacheonly"
 	cacheDir := ""
 
 	if l.update {
 		var err error
-		cacheDir, err = l.pkgListsDir("dnf", nil)
+		cacheDir, err = l.pkgListsDir(cacheSubRoot, nil)
 		if err != nil {
-			return true, err
+			return err
 		}
 
 		if cacheDir != "" {
@@ -202,46 +237,47 @@ func (l *CheckOSUpdates) addYUM(ctx context.Context, check *CheckData) (bool, er
 
 		// Expiring the private cache before the query forces a metadata refresh
 		// and works with both legacy Yum 3 and DNF.
-		output, stderr, exitCode, cacheErr := l.snc.execCommand(ctx, "yum"+yumOpts+" clean expire-cache -q", l.snc.getBuiltinCmdTimeout())
+		output, stderr, exitCode, cacheErr := l.snc.execCommand(ctx, yumCmd+yumOpts+" clean expire-cache -q", l.snc.getBuiltinCmdTimeout())
 		if cacheErr != nil {
-			return true, fmt.Errorf("yum cache expiration failed: %s\n%s", cacheErr.Error(), stderr)
+			return fmt.Errorf("%s cache expiration failed: %s\n%s", yumCmd, cacheErr.Error(), stderr)
 		}
 		if exitCode != 0 {
-			return true, fmt.Errorf("yum cache expiration failed: %s\n%s", output, stderr)
+			return fmt.Errorf("%s cache expiration failed: %s\n%s", yumCmd, output, stderr)
 		}
 	}
 
-	if err := l.checkMetadataAge(l.yumCacheDir(cacheDir)); err != nil {
-		return true, err
+	if err := l.checkMetadataAge(l.yumCacheDir(cacheDir, systemCacheDirs)); err != nil {
+		return err
 	}
 
 	yumOpts += " --setopt='*.skip_if_unavailable=False'"
 
-	output, stderr, exitCode, err := l.snc.execCommand(ctx, "yum"+yumOpts+" check-update --security -q", l.snc.getBuiltinCmdTimeout())
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, yumCmd+yumOpts+" check-update --security -q", l.snc.getBuiltinCmdTimeout())
 	if err != nil {
-		return true, fmt.Errorf("yum check-update failed: %s\n%s", err.Error(), stderr)
+		return fmt.Errorf("%s check-update failed: %s\n%s", yumCmd, err.Error(), stderr)
 	}
 	if exitCode != 0 && exitCode != 100 {
-		return true, fmt.Errorf("yum check-update failed: %s\n%s", output, stderr)
+		return fmt.Errorf("%s check-update failed: %s\n%s", yumCmd, output, stderr)
 	}
 	packageLookup := l.parseYUM(output, "1", check, nil)
 
-	output, stderr, exitCode, err = l.snc.execCommand(ctx, "yum"+yumOpts+" check-update -q", l.snc.getBuiltinCmdTimeout())
+	output, stderr, exitCode, err = l.snc.execCommand(ctx, yumCmd+yumOpts+" check-update -q", l.snc.getBuiltinCmdTimeout())
 	if err != nil {
-		return true, fmt.Errorf("yum check-update failed: %s\n%s", err.Error(), stderr)
+		return fmt.Errorf("%s check-update failed: %s\n%s", yumCmd, err.Error(), stderr)
 	}
 	if exitCode != 0 && exitCode != 100 {
-		return true, fmt.Errorf("yum check-update failed: %s\n%s", output, stderr)
+		return fmt.Errorf("%s check-update failed: %s\n%s", yumCmd, output, stderr)
 	}
 	l.parseYUM(output, "0", check, packageLookup)
 
-	return true, nil
+	return nil
 }
 
 func (l *CheckOSUpdates) parseYUM(output, security string, check *CheckData, skipPackages map[string]bool) map[string]bool {
 	packages := map[string]bool{}
 	for line := range strings.SplitSeq(output, "\n") {
-		if strings.HasPrefix(line, "Obsoleting Packages") {
+		// dnf5 uses lower case "Obsoleting packages"
+		if strings.HasPrefix(strings.ToLower(line), "obsoleting packages") {
 			break
 		}
 		matches := reYUMEntry.FindStringSubmatch(line)
@@ -255,9 +291,9 @@ func (l *CheckOSUpdates) parseYUM(output, security string, check *CheckData, ski
 		check.listData = append(check.listData, map[string]string{
 			"security":    security,
 			"package":     matches[1],
-			"version":     matches[2],
+			"version":     matches[3],
 			"old_version": "",
-			"repository":  matches[3],
+			"repository":  matches[4],
 			"arch":        matches[2],
 		})
 	}
@@ -265,22 +301,406 @@ func (l *CheckOSUpdates) parseYUM(output, security string, check *CheckData, ski
 	return packages
 }
 
+// zypperUpdateList is the xml output of zypper list-updates and list-patches
+type zypperUpdateList struct {
+	Updates []struct {
+		Kind       string `xml:"kind,attr"`
+		Name       string `xml:"name,attr"`
+		Edition    string `xml:"edition,attr"`
+		EditionOld string `xml:"edition-old,attr"`
+		Arch       string `xml:"arch,attr"`
+		Category   string `xml:"category,attr"`
+		Source     struct {
+			Alias string `xml:"alias,attr"`
+		} `xml:"source"`
+	} `xml:"update-status>update-list>update"`
+}
+
+// get packages and security patches from zypper
+func (l *CheckOSUpdates) addZypper(ctx context.Context, check *CheckData) (bool, error) {
+	if !l.usePkgSystem("zypper", "/usr/bin/zypper") {
+		return false, nil
+	}
+
+	// normally answer from cache only
+	zypperOpts := " --non-interactive --xmlout"
+	metadataDir := zypperSystemCacheDir
+	if !l.update {
+		zypperOpts += " --no-refresh"
+	} else {
+		cacheDir, err := l.pkgListsDir("zypper", nil)
+		if err != nil {
+			return true, err
+		}
+
+		if cacheDir != "" {
+			zypperOpts += fmt.Sprintf(" --cache-dir %q", cacheDir)
+			metadataDir = cacheDir
+		}
+
+		output, stderr, exitCode, err := l.snc.execCommand(ctx, "zypper"+zypperOpts+" refresh", l.snc.getBuiltinCmdTimeout())
+		if err != nil {
+			return true, fmt.Errorf("zypper refresh failed: %s\n%s", err.Error(), stderr)
+		}
+		if exitCode != 0 {
+			return true, fmt.Errorf("zypper refresh failed: %s\n%s", output, stderr)
+		}
+	}
+
+	if err := l.checkMetadataAge(filepath.Join(metadataDir, "raw")); err != nil {
+		return true, err
+	}
+
+	// zypper only knows about security patches, packages itself do not have a category
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, "zypper"+zypperOpts+" list-patches --category security", l.snc.getBuiltinCmdTimeout())
+	if err != nil {
+		return true, fmt.Errorf("zypper list-patches failed: %s\n%s", err.Error(), stderr)
+	}
+	// exit codes 100-103 indicate available updates / patches
+	if exitCode != 0 && (exitCode < 100 || exitCode > 103) {
+		return true, fmt.Errorf("zypper list-patches failed: %s\n%s", output, stderr)
+	}
+	patches, err := l.parseZypper(output, "1", check, nil)
+	if err != nil {
+		return true, err
+	}
+
+	// packages updated by security patches are already counted
+	skipPackages, err := l.zypperPatchPackages(ctx, zypperOpts, patches)
+	if err != nil {
+		return true, err
+	}
+
+	output, stderr, exitCode, err = l.snc.execCommand(ctx, "zypper"+zypperOpts+" list-updates", l.snc.getBuiltinCmdTimeout())
+	if err != nil {
+		return true, fmt.Errorf("zypper list-updates failed: %s\n%s", err.Error(), stderr)
+	}
+	if exitCode != 0 && (exitCode < 100 || exitCode > 103) {
+		return true, fmt.Errorf("zypper list-updates failed: %s\n%s", output, stderr)
+	}
+	if _, err = l.parseZypper(output, "0", check, skipPackages); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// zypperPatchPackages returns the names of all packages updated by the given patches
+func (l *CheckOSUpdates) zypperPatchPackages(ctx context.Context, zypperOpts string, patches []string) (map[string]bool, error) {
+	packages := map[string]bool{}
+	if len(patches) == 0 {
+		return packages, nil
+	}
+
+	// info has no xml output, the updated packages are listed as conflicts with older versions
+	zypperOpts = strings.Replace(zypperOpts, " --xmlout", "", 1)
+	command := "zypper" + zypperOpts + " info --type patch"
+	for _, patch := range patches {
+		command += fmt.Sprintf(" %q", patch)
+	}
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, command, l.snc.getBuiltinCmdTimeout())
+	if err != nil {
+		return nil, fmt.Errorf("zypper info failed: %s\n%s", err.Error(), stderr)
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("zypper info failed: %s\n%s", output, stderr)
+	}
+
+	inConflicts := false
+	for line := range strings.SplitSeq(output, "\n") {
+		if !strings.HasPrefix(line, " ") {
+			inConflicts = strings.HasPrefix(line, "Conflicts")
+
+			continue
+		}
+		if !inConflicts {
+			continue
+		}
+		// ex.: "    curl.x86_64 < 8.6.0-150600.4.12.1"
+		name, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if idx := strings.LastIndex(name, "."); idx > 0 {
+			name = name[:idx]
+		}
+		packages[name] = true
+	}
+
+	return packages, nil
+}
+
+// parseZypper adds all updates which are not in skipPackages and returns the names of the added updates
+func (l *CheckOSUpdates) parseZypper(output, security string, check *CheckData, skipPackages map[string]bool) ([]string, error) {
+	list := zypperUpdateList{}
+	if err := xml.Unmarshal([]byte(output), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse zypper xml output: %s", err.Error())
+	}
+
+	names := []string{}
+	for _, update := range list.Updates {
+		// list-patches also lists patches which are already installed or not applicable
+		if update.Kind == "patch" && update.Category != "security" {
+			continue
+		}
+		if skipPackages[update.Name] {
+			continue
+		}
+		names = append(names, update.Name)
+		check.listData = append(check.listData, map[string]string{
+			"security":    security,
+			"package":     update.Name,
+			"version":     update.Edition,
+			"old_version": update.EditionOld,
+			"repository":  update.Source.Alias,
+			"arch":        update.Arch,
+		})
+	}
+
+	return names, nil
+}
+
+// get packages from alpine apk
+func (l *CheckOSUpdates) addAPK(ctx context.Context, check *CheckData) (bool, error) {
+	if !l.usePkgSystem("apk", "/sbin/apk", "/usr/bin/apk") {
+		return false, nil
+	}
+
+	apkOpts := ""
+	metadataDir := apkSystemCacheDir
+	if l.update {
+		cacheDir, err := l.pkgListsDir("apk", nil)
+		if err != nil {
+			return true, err
+		}
+
+		if cacheDir != "" {
+			apkOpts = fmt.Sprintf(" --cache-dir %q", cacheDir)
+			metadataDir = cacheDir
+		}
+
+		output, stderr, exitCode, err := l.snc.execCommand(ctx, "apk"+apkOpts+" update -q", l.snc.getBuiltinCmdTimeout())
+		if err != nil {
+			return true, fmt.Errorf("apk update failed: %s\n%s", err.Error(), stderr)
+		}
+		if exitCode != 0 {
+			return true, fmt.Errorf("apk update failed: %s\n%s", output, stderr)
+		}
+	}
+
+	if err := l.checkMetadataAge(metadataDir); err != nil {
+		return true, err
+	}
+
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, "apk"+apkOpts+" list --upgradable", l.snc.getBuiltinCmdTimeout())
+	if err != nil {
+		return true, fmt.Errorf("apk list failed: %s\n%s", err.Error(), stderr)
+	}
+	if exitCode != 0 {
+		return true, fmt.Errorf("apk list failed: %s\n%s", output, stderr)
+	}
+
+	l.parseAPK(output, check)
+
+	return true, nil
+}
+
+func (l *CheckOSUpdates) parseAPK(output string, check *CheckData) {
+	for line := range strings.SplitSeq(output, "\n") {
+		matches := reAPKEntry.FindStringSubmatch(line)
+		if len(matches) < 4 {
+			continue
+		}
+		// package and version are joined like: py3-setuptools-70.3.0-r0
+		name, version := splitAPKPackage(matches[1])
+		_, oldVersion := splitAPKPackage(matches[3])
+		check.listData = append(check.listData, map[string]string{
+			"security":    "0",
+			"package":     name,
+			"version":     version,
+			"old_version": oldVersion,
+			"repository":  "", // apk list does not show the repository, the braces contain the origin package
+			"arch":        matches[2],
+		})
+	}
+}
+
+// splitAPKPackage splits apk package strings like "curl-8.9.1-r2" into name and version.
+func splitAPKPackage(pkg string) (name, version string) {
+	parts := strings.Split(pkg, "-")
+	if len(parts) < 3 {
+		return pkg, ""
+	}
+
+	return strings.Join(parts[:len(parts)-2], "-"), strings.Join(parts[len(parts)-2:], "-")
+}
+
+// get packages from arch linux pacman
+func (l *CheckOSUpdates) addPacman(ctx context.Context, check *CheckData) (bool, error) {
+	if !l.usePkgSystem("pacman", "/usr/bin/pacman") {
+		return false, nil
+	}
+
+	// checkupdates (from pacman-contrib) syncs into a temporary database and
+	// does not require root, pacman -Sy would result in partial upgrades
+	if l.update {
+		output, stderr, exitCode, err := l.snc.execCommand(ctx, "checkupdates", l.snc.getBuiltinCmdTimeout())
+		if err != nil {
+			return true, fmt.Errorf("checkupdates failed: %s\n%s", err.Error(), stderr)
+		}
+		// exit code 2 means no updates available
+		if exitCode != 0 && exitCode != 2 {
+			return true, fmt.Errorf("checkupdates failed: %s\n%s", output, stderr)
+		}
+		l.parsePacman(output, check)
+
+		return true, nil
+	}
+
+	if err := l.checkMetadataAge(pacmanSystemSyncDir); err != nil {
+		return true, err
+	}
+
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, "pacman -Qu", l.snc.getBuiltinCmdTimeout())
+	if err != nil {
+		return true, fmt.Errorf("pacman -Qu failed: %s\n%s", err.Error(), stderr)
+	}
+	// exit code 1 without error output means no updates available
+	if exitCode != 0 && (exitCode != 1 || strings.TrimSpace(stderr) != "") {
+		return true, fmt.Errorf("pacman -Qu failed: %s\n%s", output, stderr)
+	}
+
+	l.parsePacman(output, check)
+
+	return true, nil
+}
+
+func (l *CheckOSUpdates) parsePacman(output string, check *CheckData) {
+	for line := range strings.SplitSeq(output, "\n") {
+		matches := rePacmanEntry.FindStringSubmatch(line)
+		if len(matches) < 4 {
+			continue
+		}
+		// skip packages listed in IgnorePkg
+		if strings.HasSuffix(strings.TrimSpace(line), "[ignored]") {
+			continue
+		}
+		check.listData = append(check.listData, map[string]string{
+			"security":    "0",
+			"package":     matches[1],
+			"version":     matches[3],
+			"old_version": matches[2],
+			"repository":  "",
+			"arch":        "",
+		})
+	}
+}
+
+// get application updates from snap
+func (l *CheckOSUpdates) addSnap(ctx context.Context, check *CheckData) (bool, error) {
+	if !l.usePkgSystem("snap", "/usr/bin/snap") {
+		return false, nil
+	}
+
+	// the snap command is useless without a running snapd
+	if l.system == "auto" {
+		if _, err := os.Stat(snapdSystemSocketPath); err != nil {
+			return false, nil
+		}
+	}
+
+	// snap always asks the store, so there is no need to update anything
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, "snap refresh --list", l.snc.getBuiltinCmdTimeout())
+	if err != nil {
+		return true, fmt.Errorf("snap refresh failed: %s\n%s", err.Error(), stderr)
+	}
+	if exitCode != 0 {
+		return true, fmt.Errorf("snap refresh failed: %s\n%s", output, stderr)
+	}
+
+	l.parseSnap(output, check)
+
+	return true, nil
+}
+
+func (l *CheckOSUpdates) parseSnap(output string, check *CheckData) {
+	for line := range strings.SplitSeq(output, "\n") {
+		fields := strings.Fields(line)
+		// skip header and "All snaps up to date."
+		if len(fields) < 3 || fields[0] == "Name" || fields[0] == "All" {
+			continue
+		}
+		check.listData = append(check.listData, map[string]string{
+			"security":    "0",
+			"package":     fields[0],
+			"version":     fields[1],
+			"old_version": "",
+			"repository":  "snap",
+			"arch":        "",
+		})
+	}
+}
+
+// get application and runtime updates from flatpak
+func (l *CheckOSUpdates) addFlatpak(ctx context.Context, check *CheckData) (bool, error) {
+	if !l.usePkgSystem("flatpak", "/usr/bin/flatpak") {
+		return false, nil
+	}
+
+	// normally answer from cached remote metadata only
+	flatpakOpts := " --cached"
+	if l.update {
+		flatpakOpts = ""
+	}
+
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, "flatpak remote-ls --updates"+flatpakOpts+" --columns=application,version,branch,arch,origin", l.snc.getBuiltinCmdTimeout())
+	if err != nil {
+		return true, fmt.Errorf("flatpak remote-ls failed: %s\n%s", err.Error(), stderr)
+	}
+	if exitCode != 0 {
+		return true, fmt.Errorf("flatpak remote-ls failed: %s\n%s", output, stderr)
+	}
+
+	l.parseFlatpak(output, check)
+
+	return true, nil
+}
+
+func (l *CheckOSUpdates) parseFlatpak(output string, check *CheckData) {
+	for line := range strings.SplitSeq(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 || fields[0] == "Application ID" {
+			continue
+		}
+		// version is optional, use the branch instead
+		version := strings.TrimSpace(fields[1])
+		if version == "" {
+			version = strings.TrimSpace(fields[2])
+		}
+		check.listData = append(check.listData, map[string]string{
+			"security":    "0",
+			"package":     strings.TrimSpace(fields[0]),
+			"version":     version,
+			"old_version": "",
+			"repository":  strings.TrimSpace(fields[4]),
+			"arch":        strings.TrimSpace(fields[3]),
+		})
+	}
+}
+
 // yumCacheDir returns the cache directory to inspect for the metadata age
 // check. It prefers the private cache directory (used with --update as
 // non-root) and otherwise falls back to the first existing system-wide
 // yum/dnf cache directory.
-func (l *CheckOSUpdates) yumCacheDir(privateCacheDir string) string {
+func (l *CheckOSUpdates) yumCacheDir(privateCacheDir string, systemCacheDirs []string) string {
 	if privateCacheDir != "" {
 		return privateCacheDir
 	}
 
-	for _, dir := range yumSystemCacheDirs {
+	for _, dir := range systemCacheDirs {
 		if _, err := os.Stat(dir); err == nil {
 			return dir
 		}
 	}
 
-	return yumSystemCacheDirs[0]
+	return systemCacheDirs[0]
 }
 
 // checkMetadataAge fails with an error if the --max-metadata-age threshold is
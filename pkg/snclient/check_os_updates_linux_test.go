@@ -127,6 +127,12 @@ grub2-tools.x86_64         1:2.06-70.el9_3.2.rocky.0.2    baseos
 	args := strings.Split(strings.TrimSpace(string(argsRaw)), "\n")
 	require.Len(t, args, 2)
 
+	res = snc.RunCheck("check_os_updates", []string{"--system=yum", "-m", "-1", "top-syntax=${list}", "detail-syntax=${package} ${arch} ${version} ${repository}"})
+	assert.Equalf(t, "bind-export-libs x86_64 32:9.11.4-26.P2.el7_9.15 updates |'security'=3;;0;0 'updates'=0;0;;0\n"+
+		"ca-certificates noarch 2023.2.60_v7.0.306-72.el7_9 updates\n"+
+		"cronie x86_64 1.4.11-25.el7_9 updates",
+		string(res.BuildPluginOutput()), "version and repository are parsed")
+
 	StopTestAgent(t, snc)
 }
 
@@ -189,6 +195,153 @@ func TestCheckYUMMetadataAge(t *testing.T) {
 	assert.NotEqual(t, CheckExitUnknown, res.State)
 }
 
+func TestCheckDNF5Updates(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	// mock dnf5 command from output of: dnf5 check-update [--security] -q
+	MockSystemUtilityArgs(t, "dnf5", 100, [][2]string{
+		{"--security", `
+openssl-libs.x86_64                 1:3.2.2-9.fc41                  updates`},
+		{"check-update", `
+openssl-libs.x86_64                 1:3.2.2-9.fc41                  updates
+vim-minimal.x86_64                  2:9.1.825-1.fc41                updates
+Obsoleting packages
+grub2-tools-efi.x86_64              1:2.12-10.fc41                  updates
+    grub2-tools-efi.x86_64          1:2.12-5.fc41                   @System`},
+	})
+
+	res := snc.RunCheck("check_os_updates", []string{"--system=dnf5", "-m", "-1"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "CRITICAL - 1 security updates / 1 updates available. |'security'=1;;0;0 'updates'=1;0;;0", "output matches")
+}
+
+func TestCheckZypperUpdates(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	// mock zypper command from output of: zypper --xmlout list-patches --category security / list-updates
+	MockSystemUtilityArgs(t, "zypper", 0, [][2]string{
+		{"list-patches", `<?xml version='1.0'?>
+<stream>
+<message type="info">Loading repository data...</message>
+<update-status version="0.6">
+<update-list>
+<update kind="patch" name="openSUSE-SLE-15.6-2024-3401" edition="1" arch="noarch" status="needed" category="security" severity="important" pkgmanager="false" restart="false" interactive="false"><summary>Security update for curl</summary><source url="http://download.opensuse.org/update/leap/15.6/sle" alias="repo-sle-update"/></update>
+</update-list>
+</update-status>
+</stream>`},
+		{"list-updates", `<?xml version='1.0'?>
+<stream>
+<update-status version="0.6">
+<update-list>
+<update kind="package" name="curl" edition="8.6.0-150600.4.12.1" arch="x86_64" edition-old="8.6.0-150600.4.9.1"><summary>A Tool for Transferring Data from URLs</summary><source url="http://download.opensuse.org/update/leap/15.6/sle" alias="repo-sle-update"/></update>
+<update kind="package" name="tar" edition="1.34-150000.3.34.1" arch="x86_64" edition-old="1.34-150000.3.31.1"><summary>GNU implementation of tar</summary><source url="http://download.opensuse.org/update/leap/15.6/sle" alias="repo-sle-update"/></update>
+</update-list>
+</update-status>
+</stream>`},
+		{"info", `Loading repository data...
+Reading installed packages...
+
+
+Information for patch openSUSE-SLE-15.6-2024-3401:
+--------------------------------------------------
+Repository  : repo-sle-update
+Name        : openSUSE-SLE-15.6-2024-3401
+Version     : 1
+Arch        : noarch
+Vendor      : maint-coord@suse.de
+Status      : needed
+Category    : security
+Severity    : important
+Created On  : Thu Sep 26 10:27:49 2024
+Interactive : ---
+Summary     : Security update for curl
+Description :
+    This update for curl fixes the following issues:
+Provides    : patch:openSUSE-SLE-15.6-2024-3401 = 1
+Conflicts   : [3]
+    curl.x86_64 < 8.6.0-150600.4.12.1
+    libcurl4.x86_64 < 8.6.0-150600.4.12.1
+    srcpackage:curl < 8.6.0-150600.4.12.1`},
+	})
+
+	res := snc.RunCheck("check_os_updates", []string{"--system=zypper", "-m", "-1"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "CRITICAL - 1 security updates / 1 updates available. |'security'=1;;0;0 'updates'=1;0;;0", "package from security patch is not counted twice")
+}
+
+func TestCheckAPKUpdates(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	// mock apk command from output of: apk list --upgradable
+	MockSystemUtilityArgs(t, "apk", 0, [][2]string{
+		{"list", `
+busybox-1.36.1-r31 x86_64 {busybox} (GPL-2.0-only) [upgradable from: busybox-1.36.1-r29]
+py3-setuptools-70.3.0-r0 noarch {py3-setuptools} (MIT) [upgradable from: py3-setuptools-69.5.1-r0]`},
+	})
+
+	res := snc.RunCheck("check_os_updates", []string{"--system=apk", "-m", "-1"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "WARNING - 0 security updates / 2 updates available. |'security'=0;;0;0 'updates'=2;0;;0", "output matches")
+
+	res = snc.RunCheck("check_os_updates", []string{"--system=apk", "-m", "-1", "top-syntax=${list}", "detail-syntax=${package} ${arch} ${old_version} ${version} '${repository}'"})
+	assert.Equalf(t, "busybox x86_64 1.36.1-r29 1.36.1-r31 '' |'security'=0;;0;0 'updates'=2;0;;0\n"+
+		"py3-setuptools noarch 69.5.1-r0 70.3.0-r0 ''",
+		string(res.BuildPluginOutput()), "origin package is not reported as repository")
+}
+
+func TestCheckPacmanUpdates(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	// mock pacman command from output of: pacman -Qu
+	MockSystemUtilityArgs(t, "pacman", 0, [][2]string{
+		{"-Qu", `
+linux 6.11.5.arch1-1 -> 6.11.6.arch1-1
+openssl 3.4.0-1 -> 3.4.0-2
+vim 9.1.0785-1 -> 9.1.0866-1 [ignored]`},
+	})
+
+	res := snc.RunCheck("check_os_updates", []string{"--system=pacman", "-m", "-1"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "WARNING - 0 security updates / 2 updates available. |'security'=0;;0;0 'updates'=2;0;;0", "output matches")
+
+	// no updates available results in exit code 1
+	MockSystemUtilityArgs(t, "pacman", 1, [][2]string{})
+
+	res = snc.RunCheck("check_os_updates", []string{"--system=pacman", "-m", "-1"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "OK - 0 security updates / 0 updates available. |'security'=0;;0;0 'updates'=0;0;;0", "output matches")
+}
+
+func TestCheckSnapFlatpakUpdates(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	// mock snap command from output of: snap refresh --list
+	MockSystemUtilityArgs(t, "snap", 0, [][2]string{
+		{"refresh", `
+Name      Version          Rev    Size   Publisher   Notes
+core22    20241001         1663   77MB   canonical✓  base
+firefox   132.0-1          5273   283MB  mozilla✓    -`},
+	})
+
+	res := snc.RunCheck("check_os_updates", []string{"--system=snap"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "WARNING - 0 security updates / 2 updates available. |'security'=0;;0;0 'updates'=2;0;;0", "output matches")
+
+	// mock flatpak command from output of: flatpak remote-ls --updates --columns=...
+	MockSystemUtilityArgs(t, "flatpak", 0, [][2]string{
+		{"remote-ls", "org.mozilla.firefox\t132.0\tstable\tx86_64\tflathub\norg.freedesktop.Platform.GL.default\t\t23.08\tx86_64\tflathub"},
+	})
+
+	res = snc.RunCheck("check_os_updates", []string{"--system=flatpak"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "WARNING - 0 security updates / 2 updates available. |'security'=0;;0;0 'updates'=2;0;;0", "output matches")
+}
+
 func mockYUMUtility(t *testing.T, stdout, stderr string, exitCode int) string {
 	t.Helper()
 
@@ -0,0 +1,319 @@
+package snclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
+)
+
+func init() {
+	AvailableChecks["check_raid"] = CheckEntry{"check_raid", NewCheckRaid}
+}
+
+var (
+	raidMDStatFile   = "/proc/mdstat"
+	raidSysBlockPath = "/sys/block"
+
+	reMDStatArray    = regexp.MustCompile(`^(md\S*)\s*:\s*(\S+)\s*(.*)$`)
+	reMDStatDevice   = regexp.MustCompile(`^(\S+?)\[(\d+)\]((?:\([A-Z]\))*)$`)
+	reMDStatDisks    = regexp.MustCompile(`\[(\d+)/(\d+)\]\s+\[([U_]+)\]`)
+	reMDStatProgress = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*([\d.]+)%`)
+	reMDStatDelayed  = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*(DELAYED|PENDING)`)
+)
+
+type CheckRaid struct {
+	devices []string
+}
+
+func NewCheckRaid() CheckHandler {
+	return &CheckRaid{}
+}
+
+func (l *CheckRaid) Build() *CheckData {
+	return &CheckData{
+		name:         "check_raid",
+		description:  "Checks the state of linux software raid (mdadm) arrays.",
+		implemented:  Linux,
+		hasInventory: ListInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"device": {value: &l.devices, isFilter: true, description: "Show this md device only, ex.: md0"},
+		},
+		defaultWarning:  "sync_action in ('resync', 'recover', 'reshape')",
+		defaultCritical: "degraded > 0 || failed > 0 || state = 'inactive'",
+		detailSyntax:    "${name} (${level}) ${state} [${active}/${total}]",
+		okSyntax:        "%(status) - all %{count} raid arrays are ok",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no raid arrays found",
+		attributes: []CheckAttribute{
+			{name: "name", description: "Name of the md device, ex.: md0"},
+			{name: "level", description: "Raid level, ex.: raid1"},
+			{name: "state", description: "Summarized state: inactive, degraded, the current sync action or the array state"},
+			{name: "array_state", description: "Array state from sysfs, ex.: clean, active, readonly or inactive"},
+			{name: "total", description: "Number of devices the array should consist of"},
+			{name: "active", description: "Number of active devices"},
+			{name: "failed", description: "Number of failed devices"},
+			{name: "spare", description: "Number of spare devices"},
+			{name: "degraded", description: "Flag whether the array is degraded: 0 / 1"},
+			{name: "devices", description: "Comma separated list of member devices"},
+			{name: "sync_action", description: "Current sync action: idle, resync, recover, check, repair, reshape, frozen or delayed"},
+			{name: "sync_progress", description: "Progress of the current sync action in percent", unit: UPercent},
+			{name: "mismatch_cnt", description: "Number of mismatched sectors found during the last check/repair"},
+		},
+		exampleDefault: `
+    check_raid
+    OK - all 2 raid arrays are ok |'md0_active'=2;;;0;2 'md0_failed'=0;;0;0 ...
+
+    check_raid
+    CRITICAL - md1 (raid5) recover [2/3] |'md1_active'=2;;;0;3 'md1_failed'=1;;0;0 'md1_sync_progress'=12.6%;;;0;100 ...
+
+Ignore scheduled checks but alert on mismatches found by them:
+
+    check_raid warn="sync_action in ('resync', 'recover', 'reshape') || mismatch_cnt > 0"
+	`,
+		exampleArgs: `warn="sync_action in ('resync', 'recover', 'reshape')" crit="degraded > 0 || failed > 0"`,
+	}
+}
+
+func (l *CheckRaid) Check(_ context.Context, _ *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	arrays, err := l.parseMDStat(raidMDStatFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range arrays {
+		if len(l.devices) > 0 && !slices.Contains(l.devices, entry["name"]) {
+			continue
+		}
+
+		l.addSysfsInfo(entry)
+
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+
+		check.listData = append(check.listData, entry)
+		l.addMetrics(check, entry)
+	}
+
+	return check.Finalize()
+}
+
+// parseMDStat returns one entry per array found in /proc/mdstat
+func (l *CheckRaid) parseMDStat(file string) ([]map[string]string, error) {
+	mdstat, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %s", file, err.Error())
+	}
+	defer mdstat.Close()
+
+	arrays := []map[string]string{}
+	var entry map[string]string
+	scanner := bufio.NewScanner(mdstat)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := reMDStatArray.FindStringSubmatch(line); matches != nil {
+			entry = l.parseMDStatArrayLine(matches[1], matches[2], matches[3])
+			arrays = append(arrays, entry)
+
+			continue
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		if matches := reMDStatDisks.FindStringSubmatch(line); matches != nil {
+			entry["total"] = matches[1]
+			entry["active"] = matches[2]
+			if strings.Contains(matches[3], "_") {
+				entry["degraded"] = "1"
+			}
+		}
+
+		if matches := reMDStatProgress.FindStringSubmatch(line); matches != nil {
+			entry["sync_action"] = l.normalizeSyncAction(matches[1])
+			entry["sync_progress"] = matches[2]
+		} else if matches := reMDStatDelayed.FindStringSubmatch(line); matches != nil {
+			entry["sync_action"] = "delayed"
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %s", file, err.Error())
+	}
+
+	return arrays, nil
+}
+
+// parseMDStatArrayLine parses the first line of an array, ex.: md1 : active raid5 sdd1[3] sdc1[1] sdb2[0](F)
+func (l *CheckRaid) parseMDStatArrayLine(name, state, rest string) map[string]string {
+	entry := map[string]string{
+		"name":          name,
+		"level":         "",
+		"state":         state,
+		"array_state":   state,
+		"total":         "0",
+		"active":        "0",
+		"failed":        "0",
+		"spare":         "0",
+		"degraded":      "0",
+		"devices":       "",
+		"sync_action":   "idle",
+		"sync_progress": "",
+		"mismatch_cnt":  "",
+	}
+
+	devices := []string{}
+	failed := 0
+	spare := 0
+	for _, token := range strings.Fields(rest) {
+		switch {
+		case strings.HasPrefix(token, "("):
+			// ex.: (auto-read-only)
+			entry["array_state"] = strings.Trim(token, "()")
+		case reMDStatDevice.MatchString(token):
+			matches := reMDStatDevice.FindStringSubmatch(token)
+			devices = append(devices, matches[1])
+			switch {
+			case strings.Contains(matches[3], "(F)"):
+				failed++
+			case strings.Contains(matches[3], "(S)"):
+				spare++
+			}
+		case entry["level"] == "":
+			entry["level"] = token
+		}
+	}
+
+	// raid0 and linear arrays have no [n/m] status, all non-failed devices are active
+	active := len(devices) - failed - spare
+	entry["devices"] = strings.Join(devices, ",")
+	entry["total"] = fmt.Sprintf("%d", active+failed)
+	entry["active"] = fmt.Sprintf("%d", active)
+	entry["failed"] = fmt.Sprintf("%d", failed)
+	entry["spare"] = fmt.Sprintf("%d", spare)
+
+	return entry
+}
+
+// addSysfsInfo overwrites mdstat values with more detailed values from /sys/block/<md>/md/
+func (l *CheckRaid) addSysfsInfo(entry map[string]string) {
+	mdPath := filepath.Join(raidSysBlockPath, entry["name"], "md")
+
+	if val, ok := l.readSysfs(mdPath, "array_state"); ok {
+		entry["array_state"] = val
+	}
+	if val, ok := l.readSysfs(mdPath, "level"); ok && val != "" {
+		entry["level"] = val
+	}
+	if val, ok := l.readSysfs(mdPath, "raid_disks"); ok {
+		entry["total"] = val
+		if degraded, ok := l.readSysfs(mdPath, "degraded"); ok {
+			entry["active"] = fmt.Sprintf("%d", convert.Int64(val)-convert.Int64(degraded))
+			entry["degraded"] = "0"
+			if convert.Int64(degraded) > 0 {
+				entry["degraded"] = "1"
+			}
+		}
+	}
+	if val, ok := l.readSysfs(mdPath, "mismatch_cnt"); ok {
+		entry["mismatch_cnt"] = val
+	}
+	if val, ok := l.readSysfs(mdPath, "sync_action"); ok && entry["sync_action"] != "delayed" {
+		entry["sync_action"] = val
+	}
+	if val, ok := l.readSysfs(mdPath, "sync_completed"); ok {
+		// ex.: 264192 / 2093056
+		done, total, found := strings.Cut(val, "/")
+		if found && convert.Float64(total) > 0 {
+			entry["sync_progress"] = fmt.Sprintf("%.1f", convert.Float64(strings.TrimSpace(done))/convert.Float64(strings.TrimSpace(total))*100)
+		}
+	}
+
+	switch {
+	case entry["state"] == "inactive" || entry["array_state"] == "inactive":
+		entry["state"] = "inactive"
+	case entry["degraded"] == "1" && (entry["sync_action"] == "idle" || entry["sync_action"] == "frozen"):
+		entry["state"] = "degraded"
+	case entry["sync_action"] != "idle":
+		entry["state"] = entry["sync_action"]
+	default:
+		entry["state"] = entry["array_state"]
+	}
+}
+
+func (l *CheckRaid) readSysfs(mdPath, name string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(mdPath, name))
+	if err != nil {
+		return "", false
+	}
+
+	return strings.TrimSpace(string(data)), true
+}
+
+// normalizeSyncAction converts mdstat sync names into sysfs sync_action names
+func (l *CheckRaid) normalizeSyncAction(action string) string {
+	if action == "recovery" {
+		return "recover"
+	}
+
+	return action
+}
+
+func (l *CheckRaid) addMetrics(check *CheckData, entry map[string]string) {
+	total := convert.Float64(entry["total"])
+	check.result.Metrics = append(check.result.Metrics,
+		&CheckMetric{
+			Name:          entry["name"] + "_active",
+			ThresholdName: "active",
+			Value:         convert.Int64(entry["active"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+			Max:           &total,
+		},
+		&CheckMetric{
+			Name:          entry["name"] + "_failed",
+			ThresholdName: "failed",
+			Value:         convert.Int64(entry["failed"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		},
+	)
+
+	if entry["sync_progress"] != "" && entry["sync_action"] != "idle" {
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["name"] + "_sync_progress",
+			ThresholdName: "sync_progress",
+			Unit:          "%",
+			Value:         convert.Float64(entry["sync_progress"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+			Max:           &Hundred,
+		})
+	}
+
+	if entry["mismatch_cnt"] != "" {
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["name"] + "_mismatch_cnt",
+			ThresholdName: "mismatch_cnt",
+			Value:         convert.Int64(entry["mismatch_cnt"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		})
+	}
+}
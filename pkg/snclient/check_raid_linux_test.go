@@ -0,0 +1,74 @@
+package snclient
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRaid(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	origMDStat, origSysBlock := raidMDStatFile, raidSysBlockPath
+	raidMDStatFile = filepath.Join(tmpDir, "mdstat")
+	raidSysBlockPath = filepath.Join(tmpDir, "block")
+	defer func() { raidMDStatFile, raidSysBlockPath = origMDStat, origSysBlock }()
+
+	writeTestFile(t, raidMDStatFile, `Personalities : [raid1] [raid6] [raid5] [raid4]
+md0 : active raid1 sdb1[1] sda1[0]
+      1047552 blocks super 1.2 [2/2] [UU]
+      bitmap: 0/1 pages [0KB], 65536KB chunk
+
+md1 : active raid5 sdd1[3] sdc1[1] sdb2[0](F)
+      2093056 blocks super 1.2 level 5, 512k chunk, algorithm 2 [3/2] [_UU]
+      [==>..................]  recovery = 12.6% (132096/1046528) finish=0.4min speed=33024K/sec
+
+unused devices: <none>
+`)
+	writeTestFile(t, filepath.Join(raidSysBlockPath, "md0", "md", "array_state"), "clean\n")
+	writeTestFile(t, filepath.Join(raidSysBlockPath, "md0", "md", "level"), "raid1\n")
+	writeTestFile(t, filepath.Join(raidSysBlockPath, "md0", "md", "raid_disks"), "2\n")
+	writeTestFile(t, filepath.Join(raidSysBlockPath, "md0", "md", "degraded"), "0\n")
+	writeTestFile(t, filepath.Join(raidSysBlockPath, "md0", "md", "sync_action"), "idle\n")
+	writeTestFile(t, filepath.Join(raidSysBlockPath, "md0", "md", "sync_completed"), "none\n")
+	writeTestFile(t, filepath.Join(raidSysBlockPath, "md0", "md", "mismatch_cnt"), "0\n")
+
+	res := snc.RunCheck("check_raid", []string{"device=md0"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - all 1 raid arrays are ok |'md0_active'=2;;;0;2 'md0_failed'=0;;0;0 'md0_mismatch_cnt'=0;;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	// md1 has no sysfs data, so everything is parsed from mdstat
+	res = snc.RunCheck("check_raid", []string{})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - critical(md1 (raid5) recover [2/3]) |'md0_active'=2;;;0;2 'md0_failed'=0;;0;0 'md0_mismatch_cnt'=0;;;0 "+
+		"'md1_active'=2;;;0;3 'md1_failed'=1;;0;0 'md1_sync_progress'=12.6%;;;0;100",
+		string(res.BuildPluginOutput()), "output matches")
+
+	// resync of a healthy array with spare and an inactive array
+	writeTestFile(t, raidMDStatFile, `Personalities : [raid1]
+md2 : active raid1 sdf1[1] sde1[0] sdg1[2](S)
+      1047552 blocks super 1.2 [2/2] [UU]
+      [=>...................]  resync =  5.0% (52377/1047552) finish=1.2min speed=13094K/sec
+
+md127 : inactive sdh[0](S)
+      1047552 blocks super 1.2
+
+unused devices: <none>
+`)
+	res = snc.RunCheck("check_raid", []string{"device=md2", "show-all", "detail-syntax=${name} ${state} spare:${spare} ${sync_progress}%"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - md2 resync spare:1 5.0% |'md2_active'=2;;;0;2 'md2_failed'=0;;0;0 'md2_sync_progress'=5%;;;0;100",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_raid", []string{"device=md127"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "CRITICAL - md127 () inactive [0/0]", "output matches")
+
+	res = snc.RunCheck("check_raid", []string{"device=md9"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "UNKNOWN - no raid arrays found", "output matches")
+}
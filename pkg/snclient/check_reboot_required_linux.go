@@ -0,0 +1,318 @@
+package snclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/consol-monitoring/snclient/pkg/utils"
+	"github.com/shirou/gopsutil/v4/host"
+)
+
+func init() {
+	AvailableChecks["check_reboot_required"] = CheckEntry{"check_reboot_required", NewCheckRebootRequired}
+}
+
+var (
+	rebootRequiredFile     = "/var/run/reboot-required"
+	rebootRequiredPkgsFile = "/var/run/reboot-required.pkgs"
+	rebootKernelModulePath = "/lib/modules"
+	rebootProcPath         = "/proc"
+
+	reSharedLibrary = regexp.MustCompile(`\.so(\.[\d.]+)?$`)
+
+	rebootRunningKernel = host.KernelVersionWithContext
+)
+
+type CheckRebootRequired struct {
+	snc   *Agent
+	types []string
+}
+
+func NewCheckRebootRequired() CheckHandler {
+	return &CheckRebootRequired{}
+}
+
+func (l *CheckRebootRequired) Build() *CheckData {
+	return &CheckData{
+		name:        "check_reboot_required",
+		description: "Checks if a reboot is pending after updates, ex.: because of a new kernel or updated libraries.",
+		implemented: Linux,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"type": {value: &l.types, description: "Select reasons to check, can be: reboot_required, needs_restarting, kernel or libraries (default: all)"},
+		},
+		defaultWarning: "reason != ''",
+		detailSyntax:   "%(detail)",
+		okSyntax:       "%(status) - %{count} reboot reason(s) found",
+		topSyntax:      "%(status) - reboot required: %(list)",
+		emptyState:     CheckExitOK,
+		emptySyntax:    "%(status) - no reboot required",
+		attributes: []CheckAttribute{
+			{name: "reason", description: "Reason for the reboot: reboot_required, needs_restarting, kernel or libraries"},
+			{name: "detail", description: "Human readable description of the reason"},
+			{name: "packages", description: "Packages requesting the reboot (reboot_required and needs_restarting)"},
+			{name: "running_kernel", description: "Version of the running kernel (kernel)"},
+			{name: "installed_kernel", description: "Version of the newest installed kernel with the same flavour as the running kernel (kernel)"},
+			{name: "pid", description: "Process id (libraries)"},
+			{name: "process", description: "Process name (libraries)"},
+			{name: "libraries", description: "Deleted libraries still in use by this process (libraries)"},
+		},
+		exampleDefault: `
+    check_reboot_required
+    OK - 0 reboot reason(s) found |'count'=0;;;0
+
+    check_reboot_required
+    WARNING - reboot required: running kernel 6.1.0-26-amd64 is older than installed kernel 6.1.0-27-amd64 |'count'=1;;;0
+
+Processes still using deleted libraries usually only need a service restart, so only
+alert on kernel updates and explicit reboot requests:
+
+    check_reboot_required warn=none crit="reason != 'libraries'"
+	`,
+		exampleArgs: `warn="reason != ''" crit="reason = 'kernel'"`,
+	}
+}
+
+func (l *CheckRebootRequired) Check(ctx context.Context, snc *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	l.snc = snc
+
+	if l.hasType("reboot_required") {
+		l.addRebootRequiredFile(check)
+	}
+
+	if l.hasType("needs_restarting") {
+		err := l.addNeedsRestarting(ctx, check)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if l.hasType("kernel") {
+		err := l.addKernel(ctx, check)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if l.hasType("libraries") {
+		l.addDeletedLibraries(check)
+	}
+
+	check.listData = check.Filter(check.filter, check.listData)
+	check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+		ThresholdName: "count",
+		Name:          "count",
+		Value:         len(check.listData),
+		Warning:       check.warnThreshold,
+		Critical:      check.critThreshold,
+		Min:           &Zero,
+	})
+
+	return check.Finalize()
+}
+
+func (l *CheckRebootRequired) hasType(name string) bool {
+	return len(l.types) == 0 || slices.Contains(l.types, name)
+}
+
+// addRebootRequiredFile checks the debian/ubuntu reboot request file
+func (l *CheckRebootRequired) addRebootRequiredFile(check *CheckData) {
+	if _, err := os.Stat(rebootRequiredFile); err != nil {
+		return
+	}
+
+	packages := []string{}
+	pkgData, err := os.ReadFile(rebootRequiredPkgsFile)
+	if err == nil {
+		for pkg := range strings.SplitSeq(string(pkgData), "\n") {
+			pkg = strings.TrimSpace(pkg)
+			if pkg != "" && !slices.Contains(packages, pkg) {
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	detail := rebootRequiredFile + " exists"
+	if len(packages) > 0 {
+		detail += fmt.Sprintf(" (packages: %s)", strings.Join(packages, ", "))
+	}
+
+	check.listData = append(check.listData, map[string]string{
+		"reason":   "reboot_required",
+		"detail":   detail,
+		"packages": strings.Join(packages, ","),
+	})
+}
+
+// addNeedsRestarting uses needs-restarting from yum-utils/dnf-utils to check for updated core packages
+func (l *CheckRebootRequired) addNeedsRestarting(ctx context.Context, check *CheckData) error {
+	if _, err := exec.LookPath("needs-restarting"); err != nil {
+		log.Debugf("needs-restarting not found, skipping: %s", err.Error())
+
+		return nil
+	}
+
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, "needs-restarting -r", l.snc.getBuiltinCmdTimeout())
+	switch {
+	case err != nil:
+		return fmt.Errorf("needs-restarting failed: %s\n%s", err.Error(), stderr)
+	case exitCode == 0:
+		return nil
+	case exitCode != 1:
+		return fmt.Errorf("needs-restarting failed: %s\n%s", output, stderr)
+	}
+
+	// needs-restarting lists updated core packages as: "  * kernel"
+	packages := []string{}
+	for line := range strings.SplitSeq(output, "\n") {
+		line = strings.TrimSpace(line)
+		if pkg, ok := strings.CutPrefix(line, "* "); ok {
+			packages = append(packages, strings.TrimSpace(pkg))
+		}
+	}
+
+	detail := "core libraries or services have been updated since boot"
+	if len(packages) > 0 {
+		detail += fmt.Sprintf(" (packages: %s)", strings.Join(packages, ", "))
+	}
+
+	check.listData = append(check.listData, map[string]string{
+		"reason":   "needs_restarting",
+		"detail":   detail,
+		"packages": strings.Join(packages, ","),
+	})
+
+	return nil
+}
+
+// addKernel compares the running kernel with the newest installed kernel
+func (l *CheckRebootRequired) addKernel(ctx context.Context, check *CheckData) error {
+	running, err := rebootRunningKernel(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get kernel version: %s", err.Error())
+	}
+
+	// other flavours like lowlatency or debug kernels would not be booted by default
+	flavour := kernelFlavour(running)
+	installed := slices.DeleteFunc(l.getInstalledKernels(), func(kernel string) bool {
+		return kernelFlavour(kernel) != flavour
+	})
+	if len(installed) == 0 {
+		log.Debugf("no installed kernels with flavour %q found in %s", flavour, rebootKernelModulePath)
+
+		return nil
+	}
+
+	newest := installed[len(installed)-1]
+	if utils.CompareVersion(running, newest) >= 0 {
+		return nil
+	}
+
+	check.listData = append(check.listData, map[string]string{
+		"reason":           "kernel",
+		"detail":           fmt.Sprintf("running kernel %s is older than installed kernel %s", running, newest),
+		"running_kernel":   running,
+		"installed_kernel": newest,
+	})
+
+	return nil
+}
+
+// getInstalledKernels returns all installed kernel versions sorted by version
+func (l *CheckRebootRequired) getInstalledKernels() []string {
+	files, _ := filepath.Glob(filepath.Join(rebootKernelModulePath, "*", "modules.dep"))
+	installed := make([]string, 0, len(files))
+	for _, file := range files {
+		installed = append(installed, filepath.Base(filepath.Dir(file)))
+	}
+	slices.SortFunc(installed, utils.CompareVersion)
+
+	return installed
+}
+
+// kernelFlavour returns the flavour of a kernel release, ex.: generic for 6.8.0-45-generic,
+// cloud-amd64 for 6.1.0-18-cloud-amd64 or debug for 5.14.0-427.el9.x86_64+debug
+func kernelFlavour(release string) string {
+	if _, flavour, found := strings.Cut(release, "+"); found {
+		return flavour
+	}
+
+	// the flavour consists of the trailing parts which do not start with a number
+	parts := strings.Split(release, "-")
+	start := len(parts)
+	for start > 1 && parts[start-1] != "" && (parts[start-1][0] < '0' || parts[start-1][0] > '9') {
+		start--
+	}
+
+	return strings.Join(parts[start:], "-")
+}
+
+// addDeletedLibraries adds all processes which still map deleted shared libraries
+func (l *CheckRebootRequired) addDeletedLibraries(check *CheckData) {
+	files, _ := filepath.Glob(filepath.Join(rebootProcPath, "[0-9]*", "maps"))
+	for _, file := range files {
+		libraries := l.getDeletedLibraries(file)
+		if len(libraries) == 0 {
+			continue
+		}
+
+		procDir := filepath.Dir(file)
+		pid := filepath.Base(procDir)
+		process := pid
+		comm, err := os.ReadFile(filepath.Join(procDir, "comm"))
+		if err == nil {
+			process = strings.TrimSpace(string(comm))
+		}
+
+		check.listData = append(check.listData, map[string]string{
+			"reason":    "libraries",
+			"detail":    fmt.Sprintf("process %s (%s) uses deleted libraries: %s", process, pid, strings.Join(libraries, ", ")),
+			"pid":       pid,
+			"process":   process,
+			"libraries": strings.Join(libraries, ","),
+		})
+	}
+}
+
+// getDeletedLibraries parses a /proc/<pid>/maps file and returns mapped shared libraries which have been deleted
+func (l *CheckRebootRequired) getDeletedLibraries(mapsFile string) []string {
+	file, err := os.Open(mapsFile)
+	if err != nil {
+		// processes may vanish or belong to other users
+		log.Tracef("cannot read %s: %s", mapsFile, err.Error())
+
+		return nil
+	}
+	defer file.Close()
+
+	libraries := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, ok := strings.CutSuffix(scanner.Text(), " (deleted)")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		path := strings.Join(fields[5:], " ")
+		if !reSharedLibrary.MatchString(path) {
+			continue
+		}
+		if !slices.Contains(libraries, path) {
+			libraries = append(libraries, path)
+		}
+	}
+
+	return libraries
+}
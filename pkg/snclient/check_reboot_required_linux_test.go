@@ -0,0 +1,124 @@
+package snclient
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRebootRequired(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	setRebootRequiredTestPaths(t, tmpDir)
+
+	running := "6.1.0-18-amd64"
+	origKernel := rebootRunningKernel
+	rebootRunningKernel = func(context.Context) (string, error) { return running, nil }
+	defer func() { rebootRunningKernel = origKernel }()
+
+	// nothing pending, newer kernels of other flavours are ignored
+	writeTestFile(t, filepath.Join(rebootKernelModulePath, running, "modules.dep"), "")
+	writeTestFile(t, filepath.Join(rebootKernelModulePath, "999.0.0-1-rt-amd64", "modules.dep"), "")
+	writeTestFile(t, filepath.Join(rebootKernelModulePath, "6.1.0-18-amd64+debug", "modules.dep"), "")
+	res := snc.RunCheck("check_reboot_required", []string{})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - 0 reboot reason(s) found |'count'=0;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	// add newer kernel, reboot-required file and a process with deleted libraries
+	writeTestFile(t, filepath.Join(rebootKernelModulePath, "999.0.0-1-amd64", "modules.dep"), "")
+	writeTestFile(t, rebootRequiredFile, "*** System restart required ***\n")
+	writeTestFile(t, rebootRequiredPkgsFile, "linux-image-999.0.0-1-amd64\nlibc6\nlibc6\n")
+	writeTestFile(t, filepath.Join(rebootProcPath, "1234", "comm"), "sshd\n")
+	writeTestFile(t, filepath.Join(rebootProcPath, "1234", "maps"), `
+55d0a6a0c000-55d0a6a1e000 r--p 00000000 fd:01 1835126                    /usr/sbin/sshd
+7f2b1c400000-7f2b1c428000 r--p 00000000 fd:01 1837431                    /usr/lib/x86_64-linux-gnu/libc.so.6 (deleted)
+7f2b1c428000-7f2b1c5bd000 r-xp 00028000 fd:01 1837431                    /usr/lib/x86_64-linux-gnu/libc.so.6 (deleted)
+7f2b1c600000-7f2b1c6a0000 r--p 00000000 fd:01 1837500                    /usr/lib/x86_64-linux-gnu/libcrypto.so.3 (deleted)
+7f2b1c700000-7f2b1c701000 rw-s 00000000 00:01 2048                       /memfd:pulseaudio (deleted)
+`)
+	writeTestFile(t, filepath.Join(rebootProcPath, "1235", "maps"), `
+7f2b1c400000-7f2b1c428000 r--p 00000000 fd:01 1837431                    /usr/lib/x86_64-linux-gnu/libc.so.6
+`)
+
+	res = snc.RunCheck("check_reboot_required", []string{"type=reboot_required", "type=kernel", "type=libraries"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	output := string(res.BuildPluginOutput())
+	assert.Containsf(t, output, "WARNING - reboot required: ", "output matches")
+	assert.Containsf(t, output, rebootRequiredFile+" exists (packages: linux-image-999.0.0-1-amd64, libc6)", "output matches")
+	assert.Containsf(t, output, "running kernel "+running+" is older than installed kernel 999.0.0-1-amd64", "output matches")
+	assert.Containsf(t, output, "process sshd (1234) uses deleted libraries: /usr/lib/x86_64-linux-gnu/libc.so.6, /usr/lib/x86_64-linux-gnu/libcrypto.so.3", "output matches")
+	assert.NotContainsf(t, output, "memfd", "output matches")
+	assert.Containsf(t, output, "'count'=3;;;0", "output matches")
+
+	// thresholds on specific reasons
+	res = snc.RunCheck("check_reboot_required", []string{"type=reboot_required", "type=kernel", "type=libraries", "warn=none", "crit=reason = 'kernel'"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "CRITICAL - reboot required: ", "output matches")
+}
+
+func TestCheckRebootRequiredNeedsRestarting(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	setRebootRequiredTestPaths(t, t.TempDir())
+
+	// mock needs-restarting from output of: needs-restarting -r
+	MockSystemUtilities(t, map[string]string{
+		"needs-restarting": `Core libraries or services have been updated since boot-up:
+  * glibc
+  * kernel
+
+Reboot is required to fully utilize these updates.
+More information: https://access.redhat.com/solutions/27943`,
+		"needs-restarting_exit": "1",
+	})
+
+	res := snc.RunCheck("check_reboot_required", []string{"type=needs_restarting"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - reboot required: core libraries or services have been updated since boot (packages: glibc, kernel) |'count'=1;;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	MockSystemUtilities(t, map[string]string{
+		"needs-restarting": `No core libraries or services have been updated since boot-up.
+Reboot should not be necessary.`,
+		"needs-restarting_exit": "0",
+	})
+
+	res = snc.RunCheck("check_reboot_required", []string{"type=needs_restarting"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - 0 reboot reason(s) found |'count'=0;;;0", string(res.BuildPluginOutput()), "output matches")
+}
+
+func TestKernelFlavour(t *testing.T) {
+	for release, flavour := range map[string]string{
+		"6.1.0-18-amd64":              "amd64",
+		"6.1.0-18-cloud-amd64":        "cloud-amd64",
+		"6.8.0-45-generic":            "generic",
+		"6.8.0-45-lowlatency":         "lowlatency",
+		"6.4.0-150600.23.7-default":   "default",
+		"5.14.0-427.el9.x86_64":       "",
+		"5.14.0-427.el9.x86_64+debug": "debug",
+		"6.11.5-arch1-1":              "",
+		"6.11.5-zen1-1-zen":           "zen",
+		"6.18.44":                     "",
+	} {
+		assert.Equalf(t, flavour, kernelFlavour(release), "flavour of %s", release)
+	}
+}
+
+func setRebootRequiredTestPaths(t *testing.T, tmpDir string) {
+	t.Helper()
+
+	origFile, origPkgs, origModules, origProc := rebootRequiredFile, rebootRequiredPkgsFile, rebootKernelModulePath, rebootProcPath
+	rebootRequiredFile = filepath.Join(tmpDir, "reboot-required")
+	rebootRequiredPkgsFile = filepath.Join(tmpDir, "reboot-required.pkgs")
+	rebootKernelModulePath = filepath.Join(tmpDir, "modules")
+	rebootProcPath = filepath.Join(tmpDir, "proc")
+	t.Cleanup(func() {
+		rebootRequiredFile, rebootRequiredPkgsFile, rebootKernelModulePath, rebootProcPath = origFile, origPkgs, origModules, origProc
+	})
+}
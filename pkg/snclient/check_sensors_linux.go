@@ -0,0 +1,267 @@
+package snclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
+	"github.com/consol-monitoring/snclient/pkg/utils"
+)
+
+func init() {
+	AvailableChecks["check_sensors"] = CheckEntry{"check_sensors", NewCheckSensors}
+}
+
+var (
+	sensorsHwmonPath = "/sys/class/hwmon"
+
+	reSensorsInput = regexp.MustCompile(`^(in|fan|temp|curr|power|energy|humidity)(\d+)_(input|average)$`)
+)
+
+// sensorTypes contains the sysfs scaling and display unit of each hwmon sensor type, see
+// https://www.kernel.org/doc/html/latest/hwmon/sysfs-interface.html
+var sensorTypes = []struct {
+	name    string
+	divisor float64
+	unit    string
+}{
+	{"temp", 1e3, "°C"},
+	{"fan", 1, "RPM"},
+	{"in", 1e3, "V"},
+	{"curr", 1e3, "A"},
+	{"power", 1e6, "W"},
+	{"energy", 1e6, "J"},
+	{"humidity", 1e3, "%"},
+}
+
+// hwmon files with alarm or fault flags, in addition to <sensor>_alarm
+var sensorsAlarmSuffixes = []string{"alarm", "min_alarm", "max_alarm", "lcrit_alarm", "crit_alarm", "fault"}
+
+type CheckSensors struct {
+	sensors []string
+}
+
+func NewCheckSensors() CheckHandler {
+	return &CheckSensors{}
+}
+
+func (l *CheckSensors) Build() *CheckData {
+	return &CheckData{
+		name:         "check_sensors",
+		description:  "Checks hardware sensors (temperature, fans, voltages, current, power and energy) from hwmon.",
+		implemented:  Linux,
+		hasInventory: ListInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"sensor": {value: &l.sensors, isFilter: true, description: "Show this sensor only, matches sensor, chip name or label"},
+		},
+		// fan headers without fan report 0 rpm
+		defaultFilter:   "type != 'fan' || value > 0 || min > 0 || alarm = 1",
+		defaultWarning:  "alarm = 1 || value > ${max} || value < ${min}",
+		defaultCritical: "value > ${crit} || value < ${lcrit}",
+		detailSyntax:    "${label}: ${value} ${unit}",
+		okSyntax:        "%(status) - all %{count} sensors are ok",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no sensors found",
+		attributes: []CheckAttribute{
+			{name: "sensor", description: "Full name of this sensor, ex.: nct6798_cpu_fan"},
+			{name: "name", description: "Name of the chip, ex.: nct6798"},
+			{name: "label", description: "Label of this sensor, ex.: CPU Fan. Taken from the *_label file or the sensor file name"},
+			{name: "type", description: "Sensor type: temp, fan, in, curr, power, energy or humidity"},
+			{name: "value", description: "Current value"},
+			{name: "unit", description: "Unit of the value: °C, RPM, V, A, W, J or %"},
+			{name: "min", description: "Min value supplied from the sensor (empty if not set)"},
+			{name: "max", description: "Max value supplied from the sensor (empty if not set)"},
+			{name: "lcrit", description: "Lower critical value supplied from the sensor (empty if not set)"},
+			{name: "crit", description: "Critical value supplied from the sensor (empty if not set)"},
+			{name: "alarm", description: "Alarm or fault flag raised by the sensor: 0 / 1"},
+		},
+		listSorted: []string{"sensor"},
+		exampleDefault: `
+    check_sensors
+    OK - all 14 sensors are ok |'nct6798_cpu_fan'=1054;600:;;600 'nct6798_vcore'=1.144;1:1.5;;1;1.5 ...
+
+The min/max/lcrit/crit values of the hardware are used as thresholds by default, a limit of 0 is treated as not set.
+
+Check fans only and alert if a fan runs slower than 500 rpm:
+
+    check_sensors filter="type = 'fan'" warn="value < 500" crit="value < 300"
+    WARNING - CPU Fan: 412 RPM |'nct6798_cpu_fan'=412;500:;300:;600
+	`,
+		exampleArgs: `filter="type = 'fan'" warn="value < 500"`,
+	}
+}
+
+func (l *CheckSensors) Check(_ context.Context, _ *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	hwmons, err := os.ReadDir(sensorsHwmonPath)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %s", sensorsHwmonPath, err.Error())
+	}
+
+	duplicates := map[string]int{}
+	for _, hwmon := range hwmons {
+		for _, entry := range l.readChip(filepath.Join(sensorsHwmonPath, hwmon.Name())) {
+			if num, ok := duplicates[entry["sensor"]]; ok {
+				duplicates[entry["sensor"]] = num + 1
+				entry["sensor"] = fmt.Sprintf("%s.%d", entry["sensor"], num)
+			} else {
+				duplicates[entry["sensor"]] = 1
+			}
+
+			if len(l.sensors) > 0 && !slices.Contains(l.sensors, entry["sensor"]) && !slices.Contains(l.sensors, entry["name"]) && !slices.Contains(l.sensors, entry["label"]) {
+				continue
+			}
+
+			if !check.MatchMapCondition(check.filter, entry, true) {
+				continue
+			}
+
+			check.listData = append(check.listData, entry)
+			l.addMetric(check, entry)
+		}
+	}
+
+	return check.Finalize()
+}
+
+// readChip returns all sensors of a single hwmon device
+func (l *CheckSensors) readChip(hwmonPath string) []map[string]string {
+	// some older drivers put their attributes into the device folder
+	if _, err := os.Stat(filepath.Join(hwmonPath, "name")); err != nil {
+		hwmonPath = filepath.Join(hwmonPath, "device")
+	}
+
+	chip, ok := l.readString(hwmonPath, "name")
+	if !ok {
+		return nil
+	}
+
+	files, err := os.ReadDir(hwmonPath)
+	if err != nil {
+		log.Debugf("read %s: %s", hwmonPath, err.Error())
+
+		return nil
+	}
+
+	// sensor prefix (ex.: fan1) -> input file name, _input is preferred over _average
+	inputs := map[string]string{}
+	for _, file := range files {
+		matches := reSensorsInput.FindStringSubmatch(file.Name())
+		if matches == nil {
+			continue
+		}
+		prefix := matches[1] + matches[2]
+		if _, ok := inputs[prefix]; ok && matches[3] != "input" {
+			continue
+		}
+		inputs[prefix] = file.Name()
+	}
+
+	entries := []map[string]string{}
+	for _, sensorType := range sensorTypes {
+		prefixes := []string{}
+		for prefix := range inputs {
+			if strings.TrimRight(prefix, "0123456789") == sensorType.name {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		sort.Slice(prefixes, func(i, j int) bool {
+			return convert.Int64(strings.TrimPrefix(prefixes[i], sensorType.name)) < convert.Int64(strings.TrimPrefix(prefixes[j], sensorType.name))
+		})
+
+		for _, prefix := range prefixes {
+			if enabled, ok := l.readString(hwmonPath, prefix+"_enable"); ok && enabled == "0" {
+				continue
+			}
+
+			value, ok := l.readValue(hwmonPath, inputs[prefix], sensorType.divisor)
+			if !ok {
+				continue
+			}
+
+			label, ok := l.readString(hwmonPath, prefix+"_label")
+			if !ok || label == "" {
+				label = prefix
+			}
+
+			entry := map[string]string{
+				"sensor": strings.ToLower(strings.ReplaceAll(chip+"_"+label, " ", "_")),
+				"name":   chip,
+				"label":  label,
+				"type":   sensorType.name,
+				"value":  value,
+				"unit":   sensorType.unit,
+				"alarm":  "0",
+			}
+			for _, limit := range []string{"min", "max", "lcrit", "crit"} {
+				entry[limit], _ = l.readValue(hwmonPath, prefix+"_"+limit, sensorType.divisor)
+				// unconfigured limits are usually set to 0
+				if entry[limit] == "0" {
+					entry[limit] = ""
+				}
+			}
+			for _, suffix := range sensorsAlarmSuffixes {
+				if alarm, ok := l.readString(hwmonPath, prefix+"_"+suffix); ok && alarm != "0" {
+					entry["alarm"] = "1"
+				}
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func (l *CheckSensors) readString(hwmonPath, name string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(hwmonPath, name))
+	if err != nil {
+		return "", false
+	}
+
+	return strings.TrimSpace(string(data)), true
+}
+
+// readValue returns the scaled value of a sysfs attribute or an empty string if it does not exist
+func (l *CheckSensors) readValue(hwmonPath, name string, divisor float64) (string, bool) {
+	raw, ok := l.readString(hwmonPath, name)
+	if !ok {
+		return "", false
+	}
+
+	num, err := convert.Float64E(raw)
+	if err != nil {
+		return "", false
+	}
+
+	return strconv.FormatFloat(utils.ToPrecision(num/divisor, 3), 'f', -1, 64), true
+}
+
+func (l *CheckSensors) addMetric(check *CheckData, entry map[string]string) {
+	metric := &CheckMetric{
+		ThresholdName: entry["sensor"],
+		Name:          entry["sensor"],
+		Value:         convert.Float64(entry["value"]),
+		Warning:       check.ExpandMetricMacros(check.TransformMultipleKeywords([]string{"value"}, entry["sensor"], check.warnThreshold), entry),
+		Critical:      check.ExpandMetricMacros(check.TransformMultipleKeywords([]string{"value"}, entry["sensor"], check.critThreshold), entry),
+		Entry:         entry,
+	}
+
+	// min/max from hwmon are alarm limits and only used as default thresholds, not as metric range
+	switch entry["type"] {
+	case "fan", "power", "energy":
+		metric.Min = &Zero
+	}
+
+	check.result.Metrics = append(check.result.Metrics, metric)
+}
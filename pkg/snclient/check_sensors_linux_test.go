@@ -0,0 +1,72 @@
+package snclient
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSensors(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	origPath := sensorsHwmonPath
+	sensorsHwmonPath = tmpDir
+	defer func() { sensorsHwmonPath = origPath }()
+
+	// super io chip with fans, voltages and a temperature
+	chip := filepath.Join(tmpDir, "hwmon2")
+	writeTestFile(t, filepath.Join(chip, "name"), "nct6798\n")
+	writeTestFile(t, filepath.Join(chip, "fan1_input"), "1054\n")
+	writeTestFile(t, filepath.Join(chip, "fan1_min"), "600\n")
+	writeTestFile(t, filepath.Join(chip, "fan1_label"), "CPU Fan\n")
+	writeTestFile(t, filepath.Join(chip, "fan1_alarm"), "0\n")
+	writeTestFile(t, filepath.Join(chip, "fan2_input"), "0\n")
+	writeTestFile(t, filepath.Join(chip, "in0_input"), "1144\n")
+	writeTestFile(t, filepath.Join(chip, "in0_min"), "1000\n")
+	writeTestFile(t, filepath.Join(chip, "in0_max"), "1500\n")
+	writeTestFile(t, filepath.Join(chip, "in0_label"), "Vcore\n")
+	writeTestFile(t, filepath.Join(chip, "in1_input"), "3312\n")
+	writeTestFile(t, filepath.Join(chip, "in1_min"), "0\n")
+	writeTestFile(t, filepath.Join(chip, "in1_max"), "0\n")
+	writeTestFile(t, filepath.Join(chip, "temp1_input"), "42500\n")
+	writeTestFile(t, filepath.Join(chip, "temp1_max"), "80000\n")
+	writeTestFile(t, filepath.Join(chip, "temp1_crit"), "100000\n")
+	writeTestFile(t, filepath.Join(chip, "temp1_label"), "SYSTIN\n")
+
+	// power supply with current, power and energy from an older driver using the device folder
+	psu := filepath.Join(tmpDir, "hwmon3", "device")
+	writeTestFile(t, filepath.Join(psu, "name"), "psu\n")
+	writeTestFile(t, filepath.Join(psu, "curr1_input"), "2250\n")
+	writeTestFile(t, filepath.Join(psu, "curr1_crit"), "10000\n")
+	writeTestFile(t, filepath.Join(psu, "power1_average"), "118500000\n")
+	writeTestFile(t, filepath.Join(psu, "power1_max"), "500000000\n")
+	writeTestFile(t, filepath.Join(psu, "energy1_input"), "987654321000\n")
+
+	res := snc.RunCheck("check_sensors", []string{})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - all 7 sensors are ok |"+
+		"'nct6798_systin'=42.5;80;100 'nct6798_cpu_fan'=1054;600:;;0 'nct6798_vcore'=1.144;1:1.5 "+
+		"'nct6798_in1'=3.312 'psu_curr1'=2.25;;10 'psu_power1'=118.5;500;;0 'psu_energy1'=987654.321;;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	// fan below min and alarm raised
+	writeTestFile(t, filepath.Join(chip, "fan1_input"), "412\n")
+	writeTestFile(t, filepath.Join(chip, "fan1_alarm"), "1\n")
+	res = snc.RunCheck("check_sensors", []string{"sensor=CPU Fan"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - CPU Fan: 412 RPM |'nct6798_cpu_fan'=412;600:;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	writeTestFile(t, filepath.Join(psu, "curr1_input"), "12000\n")
+	res = snc.RunCheck("check_sensors", []string{"filter=type = 'curr'"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - curr1: 12 A |'psu_curr1'=12;;10", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_sensors", []string{"filter=type = 'fan' && value > 0", "warn=value < 500", "crit=value < 300"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - CPU Fan: 412 RPM |'nct6798_cpu_fan'=412;500:;300:;0",
+		string(res.BuildPluginOutput()), "output matches")
+}
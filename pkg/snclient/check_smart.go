@@ -0,0 +1,372 @@
+package snclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
+)
+
+func init() {
+	AvailableChecks["check_smart"] = CheckEntry{"check_smart", NewCheckSmart}
+}
+
+// smartctl is executed as root if possible, so only use it from system folders
+var smartctlPaths = []string{
+	"/usr/sbin/smartctl",
+	"/usr/bin/smartctl",
+	"/sbin/smartctl",
+	"/usr/local/sbin/smartctl",
+	"/opt/homebrew/bin/smartctl",
+}
+
+// smartctl exit status bits, see man smartctl
+const (
+	smartctlExitCmdLineError    = 1 << 0
+	smartctlExitDeviceOpenError = 1 << 1
+)
+
+// ata smart attribute ids
+const (
+	smartAttrReallocatedSectors   = 5
+	smartAttrPendingSectors       = 197
+	smartAttrOfflineUncorrectable = 198
+)
+
+type smartctlMessages struct {
+	Smartctl struct {
+		ExitStatus int64 `json:"exit_status"`
+		Messages   []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+}
+
+type smartctlScan struct {
+	smartctlMessages
+	Devices []struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Protocol string `json:"protocol"`
+	} `json:"devices"`
+}
+
+type smartctlDevice struct {
+	smartctlMessages
+	Device struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Protocol string `json:"protocol"`
+	} `json:"device"`
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	SmartStatus  *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature *struct {
+		Current int64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime *struct {
+		Hours int64 `json:"hours"`
+	} `json:"power_on_time"`
+	ATASmartAttributes *struct {
+		Table []struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+			Raw  struct {
+				Value int64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NVMeSmartHealth *struct {
+		CriticalWarning         int64 `json:"critical_warning"`
+		AvailableSpare          int64 `json:"available_spare"`
+		AvailableSpareThreshold int64 `json:"available_spare_threshold"`
+		PercentageUsed          int64 `json:"percentage_used"`
+		MediaErrors             int64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+type CheckSmart struct {
+	snc     *Agent
+	devices []string
+}
+
+func NewCheckSmart() CheckHandler {
+	return &CheckSmart{}
+}
+
+func (l *CheckSmart) Build() *CheckData {
+	return &CheckData{
+		name:         "check_smart",
+		description:  "Checks the disk health from smartctl (smartmontools).",
+		implemented:  Linux | Darwin | FreeBSD,
+		hasInventory: NoCallInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"device": {value: &l.devices, isFilter: true, description: "Check this device only, ex.: /dev/sda (default: all devices found by smartctl --scan-open)"},
+		},
+		defaultWarning:  "reallocated_sectors > 0 || pending_sectors > 0 || offline_uncorrectable > 0 || media_errors > 0 || percentage_used > 80 || temperature > 60",
+		defaultCritical: "passed = 0 || critical_warning > 0 || percentage_used > 95 || temperature > 70",
+		detailSyntax:    "${name} (${model}) ${health}",
+		okSyntax:        "%(status) - all %{count} disks are ok",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no disks found",
+		attributes: []CheckAttribute{
+			{name: "device", description: "Device path, ex.: /dev/sda"},
+			{name: "name", description: "Device name, ex.: sda"},
+			{name: "type", description: "Device type used by smartctl, ex.: sat or nvme"},
+			{name: "protocol", description: "Device protocol, ex.: ATA, NVMe or SCSI"},
+			{name: "model", description: "Model name"},
+			{name: "serial", description: "Serial number"},
+			{name: "passed", description: "SMART overall-health self-assessment test passed: 0 / 1"},
+			{name: "health", description: "SMART overall-health self-assessment as text: passed or failed"},
+			{name: "temperature", description: "Current temperature in celsius"},
+			{name: "power_on_hours", description: "Power on time in hours"},
+			{name: "reallocated_sectors", description: "Number of reallocated sectors (ATA only)"},
+			{name: "pending_sectors", description: "Number of sectors waiting to be remapped (ATA only)"},
+			{name: "offline_uncorrectable", description: "Number of uncorrectable sectors (ATA only)"},
+			{name: "percentage_used", description: "Estimated percentage of device life used (NVMe only)", unit: UPercent},
+			{name: "available_spare", description: "Remaining spare capacity in percent (NVMe only)", unit: UPercent},
+			{name: "media_errors", description: "Number of unrecovered data integrity errors (NVMe only)"},
+			{name: "critical_warning", description: "Critical warning bit field (NVMe only)"},
+			{name: "exit_status", description: "Exit status of smartctl"},
+		},
+		exampleDefault: `
+    check_smart
+    OK - all 2 disks are ok |'sda_temperature'=31;60;70 'sda_power_on_hours'=21533;;;0 ...
+
+smartctl requires root permissions, it is run as root if snclient has the required capabilities
+or runs as root user.
+
+Check a single disk:
+
+    check_smart device=/dev/nvme0
+    OK - all 1 disks are ok |'nvme0_temperature'=38;60;70 'nvme0_power_on_hours'=3021;;;0 'nvme0_percentage_used'=2%;80;95;0;100 ...
+	`,
+		exampleArgs: `device=/dev/sda`,
+	}
+}
+
+func (l *CheckSmart) Check(ctx context.Context, snc *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	l.snc = snc
+
+	smartctl := l.findSmartctl()
+	if smartctl == "" {
+		return nil, fmt.Errorf("smartctl not found in %s", strings.Join(smartctlPaths, ", "))
+	}
+
+	devices := [][2]string{}
+	for _, device := range l.devices {
+		// devices are passed to smartctl, so make sure they cannot be used as options
+		if !strings.HasPrefix(device, "/") || containsNastyCharacters(device, SystemCmdNastyCharacters) {
+			return nil, fmt.Errorf("invalid device, must be an absolute path: %s", device)
+		}
+		devices = append(devices, [2]string{device, ""})
+	}
+	if len(devices) == 0 {
+		scanned, err := l.scanDevices(ctx, smartctl)
+		if err != nil {
+			return nil, err
+		}
+		devices = scanned
+	}
+
+	for _, device := range devices {
+		entry, err := l.getDevice(ctx, smartctl, device[0], device[1])
+		if err != nil {
+			entry = map[string]string{
+				"device": device[0],
+				"name":   filepath.Base(device[0]),
+				"_error": err.Error(),
+			}
+		}
+
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+
+		check.listData = append(check.listData, entry)
+		l.addMetrics(check, entry)
+	}
+
+	return check.Finalize()
+}
+
+func (l *CheckSmart) findSmartctl() string {
+	for _, path := range smartctlPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// runSmartctl runs smartctl as root if possible and returns the json output
+func (l *CheckSmart) runSmartctl(ctx context.Context, smartctl, args string) (string, int64, error) {
+	command := fmt.Sprintf("%s --json %s", smartctl, args)
+
+	var output, stderr string
+	var exitCode int64
+	var err error
+	if os.Geteuid() == 0 || HasCapabilities() {
+		output, stderr, exitCode, err = l.snc.execCommandAsRoot(ctx, command, l.snc.getBuiltinCmdTimeout())
+	} else {
+		output, stderr, exitCode, err = l.snc.execCommand(ctx, command, l.snc.getBuiltinCmdTimeout())
+	}
+	if err != nil {
+		return "", exitCode, fmt.Errorf("smartctl failed: %s\n%s", err.Error(), stderr)
+	}
+
+	return output, exitCode, nil
+}
+
+// scanDevices returns list of device name and type tuples
+func (l *CheckSmart) scanDevices(ctx context.Context, smartctl string) ([][2]string, error) {
+	output, exitCode, err := l.runSmartctl(ctx, smartctl, "--scan-open")
+	if err != nil {
+		return nil, err
+	}
+
+	scan := smartctlScan{}
+	err = json.Unmarshal([]byte(output), &scan)
+	if err != nil {
+		return nil, fmt.Errorf("smartctl --scan-open failed (exit code %d): %s", exitCode, strings.TrimSpace(output))
+	}
+
+	devices := [][2]string{}
+	for _, dev := range scan.Devices {
+		devices = append(devices, [2]string{dev.Name, dev.Type})
+	}
+
+	return devices, nil
+}
+
+// getDevice runs smartctl -a for given device and returns the parsed entry
+func (l *CheckSmart) getDevice(ctx context.Context, smartctl, device, devType string) (map[string]string, error) {
+	if containsNastyCharacters(device, SystemCmdNastyCharacters) || containsNastyCharacters(devType, SystemCmdNastyCharacters) {
+		return nil, fmt.Errorf("device name contains invalid characters: %s", device)
+	}
+
+	args := "-a"
+	if devType != "" {
+		args += fmt.Sprintf(" -d %q", devType)
+	}
+	args += fmt.Sprintf(" %q", device)
+
+	output, exitCode, err := l.runSmartctl(ctx, smartctl, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.parseDevice(device, output, exitCode)
+}
+
+func (l *CheckSmart) parseDevice(device, output string, exitCode int64) (map[string]string, error) {
+	data := smartctlDevice{}
+	err := json.Unmarshal([]byte(output), &data)
+	if err != nil {
+		return nil, fmt.Errorf("smartctl failed to read %s (exit code %d): %s", device, exitCode, strings.TrimSpace(output))
+	}
+
+	// bits 0 and 1 mean smartctl could not read anything
+	if exitCode&(smartctlExitCmdLineError|smartctlExitDeviceOpenError) != 0 {
+		messages := []string{}
+		for _, msg := range data.Smartctl.Messages {
+			messages = append(messages, msg.String)
+		}
+
+		return nil, fmt.Errorf("smartctl failed to read %s (exit code %d): %s", device, exitCode, strings.Join(messages, ", "))
+	}
+
+	entry := map[string]string{
+		"device":                device,
+		"name":                  filepath.Base(device),
+		"type":                  data.Device.Type,
+		"protocol":              data.Device.Protocol,
+		"model":                 data.ModelName,
+		"serial":                data.SerialNumber,
+		"passed":                "",
+		"health":                "unknown",
+		"temperature":           "",
+		"power_on_hours":        "",
+		"reallocated_sectors":   "",
+		"pending_sectors":       "",
+		"offline_uncorrectable": "",
+		"percentage_used":       "",
+		"available_spare":       "",
+		"media_errors":          "",
+		"critical_warning":      "",
+		"exit_status":           fmt.Sprintf("%d", exitCode),
+	}
+
+	if data.SmartStatus != nil {
+		entry["passed"] = "0"
+		entry["health"] = "failed"
+		if data.SmartStatus.Passed {
+			entry["passed"] = "1"
+			entry["health"] = "passed"
+		}
+	}
+	if data.Temperature != nil {
+		entry["temperature"] = fmt.Sprintf("%d", data.Temperature.Current)
+	}
+	if data.PowerOnTime != nil {
+		entry["power_on_hours"] = fmt.Sprintf("%d", data.PowerOnTime.Hours)
+	}
+	if data.ATASmartAttributes != nil {
+		for _, attr := range data.ATASmartAttributes.Table {
+			switch attr.ID {
+			case smartAttrReallocatedSectors:
+				entry["reallocated_sectors"] = fmt.Sprintf("%d", attr.Raw.Value)
+			case smartAttrPendingSectors:
+				entry["pending_sectors"] = fmt.Sprintf("%d", attr.Raw.Value)
+			case smartAttrOfflineUncorrectable:
+				entry["offline_uncorrectable"] = fmt.Sprintf("%d", attr.Raw.Value)
+			}
+		}
+	}
+	if data.NVMeSmartHealth != nil {
+		entry["percentage_used"] = fmt.Sprintf("%d", data.NVMeSmartHealth.PercentageUsed)
+		entry["available_spare"] = fmt.Sprintf("%d", data.NVMeSmartHealth.AvailableSpare)
+		entry["media_errors"] = fmt.Sprintf("%d", data.NVMeSmartHealth.MediaErrors)
+		entry["critical_warning"] = fmt.Sprintf("%d", data.NVMeSmartHealth.CriticalWarning)
+	}
+
+	return entry, nil
+}
+
+func (l *CheckSmart) addMetrics(check *CheckData, entry map[string]string) {
+	for _, name := range []string{"temperature", "power_on_hours", "reallocated_sectors", "pending_sectors", "offline_uncorrectable", "percentage_used", "media_errors"} {
+		if entry[name] == "" {
+			continue
+		}
+
+		metric := &CheckMetric{
+			Name:          entry["name"] + "_" + name,
+			ThresholdName: name,
+			Value:         convert.Int64(entry[name]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		}
+		switch name {
+		case "temperature":
+			metric.Min = nil
+		case "percentage_used":
+			metric.Unit = "%"
+			metric.Max = &Hundred
+		}
+
+		check.result.Metrics = append(check.result.Metrics, metric)
+	}
+}
@@ -0,0 +1,153 @@
+//go:build !windows
+
+package snclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorded (and shortened) output of: smartctl --json --scan-open
+const smartctlScanJSON = `{
+  "json_format_version": [1, 0],
+  "smartctl": {"version": [7, 4], "argv": ["smartctl", "--json", "--scan-open"], "exit_status": 0},
+  "devices": [
+    {"name": "/dev/sda", "info_name": "/dev/sda [SAT]", "type": "sat", "protocol": "ATA"},
+    {"name": "/dev/nvme0", "info_name": "/dev/nvme0", "type": "nvme", "protocol": "NVMe"}
+  ]
+}`
+
+// recorded (and shortened) output of: smartctl --json -a -d sat /dev/sda
+const smartctlSATAJSON = `{
+  "json_format_version": [1, 0],
+  "smartctl": {"version": [7, 4], "argv": ["smartctl", "--json", "-a", "-d", "sat", "/dev/sda"], "exit_status": 0},
+  "device": {"name": "/dev/sda", "info_name": "/dev/sda [SAT]", "type": "sat", "protocol": "ATA"},
+  "model_name": "Samsung SSD 860 EVO 500GB",
+  "serial_number": "S3Z2NB0K123456A",
+  "smart_status": {"passed": true},
+  "ata_smart_attributes": {
+    "revision": 1,
+    "table": [
+      {"id": 5, "name": "Reallocated_Sector_Ct", "value": 100, "worst": 100, "thresh": 10, "raw": {"value": 0, "string": "0"}},
+      {"id": 9, "name": "Power_On_Hours", "value": 95, "worst": 95, "thresh": 0, "raw": {"value": 21533, "string": "21533"}},
+      {"id": 197, "name": "Current_Pending_Sector", "value": 100, "worst": 100, "thresh": 0, "raw": {"value": 0, "string": "0"}},
+      {"id": 198, "name": "Offline_Uncorrectable", "value": 100, "worst": 100, "thresh": 0, "raw": {"value": 0, "string": "0"}}
+    ]
+  },
+  "power_on_time": {"hours": 21533},
+  "temperature": {"current": 31}
+}`
+
+// recorded (and shortened) output of: smartctl --json -a -d nvme /dev/nvme0
+const smartctlNVMeJSON = `{
+  "json_format_version": [1, 0],
+  "smartctl": {"version": [7, 4], "argv": ["smartctl", "--json", "-a", "-d", "nvme", "/dev/nvme0"], "exit_status": 0},
+  "device": {"name": "/dev/nvme0", "info_name": "/dev/nvme0", "type": "nvme", "protocol": "NVMe"},
+  "model_name": "WDC WDS100T2B0C-00PXH0",
+  "serial_number": "21154A801234",
+  "smart_status": {"passed": true, "nvme": {"value": 0}},
+  "nvme_smart_health_information_log": {
+    "critical_warning": 0,
+    "temperature": 38,
+    "available_spare": 100,
+    "available_spare_threshold": 10,
+    "percentage_used": 84,
+    "data_units_read": 19716384,
+    "media_errors": 0,
+    "num_err_log_entries": 12,
+    "power_on_hours": 3021
+  },
+  "temperature": {"current": 38},
+  "power_on_time": {"hours": 3021}
+}`
+
+// recorded (and shortened) output of a failing disk: smartctl --json -a /dev/sdb
+const smartctlFailingJSON = `{
+  "json_format_version": [1, 0],
+  "smartctl": {"version": [7, 4], "argv": ["smartctl", "--json", "-a", "/dev/sdb"], "exit_status": 24},
+  "device": {"name": "/dev/sdb", "info_name": "/dev/sdb [SAT]", "type": "sat", "protocol": "ATA"},
+  "model_name": "ST2000DM001-1CH164",
+  "serial_number": "Z1E12345",
+  "smart_status": {"passed": false},
+  "ata_smart_attributes": {
+    "revision": 10,
+    "table": [
+      {"id": 5, "name": "Reallocated_Sector_Ct", "value": 5, "worst": 5, "thresh": 10, "raw": {"value": 3920, "string": "3920"}},
+      {"id": 197, "name": "Current_Pending_Sector", "value": 100, "worst": 100, "thresh": 0, "raw": {"value": 16, "string": "16"}},
+      {"id": 198, "name": "Offline_Uncorrectable", "value": 100, "worst": 100, "thresh": 0, "raw": {"value": 16, "string": "16"}}
+    ]
+  },
+  "power_on_time": {"hours": 41022},
+  "temperature": {"current": 36}
+}`
+
+// recorded output of: smartctl --json -a /dev/sdz
+const smartctlMissingJSON = `{
+  "json_format_version": [1, 0],
+  "smartctl": {
+    "version": [7, 4],
+    "argv": ["smartctl", "--json", "-a", "/dev/sdz"],
+    "messages": [{"string": "/dev/sdz: Unable to detect device type", "severity": "error"}],
+    "exit_status": 1
+  }
+}`
+
+func TestCheckSmart(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	testModeFakeHasCapabilities = true
+	defer func() { testModeFakeHasCapabilities = false }()
+
+	smartctl := MockSystemUtilityArgs(t, "smartctl", 0, [][2]string{
+		{"--scan-open", smartctlScanJSON},
+		{"/dev/sda", smartctlSATAJSON},
+		{"/dev/nvme0", smartctlNVMeJSON},
+	})
+	origPaths := smartctlPaths
+	smartctlPaths = []string{smartctl}
+	defer func() { smartctlPaths = origPaths }()
+
+	res := snc.RunCheck("check_smart", []string{})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - warning(nvme0 (WDC WDS100T2B0C-00PXH0) passed) |"+
+		"'sda_temperature'=31;60;70 'sda_power_on_hours'=21533;;;0 'sda_reallocated_sectors'=0;0;;0 "+
+		"'sda_pending_sectors'=0;0;;0 'sda_offline_uncorrectable'=0;0;;0 "+
+		"'nvme0_temperature'=38;60;70 'nvme0_power_on_hours'=3021;;;0 'nvme0_percentage_used'=84%;80;95;0;100 "+
+		"'nvme0_media_errors'=0;0;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_smart", []string{"device=/dev/sda"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "OK - all 1 disks are ok |'sda_temperature'=31;60;70", "output matches")
+}
+
+func TestCheckSmartFailing(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	smartctl := MockSystemUtilityArgs(t, "smartctl", 24, [][2]string{
+		{"/dev/sdb", smartctlFailingJSON},
+	})
+	origPaths := smartctlPaths
+	smartctlPaths = []string{smartctl}
+	defer func() { smartctlPaths = origPaths }()
+
+	res := snc.RunCheck("check_smart", []string{"device=/dev/sdb"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "CRITICAL - sdb (ST2000DM001-1CH164) failed |", "output matches")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "'sdb_reallocated_sectors'=3920;0;;0", "output matches")
+
+	smartctl = MockSystemUtilityArgs(t, "smartctl", 1, [][2]string{
+		{"/dev/sdz", smartctlMissingJSON},
+	})
+	smartctlPaths = []string{smartctl}
+
+	res = snc.RunCheck("check_smart", []string{"device=/dev/sdz"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "/dev/sdz: Unable to detect device type", "output matches")
+	res = snc.RunCheck("check_smart", []string{"device=--scan"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "invalid device, must be an absolute path: --scan", "options are not passed to smartctl")
+}
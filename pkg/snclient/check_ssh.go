@@ -18,16 +18,28 @@ func NewCheckSSH() CheckHandler {
 	return &CheckBuiltin{
 		name: "check_ssh",
 		description: `Runs check_tcp with an SSH configururation to check for a running SSH server.
-It basically wraps the plugin from https://github.com/taku-k/go-check-plugins/tree/master/check-tcp`,
+It basically wraps the plugin from https://github.com/taku-k/go-check-plugins/tree/master/check-tcp
+
+Afterwards it runs the SSH key exchange without authenticating and reports the host key and the offered algorithms.
+The host key can be verified against pinned fingerprints or a known_hosts file.`,
 		check:    checkSSH,
 		docTitle: `check_ssh`,
 		usage:    `check_ssh [<options>]`,
 		exampleDefault: `
 		check_ssh github.com
-SSH OK - 0.234 seconds response time on github.com port 22 [SSH-2.0-8ad108e] | time=0.234029s;;;0.000000;10.000000
+SSH OK - 0.234 seconds response time on github.com port 22 [SSH-2.0-8ad108e] - ssh-ed25519 host key SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU | time=0.234029s;;;0.000000;10.000000
+kex algorithms: curve25519-sha256, curve25519-sha256@libssh.org, ecdh-sha2-nistp256, ...
+host key algorithms: ssh-ed25519, ecdsa-sha2-nistp256, rsa-sha2-512, rsa-sha2-256
+ciphers: chacha20-poly1305@openssh.com, aes256-gcm@openssh.com, aes128-gcm@openssh.com, ...
+macs: hmac-sha2-512-etm@openssh.com, hmac-sha2-256-etm@openssh.com, ...
+
+		check_ssh --hostname github.com --warning 1 --fingerprint SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+SSH OK - 0.262 seconds response time on github.com port 22 [SSH-2.0-8ad108e] - ssh-ed25519 host key SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU | time=0.262048s;;;1.000000;10.000000
+...
 
-		check_ssh --hostname github.com --warning 1
-SSH OK - 0.262 seconds response time on github.com port 22 [SSH-2.0-8ad108e] | time=0.262048s;;;1.000000;10.000000
+		check_ssh --hostname oldhost --known-hosts /etc/ssh/ssh_known_hosts
+SSH WARNING - 0.012 seconds response time on oldhost port 22 [SSH-2.0-OpenSSH_7.4] - ssh-ed25519 host key SHA256:W6sCR1ZD2Wjx6SXjvhr2Kk0Q4B1Xs3dT/ZiNqhqqAWU, weak algorithms offered: diffie-hellman-group-exchange-sha1, ssh-rsa, aes128-cbc | time=0.012442s;;;0.000000;10.000000
+...
 	`,
 		exampleArgs: `'-H' '192.168.178.100' '-p' '2323'`,
 	}
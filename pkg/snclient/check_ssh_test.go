@@ -1,9 +1,21 @@
 package snclient
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 func TestCheckSSH(t *testing.T) {
@@ -33,3 +45,118 @@ CheckBuiltinPlugins = enabled
 
 	StopTestAgent(t, snc)
 }
+
+func TestCheckSSHHostKey(t *testing.T) {
+	config := `
+[/modules]
+CheckBuiltinPlugins = enabled
+`
+	snc := StartTestAgent(t, config)
+	defer StopTestAgent(t, snc)
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostKey, err := ssh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+	fingerprint := ssh.FingerprintSHA256(hostKey.PublicKey())
+
+	port := startTestSSHServer(t, ssh.Config{
+		MACs: []string{"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256"},
+	}, hostKey)
+	res := snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", port, "--fingerprint", fingerprint})
+	assert.Equalf(t, CheckExitOK, res.State, "state ok")
+	assert.Regexpf(t,
+		`^SSH OK - [\d.]+ seconds response time on 127.0.0.1 port \d+ \[SSH-2.0-Go\] - ssh-ed25519 host key `+regexp.QuoteMeta(fingerprint)+` \| time=\S+\nkex algorithms: .*curve25519-sha256.*\nhost key algorithms: ssh-ed25519\nciphers: .*aes128-gcm@openssh.com.*\nmacs: .*hmac-sha2-256`,
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", port, "--fingerprint", "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "host key "+fingerprint+" does not match any pinned fingerprint", "output matches")
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{"[127.0.0.1]:" + port}, hostKey.PublicKey())+"\n"), 0o600))
+	res = snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", port, "--known-hosts", knownHosts})
+	assert.Equalf(t, CheckExitOK, res.State, "state ok")
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherSigner, err := ssh.NewSignerFromKey(otherKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{"[127.0.0.1]:" + port}, otherSigner.PublicKey())+"\n"), 0o600))
+	res = snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", port, "--known-hosts", knownHosts})
+	assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "host key "+fingerprint+" does not match "+knownHosts+":1", "output matches")
+
+	weakPort := startTestSSHServer(t, ssh.Config{
+		KeyExchanges: []string{"curve25519-sha256", "diffie-hellman-group1-sha1"},
+		Ciphers:      []string{"aes128-ctr", "aes128-cbc"},
+		MACs:         []string{"hmac-sha2-256", "hmac-sha1-96"},
+	}, hostKey)
+	res = snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", weakPort})
+	assert.Equalf(t, CheckExitWarning, res.State, "state warning")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "weak algorithms offered: diffie-hellman-group1-sha1, aes128-cbc, hmac-sha1-96 |", "output matches")
+
+	res = snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", weakPort, "--ignore-weak-algorithms"})
+	assert.Equalf(t, CheckExitOK, res.State, "state ok")
+
+	// the ecdsa key is preferred, but only the ed25519 key is known
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecdsaSigner, err := ssh.NewSignerFromKey(ecdsaKey)
+	require.NoError(t, err)
+	multiPort := startTestSSHServer(t, ssh.Config{}, ecdsaSigner, hostKey)
+	require.NoError(t, os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{"[127.0.0.1]:" + multiPort}, hostKey.PublicKey())+"\n"), 0o600))
+	res = snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", multiPort, "--known-hosts", knownHosts, "--ignore-weak-algorithms"})
+	assert.Equalf(t, CheckExitOK, res.State, "state ok")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "ssh-ed25519 host key "+fingerprint, "output matches")
+
+	res = snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", multiPort, "--fingerprint", fingerprint, "--ignore-weak-algorithms"})
+	assert.Equalf(t, CheckExitOK, res.State, "state ok")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "ssh-ed25519 host key "+fingerprint, "output matches")
+
+	res = snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", multiPort, "--fingerprint", "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "ecdsa-sha2-nistp256 host key "+ssh.FingerprintSHA256(ecdsaSigner.PublicKey())+" does not match any pinned fingerprint", "output matches")
+
+	// certificate options are only available in check_tcp
+	res = snc.RunCheck("check_ssh", []string{"-H", "127.0.0.1", "-p", port, "--starttls", "smtp"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "unknown flag `starttls'", "output matches")
+}
+
+// startTestSSHServer starts a ssh server which runs the key exchange and rejects all authentication attempts
+func startTestSSHServer(t *testing.T, algorithms ssh.Config, hostKeys ...ssh.Signer) string {
+	t.Helper()
+
+	serverConfig := &ssh.ServerConfig{
+		Config: algorithms,
+		PasswordCallback: func(_ ssh.ConnMetadata, _ []byte) (*ssh.Permissions, error) {
+			return nil, fmt.Errorf("access denied")
+		},
+	}
+	for _, hostKey := range hostKeys {
+		serverConfig.AddHostKey(hostKey)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _, _, _ = ssh.NewServerConn(conn, serverConfig)
+			}()
+		}
+	}()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	return port
+}
@@ -0,0 +1,363 @@
+package snclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/consol-monitoring/snclient/pkg/convert"
+	"github.com/consol-monitoring/snclient/pkg/utils"
+)
+
+func init() {
+	AvailableChecks["check_sysctl"] = CheckEntry{"check_sysctl", NewCheckSysctl}
+}
+
+var (
+	sysctlProcPath = "/proc/sys"
+
+	// sysctlDefaultAllowedKeys is used if 'allowed keys' is not set in /settings/check/sysctl
+	sysctlDefaultAllowedKeys = []string{"fs.**", "kernel.**", "net.**", "vm.**"}
+
+	// sysctlDefaultAllowedFiles is used if 'allowed files' is not set in /settings/check/sysctl
+	sysctlDefaultAllowedFiles = []string{"/sys/kernel/mm/transparent_hugepage/*"}
+
+	// process specific folders are never readable, they contain environment, command lines and the root filesystem of processes
+	reSysctlProcessPath = regexp.MustCompile(`^/proc/(\d+|self|thread-self)(/|$)`)
+
+	// sysctlDefaultKeys are checked if neither key nor file is set, missing keys are skipped
+	sysctlDefaultKeys = []string{"fs.file-nr", "net.netfilter.nf_conntrack_count"}
+
+	// sysctlUsagePairs contains keys with a known limit to calculate the usage percentage
+	sysctlUsagePairs = map[string]string{
+		"net.netfilter.nf_conntrack_count": "net.netfilter.nf_conntrack_max",
+		"net.nf_conntrack_count":           "net.nf_conntrack_max",
+		"fs.aio-nr":                        "fs.aio-max-nr",
+		"kernel.pty.nr":                    "kernel.pty.max",
+	}
+)
+
+// sysctlMaxReadSize limits the number of bytes read from a single key or file
+const sysctlMaxReadSize = 64 * 1024
+
+type CheckSysctl struct {
+	keys         []string
+	files        []string
+	expect       []string
+	allowedKeys  []string
+	allowedFiles []string
+}
+
+func NewCheckSysctl() CheckHandler {
+	return &CheckSysctl{}
+}
+
+func (l *CheckSysctl) Build() *CheckData {
+	return &CheckData{
+		name: "check_sysctl",
+		description: `Checks kernel parameters and values from /proc and /sys.
+
+    Only keys and files matching the 'allowed keys' and 'allowed files' patterns from the
+    '[/settings/check/sysctl]' section of the snclient_local.ini can be read.
+
+    Example:
+    [/settings/check/sysctl]
+    allowed keys   = fs.**, kernel.**, net.**, vm.**
+    allowed files  = /sys/kernel/mm/transparent_hugepage/*
+    allowed files += /proc/pressure/*
+
+    Files are matched after resolving symlinks, process specific folders like /proc/<pid> or /proc/self are never allowed.
+    See https://github.com/bmatcuk/doublestar#patterns for details on the pattern syntax.
+`,
+		implemented:  Linux,
+		hasInventory: NoInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"key":    {value: &l.keys, isFilter: true, description: "Sysctl key to check, ex.: vm.swappiness. Must match the allowed keys. Can be used multiple times"},
+			"file":   {value: &l.files, isFilter: true, description: "File from /proc or /sys to check, ex.: /sys/kernel/mm/transparent_hugepage/enabled. Must match the allowed files. Can be used multiple times"},
+			"expect": {value: &l.expect, description: "Expected value of a key or file, ex.: vm.swappiness=10. Can be used multiple times"},
+		},
+		defaultWarning:  "pct > 80",
+		defaultCritical: "pct > 90 || match = 0",
+		detailSyntax:    "${key} = ${value}",
+		okSyntax:        "%(status) - all %{count} values are ok",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no values found",
+		attributes: []CheckAttribute{
+			{name: "key", description: "Sysctl key or file name"},
+			{name: "value", description: "Value as text, multiple fields are separated by a single space"},
+			{name: "numeric", description: "First field of the value as number, used file handles for fs.file-nr (empty if not numeric)"},
+			{name: "expected", description: "Expected value from the expect argument (empty if not set)"},
+			{name: "match", description: "Flag whether the value matches the expected value: 0 / 1 (empty if not set)"},
+			{name: "max", description: "Limit of the value for known pairs: nf_conntrack_count, fs.aio-nr, kernel.pty.nr and fs.file-nr (empty if unknown)"},
+			{name: "pct", description: "Usage in percent of the limit for known pairs (empty if unknown)", unit: UPercent},
+		},
+		exampleDefault: `
+    check_sysctl
+    OK - all 2 values are ok |'fs.file-nr'=12544;;;0;9223372036854775807 'fs.file-nr_pct'=0%;80;90;0;100 ...
+
+Without arguments, the usage of open files (fs.file-nr) and the connection tracking table (nf_conntrack_count / nf_conntrack_max) is checked.
+
+Check kernel tunables:
+
+    check_sysctl key=vm.swappiness key=net.core.somaxconn expect=vm.swappiness=10 expect=net.core.somaxconn=4096
+    CRITICAL - critical(vm.swappiness = 60) |'vm.swappiness'=60 'net.core.somaxconn'=4096
+
+Check a value from /sys:
+
+    check_sysctl file=/sys/kernel/mm/transparent_hugepage/enabled crit="value like '[always]'"
+	`,
+		exampleArgs: `key=vm.swappiness expect=vm.swappiness=10`,
+	}
+}
+
+func (l *CheckSysctl) Check(_ context.Context, snc *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	section := snc.config.Section("/settings/check/sysctl")
+	allowedKeys, ok := section.GetStringList("allowed keys")
+	if !ok {
+		allowedKeys = sysctlDefaultAllowedKeys
+	}
+	l.allowedKeys = allowedKeys
+	allowedFiles, ok := section.GetStringList("allowed files")
+	if !ok {
+		allowedFiles = sysctlDefaultAllowedFiles
+	}
+	l.allowedFiles = allowedFiles
+
+	expected := map[string]string{}
+	for _, expect := range l.expect {
+		key, val, found := strings.Cut(expect, "=")
+		if !found {
+			return nil, fmt.Errorf("expect must be in the form key=value, got: %s", expect)
+		}
+		expected[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+
+	keys := l.keys
+	skipMissing := false
+	if len(l.keys) == 0 && len(l.files) == 0 {
+		keys = sysctlDefaultKeys
+		skipMissing = true
+	}
+
+	entries := []map[string]string{}
+	for _, key := range keys {
+		value, err := l.readSysctl(key)
+		if err != nil {
+			if skipMissing {
+				log.Debugf("skipping sysctl %s: %s", key, err.Error())
+
+				continue
+			}
+
+			return nil, err
+		}
+		entry := l.buildEntry(key, value, expected)
+		l.addUsage(entry)
+		entries = append(entries, entry)
+	}
+
+	for _, file := range l.files {
+		value, err := l.readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, l.buildEntry(file, value, expected))
+	}
+
+	for _, entry := range entries {
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+		check.listData = append(check.listData, entry)
+		l.addMetrics(check, entry)
+	}
+
+	return check.Finalize()
+}
+
+// sysctlName returns the key with slashes as separator, keys may use dots (net.ipv4.ip_forward) or slashes (net/ipv4/conf/eth0.100/rp_filter)
+func (l *CheckSysctl) sysctlName(key string) string {
+	if !strings.Contains(key, "/") {
+		key = strings.ReplaceAll(key, ".", "/")
+	}
+
+	return strings.TrimPrefix(filepath.Clean("/"+key), "/")
+}
+
+func (l *CheckSysctl) readSysctl(key string) (string, error) {
+	name := l.sysctlName(key)
+	allowed := false
+	for _, pattern := range l.allowedKeys {
+		if matched, _ := doublestar.Match(l.sysctlName(pattern), name); matched {
+			allowed = true
+
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("sysctl %s is not allowed, see 'allowed keys' in [/settings/check/sysctl]", key)
+	}
+
+	value, err := l.readLimited(filepath.Join(sysctlProcPath, name))
+	if err != nil {
+		return "", fmt.Errorf("cannot read sysctl %s: %s", key, err.Error())
+	}
+
+	return value, nil
+}
+
+func (l *CheckSysctl) readFile(file string) (string, error) {
+	file = filepath.Clean(file)
+	resolved, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %s", file, err.Error())
+	}
+
+	if reSysctlProcessPath.MatchString(file) || reSysctlProcessPath.MatchString(resolved) {
+		return "", fmt.Errorf("file %s is not allowed, process specific files cannot be read", file)
+	}
+
+	allowed := false
+	for _, pattern := range l.allowedFiles {
+		if matched, _ := doublestar.PathMatch(pattern, resolved); matched {
+			allowed = true
+
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("file %s is not allowed, see 'allowed files' in [/settings/check/sysctl]", file)
+	}
+
+	value, err := l.readLimited(resolved)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %s", file, err.Error())
+	}
+
+	return value, nil
+}
+
+// readLimited returns the whitespace normalized content of a file, files larger than sysctlMaxReadSize result in an error
+func (l *CheckSysctl) readLimited(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open: %s", err.Error())
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, sysctlMaxReadSize+1))
+	if err != nil {
+		return "", fmt.Errorf("read: %s", err.Error())
+	}
+	if len(data) > sysctlMaxReadSize {
+		return "", fmt.Errorf("file exceeds the maximum size of %d bytes", sysctlMaxReadSize)
+	}
+
+	return strings.Join(strings.Fields(string(data)), " "), nil
+}
+
+func (l *CheckSysctl) buildEntry(key, value string, expected map[string]string) map[string]string {
+	entry := map[string]string{
+		"key":      key,
+		"value":    value,
+		"numeric":  "",
+		"expected": "",
+		"match":    "",
+		"max":      "",
+		"pct":      "",
+	}
+
+	if fields := strings.Fields(value); len(fields) > 0 {
+		if _, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			entry["numeric"] = fields[0]
+		}
+	}
+
+	if exp, ok := expected[key]; ok {
+		entry["expected"] = exp
+		entry["match"] = "0"
+		if exp == value || (entry["numeric"] != "" && convert.Float64(exp) == convert.Float64(entry["numeric"]) && !strings.Contains(value, " ")) {
+			entry["match"] = "1"
+		}
+	}
+
+	return entry
+}
+
+// addUsage calculates the usage percentage for known value / limit pairs
+func (l *CheckSysctl) addUsage(entry map[string]string) {
+	var used, limit float64
+	switch entry["key"] {
+	case "fs.file-nr":
+		// allocated, unused and max file handles
+		fields := strings.Fields(entry["value"])
+		if len(fields) != 3 {
+			return
+		}
+		used = convert.Float64(fields[0]) - convert.Float64(fields[1])
+		limit = convert.Float64(fields[2])
+		entry["numeric"] = strconv.FormatFloat(used, 'f', -1, 64)
+		entry["max"] = fields[2]
+	default:
+		maxKey := sysctlUsagePairs[entry["key"]]
+		if maxKey == "" {
+			return
+		}
+		maxVal, err := l.readSysctl(maxKey)
+		if err != nil {
+			log.Debugf("cannot read limit for %s: %s", entry["key"], err.Error())
+
+			return
+		}
+		used = convert.Float64(entry["numeric"])
+		limit = convert.Float64(maxVal)
+		entry["max"] = maxVal
+	}
+
+	if limit > 0 {
+		entry["pct"] = strconv.FormatFloat(utils.ToPrecision(used*100/limit, 2), 'f', -1, 64)
+	}
+}
+
+func (l *CheckSysctl) addMetrics(check *CheckData, entry map[string]string) {
+	if entry["numeric"] == "" {
+		return
+	}
+
+	metric := &CheckMetric{
+		Name:          entry["key"],
+		ThresholdName: "numeric",
+		Value:         convert.Float64(entry["numeric"]),
+		Warning:       check.warnThreshold,
+		Critical:      check.critThreshold,
+	}
+	if entry["max"] != "" {
+		maxVal := convert.Float64(entry["max"])
+		metric.Min = &Zero
+		metric.Max = &maxVal
+	}
+	check.result.Metrics = append(check.result.Metrics, metric)
+
+	if entry["pct"] != "" {
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["key"] + "_pct",
+			ThresholdName: "pct",
+			Unit:          "%",
+			Value:         convert.Float64(entry["pct"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+			Max:           &Hundred,
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package snclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSysctl(t *testing.T) {
+	tmpDir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoErrorf(t, err, "resolving tmp dir works")
+	origPath := sysctlProcPath
+	sysctlProcPath = filepath.Join(tmpDir, "proc", "sys")
+	defer func() { sysctlProcPath = origPath }()
+
+	config := `
+[/settings/check/sysctl]
+allowed keys = fs.*, net.**, vm.*
+allowed files = ` + filepath.Join(tmpDir, "sys") + `/**, /proc/self/**
+`
+	snc := StartTestAgent(t, config)
+	defer StopTestAgent(t, snc)
+
+	writeTestFile(t, filepath.Join(sysctlProcPath, "fs", "file-nr"), "12544\t544\t100000\n")
+	writeTestFile(t, filepath.Join(sysctlProcPath, "net", "netfilter", "nf_conntrack_count"), "60000\n")
+	writeTestFile(t, filepath.Join(sysctlProcPath, "net", "netfilter", "nf_conntrack_max"), "65536\n")
+	writeTestFile(t, filepath.Join(sysctlProcPath, "vm", "swappiness"), "60\n")
+	writeTestFile(t, filepath.Join(sysctlProcPath, "net", "ipv4", "conf", "eth0.100", "rp_filter"), "1\n")
+	writeTestFile(t, filepath.Join(tmpDir, "sys", "kernel", "mm", "transparent_hugepage", "enabled"), "always [madvise] never\n")
+
+	res := snc.RunCheck("check_sysctl", []string{})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - critical(net.netfilter.nf_conntrack_count = 60000) |"+
+		"'fs.file-nr'=12000;;;0;100000 'fs.file-nr_pct'=12%;80;90;0;100 "+
+		"'net.netfilter.nf_conntrack_count'=60000;;;0;65536 'net.netfilter.nf_conntrack_count_pct'=91.55%;80;90;0;100",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_sysctl", []string{"key=vm.swappiness", "key=net/ipv4/conf/eth0.100/rp_filter", "expect=vm.swappiness=10", "expect=net/ipv4/conf/eth0.100/rp_filter=1"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - critical(vm.swappiness = 60) |'vm.swappiness'=60 'net/ipv4/conf/eth0.100/rp_filter'=1",
+		string(res.BuildPluginOutput()), "output matches")
+
+	thp := filepath.Join(tmpDir, "sys", "kernel", "mm", "transparent_hugepage", "enabled")
+	res = snc.RunCheck("check_sysctl", []string{"file=" + thp, "warn=value like '[always]'"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - all 1 values are ok", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_sysctl", []string{"file=" + thp, "expect=" + thp + "=always [madvise] never"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+
+	res = snc.RunCheck("check_sysctl", []string{"file=/etc/shadow"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "file /etc/shadow is not allowed", "output matches")
+
+	// process specific files are never allowed, even if they match an allowed pattern
+	res = snc.RunCheck("check_sysctl", []string{"file=/proc/self/root/etc/hostname"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "process specific files cannot be read", "output matches")
+
+	// symlinks are resolved before checking the allowed files
+	secret := filepath.Join(tmpDir, "secret")
+	writeTestFile(t, secret, "secret\n")
+	link := filepath.Join(tmpDir, "sys", "link")
+	require.NoErrorf(t, os.Symlink(secret, link), "creating symlink works")
+	res = snc.RunCheck("check_sysctl", []string{"file=" + link})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "file "+link+" is not allowed", "output matches")
+
+	large := filepath.Join(tmpDir, "sys", "large")
+	writeTestFile(t, large, strings.Repeat("1", sysctlMaxReadSize+1))
+	res = snc.RunCheck("check_sysctl", []string{"file=" + large})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "file exceeds the maximum size", "output matches")
+
+	res = snc.RunCheck("check_sysctl", []string{"key=kernel.hostname"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "sysctl kernel.hostname is not allowed", "output matches")
+
+	res = snc.RunCheck("check_sysctl", []string{"key=vm.missing"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "cannot read sysctl vm.missing", "output matches")
+}
@@ -25,6 +25,14 @@ Send something and expect specific string:
     check_tcp -H outlook.com -p 25 -s "HELO" -e "Microsoft ESMTP MAIL Service ready" -q "QUIT
     TCP OK - 0.197 seconds response time on outlook.com port 25
 
+Negotiate STARTTLS and check the certificate of a mail server, warn 30 days and critical 14 days before it expires:
+
+    check_tcp -H mail.example.com --starttls smtp --certificate 30,14 -q "QUIT"
+    TCP OK - 0.184 seconds response time on mail.example.com port 25 - x509 certificate 'mail.example.com' from 'R11' is valid until ... | time=0.184s;;;0.000000;10.000000 days_chain_elem1=71;30;14;0 ...
+
+Supported protocols are smtp, imap, pop3, ftp, ldap, postgres and xmpp.
+Use --certificate together with --ssl to check certificates of implicit tls connections.
+
 It can be a bit tricky to set the -u/--uri on windows, since the / is considered as start of
 a command line parameter.
 
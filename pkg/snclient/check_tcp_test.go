@@ -1,10 +1,15 @@
 package snclient
 
 import (
+	"bufio"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCheckTCP(t *testing.T) {
@@ -31,3 +36,60 @@ use ssl = false
 
 	StopTestAgent(t, snc)
 }
+
+func TestCheckTCPStartTLS(t *testing.T) {
+	// borrow the self-signed certificate of the httptest server
+	certServer := httptest.NewTLSServer(nil)
+	defer certServer.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				fmt.Fprint(conn, "220 mail.example.com ESMTP\r\n")
+				_, _ = reader.ReadString('\n') // EHLO
+				fmt.Fprint(conn, "250-mail.example.com\r\n250-PIPELINING\r\n250 STARTTLS\r\n")
+				_, _ = reader.ReadString('\n') // STARTTLS
+				fmt.Fprint(conn, "220 ready to start TLS\r\n")
+				tlsConn := tls.Server(conn, certServer.TLS)
+				if tlsConn.Handshake() != nil {
+					return
+				}
+				_, _ = bufio.NewReader(tlsConn).ReadString('\n') // QUIT
+			}()
+		}
+	}()
+
+	port := fmt.Sprintf("%d", listener.Addr().(*net.TCPAddr).Port)
+	snc := StartTestAgent(t, `
+[/modules]
+CheckBuiltinPlugins = enabled
+`)
+
+	res := snc.RunCheck("check_tcp", []string{"-H", "127.0.0.1", "-p", port, "--starttls", "smtp", "-q", "QUIT"})
+	assert.Equalf(t, CheckExitOK, res.State, "state ok")
+	assert.Regexpf(t,
+		`^TCP OK - [\d.]+ seconds response time on 127.0.0.1 port \d+ - x509 certificate '' from '' is valid until .* \| time=[\d.]+s;;;[\d.]+;[\d.]+ days_chain_elem1=\d+;30;14;0`,
+		string(res.BuildPluginOutput()),
+		"output matches",
+	)
+
+	res = snc.RunCheck("check_tcp", []string{"-H", "127.0.0.1", "-p", port, "--starttls", "smtp", "--certificate", "100000"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state warning")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "(expires in", "output contains expiry")
+
+	res = snc.RunCheck("check_tcp", []string{"-H", "127.0.0.1", "-p", port, "--starttls", "imap"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "starttls imap: unexpected greeting", "output contains error")
+
+	StopTestAgent(t, snc)
+}
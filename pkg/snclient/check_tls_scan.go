@@ -0,0 +1,265 @@
+package snclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/consol-monitoring/snclient/pkg/check_http"
+)
+
+func init() {
+	AvailableChecks["check_tls_scan"] = CheckEntry{"check_tls_scan", NewCheckTLSScan}
+}
+
+// tlsScanVersions contains all tls versions which are probed, from old to new
+var tlsScanVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+type CheckTLSScan struct {
+	hostname   string
+	serverName string
+	port       int64
+	timeout    float64
+}
+
+func NewCheckTLSScan() CheckHandler {
+	return &CheckTLSScan{
+		port:    443,
+		timeout: 5,
+	}
+}
+
+func (l *CheckTLSScan) Build() *CheckData {
+	return &CheckData{
+		name:         "check_tls_scan",
+		description:  "Probes a tls endpoint with each tls version and cipher suite and checks the accepted ones against a policy.",
+		implemented:  ALL,
+		hasInventory: NoInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"host":       {value: &l.hostname, description: "Host name or ip address to scan"},
+			"port":       {value: &l.port, description: "Port to scan (default: 443)"},
+			"servername": {value: &l.serverName, description: "Server name to send with SNI (default: host if it is not an ip address)"},
+			"timeout":    {value: &l.timeout, description: "Timeout in seconds for each handshake (default: 5)"},
+		},
+		defaultFilter:   "none",
+		defaultWarning:  "(key_type = 'RSA' and key_size < 2048) || (key_type = 'ECDSA' and key_size < 256)",
+		defaultCritical: "version < 1.2 || insecure = 1",
+		detailSyntax:    "${protocol} ${cipher} (${key_type} ${key_size}bit)",
+		okSyntax:        "%(status) - all %{count} accepted cipher suites comply with the policy",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitCritical,
+		emptySyntax:     "%(status) - no tls version or cipher suite accepted",
+		attributes: []CheckAttribute{
+			{name: "host", description: "Scanned host"},
+			{name: "port", description: "Scanned port"},
+			{name: "protocol", description: "Protocol name, ex.: TLS 1.2"},
+			{name: "version", description: "Protocol version as number, ex.: 1.2"},
+			{name: "cipher", description: "Name of the cipher suite, ex.: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			{name: "kex", description: "Key exchange, ex.: ECDHE, RSA or the group name for tls 1.3"},
+			{name: "group", description: "Negotiated key exchange group, ex.: X25519 or X25519MLKEM768"},
+			{name: "insecure", description: "Cipher suite has known security issues: 0 / 1"},
+			{name: "key_type", description: "Public key type of the server certificate: RSA, ECDSA or Ed25519"},
+			{name: "key_size", description: "Public key size of the server certificate in bits"},
+			{name: "ocsp_stapled", description: "Server sent a stapled OCSP response: 0 / 1"},
+			{name: "subject", description: "Subject of the server certificate"},
+		},
+		exampleDefault: `
+    check_tls_scan host=www.example.com
+    OK - all 6 accepted cipher suites comply with the policy |'accepted_cipher_suites'=6;;;0
+
+    check_tls_scan host=legacy.example.com
+    CRITICAL - critical(TLS 1.0 TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA (RSA 2048bit), TLS 1.2 TLS_RSA_WITH_3DES_EDE_CBC_SHA (RSA 2048bit)) |...
+
+Set your own policy, ex.: forbid cbc ciphers and require ocsp stapling:
+
+    check_tls_scan host=www.example.com crit="version < 1.2 || insecure = 1 || cipher like '_CBC_'" warn="ocsp_stapled = 0"
+
+Show all accepted cipher suites:
+
+    check_tls_scan host=www.example.com show-all detail-syntax="${protocol} ${cipher} ${kex}"
+
+TLS 1.3 cipher suites cannot be selected by the client, so only the preferred suite of the server is listed for TLS 1.3.
+	`,
+		exampleArgs: `host=www.example.com crit="version < 1.2 || insecure = 1"`,
+	}
+}
+
+func (l *CheckTLSScan) Check(ctx context.Context, _ *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	if l.hostname == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+
+	if l.serverName == "" && net.ParseIP(l.hostname) == nil {
+		l.serverName = l.hostname
+	}
+
+	addr := net.JoinHostPort(l.hostname, strconv.FormatInt(l.port, 10))
+	timeout := time.Duration(l.timeout * float64(time.Second))
+
+	// make sure the port is reachable at all, otherwise every probe would fail
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %s: %s", addr, err.Error())
+	}
+	conn.Close()
+
+	for _, version := range tlsScanVersions {
+		for _, suite := range l.cipherSuites(version) {
+			state, err := l.probe(ctx, addr, timeout, version, suite)
+			if err != nil {
+				log.Tracef("tls scan %s: %s %s not accepted: %s", addr, tls.VersionName(version), suiteName(suite), err.Error())
+
+				continue
+			}
+
+			entry := l.buildEntry(state)
+			if !check.MatchMapCondition(check.filter, entry, true) {
+				continue
+			}
+			check.listData = append(check.listData, entry)
+		}
+	}
+
+	check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+		Name:  "accepted_cipher_suites",
+		Value: len(check.listData),
+		Min:   &Zero,
+	})
+
+	return check.Finalize()
+}
+
+// cipherSuites returns all cipher suites go supports for the given version.
+// TLS 1.3 suites are not configurable, a single nil entry is returned in that case.
+func (l *CheckTLSScan) cipherSuites(version uint16) []*tls.CipherSuite {
+	if version == tls.VersionTLS13 {
+		return []*tls.CipherSuite{nil}
+	}
+
+	suites := []*tls.CipherSuite{}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if slices.Contains(suite.SupportedVersions, version) {
+			suites = append(suites, suite)
+		}
+	}
+
+	return suites
+}
+
+// probe runs a single handshake with exactly this version and cipher suite
+func (l *CheckTLSScan) probe(ctx context.Context, addr string, timeout time.Duration, version uint16, suite *tls.CipherSuite) (*tls.ConnectionState, error) {
+	conf := check_http.MakeTLSConfig(l.serverName, version, version)
+	if suite != nil {
+		conf.CipherSuites = []uint16{suite.ID}
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    conf,
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("handshake failed: %w", err)
+	}
+	defer conn.Close()
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil, fmt.Errorf("unexpected connection type: %T", conn)
+	}
+	state := tlsConn.ConnectionState()
+
+	return &state, nil
+}
+
+func (l *CheckTLSScan) buildEntry(state *tls.ConnectionState) map[string]string {
+	entry := map[string]string{
+		"host":         l.hostname,
+		"port":         fmt.Sprintf("%d", l.port),
+		"protocol":     tls.VersionName(state.Version),
+		"version":      strings.TrimPrefix(tls.VersionName(state.Version), "TLS "),
+		"cipher":       tls.CipherSuiteName(state.CipherSuite),
+		"kex":          "",
+		"group":        "",
+		"insecure":     "0",
+		"key_type":     "",
+		"key_size":     "",
+		"ocsp_stapled": "0",
+		"subject":      "",
+	}
+
+	if state.CurveID != 0 {
+		entry["group"] = state.CurveID.String()
+	}
+
+	entry["kex"] = cipherSuiteKeyExchange(entry["cipher"])
+	if state.Version == tls.VersionTLS13 {
+		entry["kex"] = entry["group"]
+	}
+
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == state.CipherSuite {
+			entry["insecure"] = "1"
+		}
+	}
+
+	if len(state.OCSPResponse) > 0 {
+		entry["ocsp_stapled"] = "1"
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		entry["subject"] = cert.Subject.String()
+		entry["key_type"], entry["key_size"] = publicKeyInfo(cert)
+	}
+
+	return entry
+}
+
+// cipherSuiteKeyExchange returns the key exchange part of a tls 1.0-1.2 cipher suite name, ex.: ECDHE for TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+func cipherSuiteKeyExchange(name string) string {
+	name = strings.TrimPrefix(name, "TLS_")
+	kex, _, found := strings.Cut(name, "_WITH_")
+	if !found {
+		return ""
+	}
+
+	kex, _, _ = strings.Cut(kex, "_")
+
+	return kex
+}
+
+// publicKeyInfo returns the type and size in bits of the certificate public key
+func publicKeyInfo(cert *x509.Certificate) (keyType, keySize string) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", strconv.Itoa(key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA", strconv.Itoa(key.Curve.Params().BitSize)
+	case ed25519.PublicKey:
+		return "Ed25519", "256"
+	default:
+		return cert.PublicKeyAlgorithm.String(), ""
+	}
+}
+
+func suiteName(suite *tls.CipherSuite) string {
+	if suite == nil {
+		return "default suites"
+	}
+
+	return suite.Name
+}
@@ -0,0 +1,58 @@
+package snclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTLSScan(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	modern := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	modern.Config.ErrorLog = NewStandardLog("TRACE")
+	modern.StartTLS()
+	defer modern.Close()
+
+	modernURL, err := url.Parse(modern.URL)
+	require.NoError(t, err)
+
+	res := snc.RunCheck("check_tls_scan", []string{"host=127.0.0.1", "port=" + modernURL.Port()})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Regexpf(t, `^OK - all \d+ accepted cipher suites comply with the policy \|'accepted_cipher_suites'=\d+;;;0`,
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_tls_scan", []string{"host=127.0.0.1", "port=" + modernURL.Port(), "show-all", "warn=ocsp_stapled = 0",
+		"detail-syntax=${protocol} ${cipher} ${kex}", "filter=version = 1.3"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Regexpf(t, `^WARNING - TLS 1.3 TLS_AES_\w+ X25519`, string(res.BuildPluginOutput()), "output matches")
+
+	legacy := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	legacy.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS10,
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	}
+	legacy.Config.ErrorLog = NewStandardLog("TRACE")
+	legacy.StartTLS()
+	defer legacy.Close()
+
+	legacyURL, err := url.Parse(legacy.URL)
+	require.NoError(t, err)
+
+	res = snc.RunCheck("check_tls_scan", []string{"host=127.0.0.1", "port=" + legacyURL.Port()})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Regexpf(t, `^CRITICAL - critical\(TLS 1.0 TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA \(RSA \d+bit\), TLS 1.1 TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA`,
+		string(res.BuildPluginOutput()), "output matches")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "'accepted_cipher_suites'=4;;;0", "tls 1.0, 1.1 and two tls 1.2 suites accepted")
+
+	res = snc.RunCheck("check_tls_scan", []string{"host=127.0.0.1", "port=1"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "cannot connect to 127.0.0.1:1", "output matches")
+}
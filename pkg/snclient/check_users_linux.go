@@ -0,0 +1,309 @@
+package snclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/consol-monitoring/snclient/pkg/utils"
+)
+
+func init() {
+	AvailableChecks["check_users"] = CheckEntry{"check_users", NewCheckUsers}
+}
+
+var (
+	usersUtmpFile = "/var/run/utmp"
+	usersWtmpFile = "/var/log/wtmp"
+	usersBtmpFile = "/var/log/btmp"
+	usersDevPath  = "/dev"
+)
+
+// utmp record types, see man 5 utmp
+const (
+	utmpRunLevel    = 1
+	utmpBootTime    = 2
+	utmpUserProcess = 7
+)
+
+const usersDefaultOkSyntax = "%(status) - %{count} user(s) logged in"
+
+// utmpRecord is the binary layout of struct utmp on linux
+type utmpRecord struct {
+	Type    int16
+	_       [2]byte
+	Pid     int32
+	Line    [32]byte
+	ID      [4]byte
+	User    [32]byte
+	Host    [256]byte
+	Exit    [2]int16
+	Session int32
+	TvSec   int32
+	TvUsec  int32
+	AddrV6  [4]int32
+	_       [20]byte
+}
+
+type CheckUsers struct {
+	mode      string
+	scanRange string
+}
+
+func NewCheckUsers() CheckHandler {
+	return &CheckUsers{
+		mode:      "sessions",
+		scanRange: "24h",
+	}
+}
+
+func (l *CheckUsers) Build() *CheckData {
+	return &CheckData{
+		name:         "check_users",
+		description:  "Checks logged in users and the login, reboot and failed login history from utmp/wtmp/btmp.",
+		implemented:  Linux,
+		hasInventory: ListInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"mode":       {value: &l.mode, description: "Show current 'sessions' from utmp or the login 'history' from wtmp and btmp (default: sessions)"},
+			"scan-range": {value: &l.scanRange, description: "Time range to scan the history for (default: 24h)"},
+		},
+		detailSyntax: "${type} ${user} (${tty})",
+		okSyntax:     usersDefaultOkSyntax,
+		topSyntax:    "%(status) - ${problem_list || 'found ${count} entries'}",
+		emptySyntax:  "%(status) - no entries found",
+		emptyState:   CheckExitOK,
+		attributes: []CheckAttribute{
+			{name: "type", description: "Type of the entry: session (current sessions) or login, reboot, shutdown and failed (history)"},
+			{name: "user", description: "User name"},
+			{name: "tty", description: "Terminal, ex.: pts/0"},
+			{name: "host", description: "Remote host or display"},
+			{name: "remote", description: "Flag whether this is a remote login: 0 / 1"},
+			{name: "pid", description: "Process id of the login process"},
+			{name: "time", description: "Unix timestamp of the login, reboot or failed login", unit: UTimestamp},
+			{name: "idle", description: "Seconds since the last terminal input (current sessions only)", unit: UDuration},
+			{name: "clean", description: "Flag whether a reboot was preceded by a clean shutdown: 0 / 1 (reboots only, empty if unknown)"},
+		},
+		exampleDefault: `
+    check_users
+    OK - 2 user(s) logged in |'count'=2;;;0
+
+Warn if root is logged in via ssh:
+
+    check_users warn="user = 'root' && remote = 1"
+    WARNING - warning(session root (pts/0)) |'count'=2;;;0
+
+Critical if there were more than 5 failed logins within the last 10 minutes:
+
+    check_users mode=history scan-range=10m filter="type = 'failed'" crit="count > 5"
+    CRITICAL - found 8 entries |'count'=8;;5;0
+
+Warn on unexpected reboots within the last day:
+
+    check_users mode=history scan-range=1d filter="type = 'reboot'" warn="clean = 0"
+	`,
+		exampleArgs: `warn="user = 'root' && remote = 1"`,
+	}
+}
+
+func (l *CheckUsers) Check(_ context.Context, _ *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	var entries []map[string]string
+	var err error
+
+	switch l.mode {
+	case "sessions":
+		entries, err = l.getSessions()
+	case "history":
+		if check.okSyntax == usersDefaultOkSyntax {
+			check.okSyntax = "%(status) - %{count} entries in history"
+		}
+		entries, err = l.getHistory()
+	default:
+		return nil, fmt.Errorf("unknown mode %q, must be sessions or history", l.mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+		check.listData = append(check.listData, entry)
+	}
+
+	check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+		ThresholdName: "count",
+		Name:          "count",
+		Value:         len(check.listData),
+		Warning:       check.warnThreshold,
+		Critical:      check.critThreshold,
+		Min:           &Zero,
+	})
+
+	return check.Finalize()
+}
+
+// getSessions returns currently logged in users from utmp
+func (l *CheckUsers) getSessions() ([]map[string]string, error) {
+	records, err := l.readRecords(usersUtmpFile)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []map[string]string{}
+	for i := range records {
+		if records[i].Type != utmpUserProcess {
+			continue
+		}
+		entry := l.recordEntry(&records[i], "session")
+		entry["idle"] = l.idleTime(entry["tty"])
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// getHistory returns logins, reboots and shutdowns from wtmp and failed logins from btmp within the scan range
+func (l *CheckUsers) getHistory() ([]map[string]string, error) {
+	scanRange, err := utils.ExpandDuration(l.scanRange)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse scan-range: %s", err.Error())
+	}
+	if scanRange < 0 {
+		scanRange *= -1
+	}
+	since := time.Now().Unix() - int64(scanRange)
+
+	entries := []map[string]string{}
+	records, err := l.readRecords(usersWtmpFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// unknown until the first shutdown or reboot record has been seen
+	shutdown := ""
+	for i := range records {
+		record := &records[i]
+		var entry map[string]string
+		switch {
+		case record.Type == utmpUserProcess:
+			entry = l.recordEntry(record, "login")
+		case record.Type == utmpRunLevel && l.cString(record.User[:]) == "shutdown":
+			entry = l.recordEntry(record, "shutdown")
+			shutdown = "1"
+		case record.Type == utmpBootTime:
+			entry = l.recordEntry(record, "reboot")
+			entry["clean"] = shutdown
+			shutdown = "0"
+		default:
+			continue
+		}
+		if int64(record.TvSec) >= since {
+			entries = append(entries, entry)
+		}
+	}
+
+	records, err = l.readRecords(usersBtmpFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Debugf("%s does not exist, skipping failed logins", usersBtmpFile)
+	case err != nil:
+		return nil, err
+	}
+	for i := range records {
+		if int64(records[i].TvSec) >= since {
+			entries = append(entries, l.recordEntry(&records[i], "failed"))
+		}
+	}
+
+	return entries, nil
+}
+
+// readRecords reads all records from a utmp formatted file
+func (l *CheckUsers) readRecords(file string) ([]utmpRecord, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", file, err)
+	}
+	defer fh.Close()
+
+	records := []utmpRecord{}
+	reader := bufio.NewReader(fh)
+	for {
+		record := utmpRecord{}
+		err := binary.Read(reader, binary.NativeEndian, &record)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %s", file, err.Error())
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func (l *CheckUsers) recordEntry(record *utmpRecord, entryType string) map[string]string {
+	host := l.cString(record.Host[:])
+	remote := "0"
+	if host != "" && !strings.HasPrefix(host, ":") {
+		remote = "1"
+	}
+
+	return map[string]string{
+		"type":   entryType,
+		"user":   l.cString(record.User[:]),
+		"tty":    l.cString(record.Line[:]),
+		"host":   host,
+		"remote": remote,
+		"pid":    fmt.Sprintf("%d", record.Pid),
+		"time":   fmt.Sprintf("%d", record.TvSec),
+		"idle":   "",
+		"clean":  "",
+	}
+}
+
+// cString returns the string up to the first null byte
+func (l *CheckUsers) cString(data []byte) string {
+	if idx := bytes.IndexByte(data, 0); idx >= 0 {
+		data = data[:idx]
+	}
+
+	return string(data)
+}
+
+// idleTime returns seconds since the last access of the terminal device
+func (l *CheckUsers) idleTime(tty string) string {
+	if tty == "" || strings.Contains(tty, "..") {
+		return ""
+	}
+
+	info, err := os.Stat(filepath.Join(usersDevPath, tty))
+	if err != nil {
+		return ""
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+
+	idle := time.Now().Unix() - stat.Atim.Sec
+	if idle < 0 {
+		idle = 0
+	}
+
+	return fmt.Sprintf("%d", idle)
+}
@@ -0,0 +1,106 @@
+package snclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUsers(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	tmpDir := t.TempDir()
+	origUtmp, origWtmp, origBtmp, origDev := usersUtmpFile, usersWtmpFile, usersBtmpFile, usersDevPath
+	usersUtmpFile = filepath.Join(tmpDir, "utmp")
+	usersWtmpFile = filepath.Join(tmpDir, "wtmp")
+	usersBtmpFile = filepath.Join(tmpDir, "btmp")
+	usersDevPath = filepath.Join(tmpDir, "dev")
+	defer func() {
+		usersUtmpFile, usersWtmpFile, usersBtmpFile, usersDevPath = origUtmp, origWtmp, origBtmp, origDev
+	}()
+
+	now := time.Now().Unix()
+	writeTestUtmp(t, usersUtmpFile, []utmpRecord{
+		testUtmpRecord(utmpBootTime, "reboot", "~", "", now-7200),
+		testUtmpRecord(utmpUserProcess, "alice", "tty1", "", now-3600),
+		testUtmpRecord(utmpUserProcess, "root", "pts/0", "10.0.2.2", now-600),
+		testUtmpRecord(8, "", "pts/1", "", now-500),
+	})
+
+	// pts/0 was idle for 5 minutes
+	writeTestFile(t, filepath.Join(usersDevPath, "pts", "0"), "")
+	idleSince := time.Unix(now-300, 0)
+	require.NoError(t, os.Chtimes(filepath.Join(usersDevPath, "pts", "0"), idleSince, idleSince))
+
+	res := snc.RunCheck("check_users", []string{})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - 2 user(s) logged in |'count'=2;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_users", []string{"warn=user = 'root' && remote = 1", "detail-syntax=${user} ${host} ${idle | duration}"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - warning(root 10.0.2.2 5m) |'count'=2;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	// history with a clean reboot two days ago, an unexpected reboot an hour ago and failed logins
+	writeTestUtmp(t, usersWtmpFile, []utmpRecord{
+		testUtmpRecord(utmpRunLevel, "shutdown", "~~", "", now-172900),
+		testUtmpRecord(utmpBootTime, "reboot", "~", "", now-172800),
+		testUtmpRecord(utmpUserProcess, "alice", "tty1", "", now-172000),
+		testUtmpRecord(utmpBootTime, "reboot", "~", "", now-3600),
+		testUtmpRecord(utmpUserProcess, "root", "pts/0", "10.0.2.2", now-600),
+	})
+	writeTestUtmp(t, usersBtmpFile, []utmpRecord{
+		testUtmpRecord(6, "admin", "ssh:notty", "192.0.2.7", now-7200),
+		testUtmpRecord(6, "admin", "ssh:notty", "192.0.2.7", now-120),
+		testUtmpRecord(6, "root", "ssh:notty", "192.0.2.7", now-60),
+	})
+
+	res = snc.RunCheck("check_users", []string{"mode=history"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - 5 entries in history |'count'=5;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_users", []string{"mode=history", "scan-range=10m", "filter=type = 'failed'", "crit=count > 1"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - found 2 entries |'count'=2;;1;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_users", []string{"mode=history", "scan-range=3d", "filter=type = 'reboot'", "warn=clean = 0"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Equalf(t, "WARNING - warning(reboot reboot (~)) |'count'=2;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_users", []string{"mode=history", "scan-range=1m", "filter=type = 'reboot'"})
+	assert.Equalf(t, CheckExitOK, res.State, "state OK")
+	assert.Equalf(t, "OK - no entries found |'count'=0;;;0", string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_users", []string{"mode=last"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+}
+
+func testUtmpRecord(recordType int16, user, line, host string, timestamp int64) utmpRecord {
+	record := utmpRecord{
+		Type:  recordType,
+		Pid:   1234,
+		TvSec: int32(timestamp),
+	}
+	copy(record.User[:], user)
+	copy(record.Line[:], line)
+	copy(record.Host[:], host)
+
+	return record
+}
+
+func writeTestUtmp(t *testing.T, file string, records []utmpRecord) {
+	t.Helper()
+
+	buf := bytes.Buffer{}
+	for i := range records {
+		err := binary.Write(&buf, binary.NativeEndian, &records[i])
+		require.NoError(t, err)
+	}
+	writeTestFile(t, file, buf.String())
+}
@@ -0,0 +1,542 @@
+package snclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/consol-monitoring/snclient/pkg/convert"
+)
+
+func init() {
+	AvailableChecks["check_zfs"] = CheckEntry{"check_zfs", NewCheckZFS}
+}
+
+var (
+	reZpoolScanDate = regexp.MustCompile(`\s(?:on|since)\s+(\w{3}\s+\w{3}\s+\d+\s+[\d:]+\s+\d{4})`)
+	reZpoolErrors   = regexp.MustCompile(`^(\d+)\s+data errors`)
+)
+
+// zpool status prints dates in ctime format
+const zpoolDateFormat = "Mon Jan _2 15:04:05 2006"
+
+// zpoolJSONValue contains numbers which are printed as strings or numbers depending on --json-int
+type zpoolJSONValue string
+
+func (v *zpoolJSONValue) UnmarshalJSON(data []byte) error {
+	str := ""
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &str); err != nil {
+			return fmt.Errorf("json: %s", err.Error())
+		}
+	} else {
+		str = string(data)
+	}
+	*v = zpoolJSONValue(str)
+
+	return nil
+}
+
+type zpoolJSONVdev struct {
+	Name           string                   `json:"name"`
+	VdevType       string                   `json:"vdev_type"`
+	State          string                   `json:"state"`
+	ReadErrors     zpoolJSONValue           `json:"read_errors"`
+	WriteErrors    zpoolJSONValue           `json:"write_errors"`
+	ChecksumErrors zpoolJSONValue           `json:"checksum_errors"`
+	Vdevs          map[string]zpoolJSONVdev `json:"vdevs"`
+}
+
+type zpoolJSONStatus struct {
+	Pools map[string]struct {
+		Name       string                   `json:"name"`
+		State      string                   `json:"state"`
+		ErrorCount zpoolJSONValue           `json:"error_count"`
+		Vdevs      map[string]zpoolJSONVdev `json:"vdevs"`
+		Logs       map[string]zpoolJSONVdev `json:"logs"`
+		L2Cache    map[string]zpoolJSONVdev `json:"l2cache"`
+		Spares     map[string]zpoolJSONVdev `json:"spares"`
+		Special    map[string]zpoolJSONVdev `json:"special"`
+		Dedup      map[string]zpoolJSONVdev `json:"dedup"`
+		ScanStats  *struct {
+			Function zpoolJSONValue `json:"function"`
+			State    zpoolJSONValue `json:"state"`
+			EndTime  zpoolJSONValue `json:"end_time"`
+		} `json:"scan_stats"`
+	} `json:"pools"`
+}
+
+// zpoolVdev contains the state and error counters of a single vdev
+type zpoolVdev struct {
+	name     string
+	state    string
+	read     int64
+	write    int64
+	checksum int64
+}
+
+type CheckZFS struct {
+	snc   *Agent
+	pools []string
+	vdevs map[string][]zpoolVdev // vdevs by pool name
+}
+
+func NewCheckZFS() CheckHandler {
+	return &CheckZFS{}
+}
+
+func (l *CheckZFS) Build() *CheckData {
+	return &CheckData{
+		name:         "check_zfs",
+		description:  "Checks the health, capacity and error counters of zfs pools. The error counters are also added as metrics for each vdev.",
+		implemented:  Linux | FreeBSD,
+		hasInventory: NoCallInventory,
+		result: &CheckResult{
+			State: CheckExitOK,
+		},
+		args: map[string]CheckArgument{
+			"pool": {value: &l.pools, isFilter: true, description: "Check this pool only (default: all imported pools)"},
+		},
+		defaultWarning:  "used_pct > 80 || read_errors > 0 || write_errors > 0 || checksum_errors > 0",
+		defaultCritical: "state != 'ONLINE' || used_pct > 90 || data_errors > 0",
+		detailSyntax:    "${name} ${state} (used: ${used_pct}%)",
+		okSyntax:        "%(status) - all %{count} zfs pools are ok",
+		topSyntax:       "%(status) - %(problem_list)",
+		emptyState:      CheckExitUnknown,
+		emptySyntax:     "%(status) - no zfs pools found",
+		attributes: []CheckAttribute{
+			{name: "name", description: "Name of the pool"},
+			{name: "state", description: "Pool health, ex.: ONLINE, DEGRADED, FAULTED, OFFLINE, UNAVAIL, REMOVED or SUSPENDED"},
+			{name: "size", description: "Total size of the pool", unit: UByte},
+			{name: "used", description: "Allocated bytes", unit: UByte},
+			{name: "free", description: "Free bytes", unit: UByte},
+			{name: "used_pct", description: "Allocated space in percent", unit: UPercent},
+			{name: "fragmentation", description: "Fragmentation of the free space in percent", unit: UPercent},
+			{name: "dedup", description: "Deduplication ratio, ex.: 1.00"},
+			{name: "scan", description: "Status of the last scrub or resilver, ex.: scrub repaired 0B in 00:06:10 with 0 errors on Sun Oct 13 00:30:11 2024"},
+			{name: "scan_function", description: "Last or current scan function: scrub, resilver or none"},
+			{name: "scan_state", description: "State of the last scan: finished, scanning, paused, canceled or none"},
+			{name: "last_scrub", description: "Unix timestamp when the last scrub finished", unit: UTimestamp},
+			{name: "scrub_age", description: "Seconds since the last scrub finished", unit: UDuration},
+			{name: "read_errors", description: "Sum of read errors of all vdevs"},
+			{name: "write_errors", description: "Sum of write errors of all vdevs"},
+			{name: "checksum_errors", description: "Sum of checksum errors of all vdevs"},
+			{name: "data_errors", description: "Number of known data errors"},
+			{name: "vdevs", description: "Number of vdevs (including the pool root)"},
+			{name: "faulty_vdevs", description: "Comma separated list of vdevs with errors or not being online, ex.: sdb FAULTED (read:3 write:0 cksum:12)"},
+		},
+		exampleDefault: `
+    check_zfs
+    OK - all 2 zfs pools are ok |'rpool_used'=18253611008B;;;0;107374182400 'rpool_used_pct'=17%;80;90;0;100 ... 'rpool_nvme0n1p3_read_errors'=0;0;;0 ...
+
+    check_zfs
+    CRITICAL - tank DEGRADED (used: 45%) |'tank_used'=...
+
+Warn if the last scrub is older than 5 weeks:
+
+    check_zfs warn="used_pct > 80 || scrub_age > 35d"
+	`,
+		exampleArgs: `pool=tank warn="used_pct > 80 || fragmentation > 50"`,
+	}
+}
+
+func (l *CheckZFS) Check(ctx context.Context, snc *Agent, check *CheckData, _ []Argument) (*CheckResult, error) {
+	l.snc = snc
+	l.vdevs = map[string][]zpoolVdev{}
+
+	pools, err := l.listPools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.addStatus(ctx, pools)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range pools {
+		if len(l.pools) > 0 && !slices.Contains(l.pools, entry["name"]) {
+			continue
+		}
+
+		if !check.MatchMapCondition(check.filter, entry, true) {
+			continue
+		}
+
+		check.listData = append(check.listData, entry)
+		l.addMetrics(check, entry)
+	}
+
+	return check.Finalize()
+}
+
+// listPools returns one entry per pool from zpool list
+func (l *CheckZFS) listPools(ctx context.Context) ([]map[string]string, error) {
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, "zpool list -Hp -o name,size,alloc,free,frag,cap,dedup,health", l.snc.getBuiltinCmdTimeout())
+	if err != nil {
+		return nil, fmt.Errorf("zpool list failed: %s\n%s", err.Error(), stderr)
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("zpool list failed (exit code %d): %s", exitCode, strings.TrimSpace(output+"\n"+stderr))
+	}
+
+	return l.parseList(output), nil
+}
+
+// parseList parses tab separated zpool list -Hp output
+func (l *CheckZFS) parseList(output string) []map[string]string {
+	pools := []map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		cols := strings.Split(strings.TrimSpace(line), "\t")
+		if len(cols) < 8 {
+			continue
+		}
+
+		entry := map[string]string{
+			"name":            cols[0],
+			"size":            cols[1],
+			"used":            cols[2],
+			"free":            cols[3],
+			"fragmentation":   l.parseNumber(cols[4]),
+			"used_pct":        l.parseNumber(cols[5]),
+			"dedup":           l.parseNumber(cols[6]),
+			"state":           cols[7],
+			"scan":            "",
+			"scan_function":   "none",
+			"scan_state":      "none",
+			"last_scrub":      "",
+			"scrub_age":       "",
+			"read_errors":     "0",
+			"write_errors":    "0",
+			"checksum_errors": "0",
+			"data_errors":     "0",
+			"vdevs":           "0",
+			"faulty_vdevs":    "",
+		}
+		pools = append(pools, entry)
+	}
+
+	return pools
+}
+
+// parseNumber removes units like % or x and returns empty string for unavailable values
+func (l *CheckZFS) parseNumber(val string) string {
+	val = strings.TrimRight(val, "%x")
+	if val == "-" {
+		return ""
+	}
+
+	return val
+}
+
+// addStatus adds vdev and scan details from zpool status, json output is used if available (OpenZFS >= 2.3)
+func (l *CheckZFS) addStatus(ctx context.Context, pools []map[string]string) error {
+	output, _, exitCode, err := l.snc.execCommand(ctx, "zpool status -j --json-int", l.snc.getBuiltinCmdTimeout())
+	if err == nil && exitCode == 0 && strings.HasPrefix(strings.TrimSpace(output), "{") {
+		status := zpoolJSONStatus{}
+		if err := json.Unmarshal([]byte(output), &status); err == nil {
+			l.parseJSONStatus(&status, pools)
+
+			return nil
+		}
+	}
+
+	output, stderr, exitCode, err := l.snc.execCommand(ctx, "zpool status -p", l.snc.getBuiltinCmdTimeout())
+	if err != nil {
+		return fmt.Errorf("zpool status failed: %s\n%s", err.Error(), stderr)
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("zpool status failed (exit code %d): %s", exitCode, strings.TrimSpace(output+"\n"+stderr))
+	}
+
+	l.parseStatus(output, pools)
+
+	return nil
+}
+
+// parseStatus parses the text output of zpool status -p
+func (l *CheckZFS) parseStatus(output string, pools []map[string]string) {
+	var entry map[string]string
+	vdevs := []zpoolVdev{}
+	inConfig := false
+
+	finishPool := func() {
+		if entry != nil {
+			l.setVdevs(entry, vdevs)
+		}
+		vdevs = []zpoolVdev{}
+		inConfig = false
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		key, val, _ := strings.Cut(trimmed, ":")
+		val = strings.TrimSpace(val)
+
+		switch {
+		case key == "pool":
+			finishPool()
+			entry = l.findPool(pools, val)
+		case entry == nil:
+			continue
+		case key == "state":
+			entry["state"] = val
+		case key == "scan":
+			l.setScan(entry, val)
+		case key == "config":
+			inConfig = true
+		case key == "errors":
+			inConfig = false
+			if matches := reZpoolErrors.FindStringSubmatch(val); matches != nil {
+				entry["data_errors"] = matches[1]
+			}
+		case inConfig:
+			fields := strings.Fields(trimmed)
+			if len(fields) < 5 || fields[0] == "NAME" {
+				continue
+			}
+			vdev := zpoolVdev{name: fields[0], state: fields[1]}
+			var err1, err2, err3 error
+			vdev.read, err1 = convert.Int64E(fields[2])
+			vdev.write, err2 = convert.Int64E(fields[3])
+			vdev.checksum, err3 = convert.Int64E(fields[4])
+			if err1 != nil || err2 != nil || err3 != nil {
+				continue
+			}
+			vdevs = append(vdevs, vdev)
+		}
+	}
+	finishPool()
+}
+
+// parseJSONStatus parses the output of zpool status -j
+func (l *CheckZFS) parseJSONStatus(status *zpoolJSONStatus, pools []map[string]string) {
+	for name := range status.Pools {
+		pool := status.Pools[name]
+		entry := l.findPool(pools, name)
+		if entry == nil {
+			continue
+		}
+
+		entry["state"] = pool.State
+		if pool.ErrorCount != "" {
+			entry["data_errors"] = string(pool.ErrorCount)
+		}
+
+		vdevs := []zpoolVdev{}
+		for _, group := range []map[string]zpoolJSONVdev{pool.Vdevs, pool.Logs, pool.L2Cache, pool.Special, pool.Dedup, pool.Spares} {
+			vdevs = l.flattenJSONVdevs(vdevs, group)
+		}
+		l.setVdevs(entry, vdevs)
+
+		if pool.ScanStats != nil {
+			function := strings.ToLower(string(pool.ScanStats.Function))
+			state := strings.ToLower(string(pool.ScanStats.State))
+			entry["scan_function"] = function
+			entry["scan_state"] = state
+			entry["scan"] = function + " " + state
+			if function == "scrub" && state == "finished" {
+				l.setLastScrub(entry, string(pool.ScanStats.EndTime))
+				entry["scan"] += " on " + string(pool.ScanStats.EndTime)
+			}
+		}
+	}
+}
+
+func (l *CheckZFS) flattenJSONVdevs(vdevs []zpoolVdev, group map[string]zpoolJSONVdev) []zpoolVdev {
+	names := make([]string, 0, len(group))
+	for name := range group {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		vdev := group[name]
+		vdevs = append(vdevs, zpoolVdev{
+			name:     name,
+			state:    vdev.State,
+			read:     convert.Int64(string(vdev.ReadErrors)),
+			write:    convert.Int64(string(vdev.WriteErrors)),
+			checksum: convert.Int64(string(vdev.ChecksumErrors)),
+		})
+		vdevs = l.flattenJSONVdevs(vdevs, vdev.Vdevs)
+	}
+
+	return vdevs
+}
+
+func (l *CheckZFS) findPool(pools []map[string]string, name string) map[string]string {
+	for _, entry := range pools {
+		if entry["name"] == name {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// setVdevs sums up the error counters and collects all vdevs with problems
+func (l *CheckZFS) setVdevs(entry map[string]string, vdevs []zpoolVdev) {
+	var read, write, checksum int64
+	faulty := []string{}
+	for _, vdev := range vdevs {
+		read += vdev.read
+		write += vdev.write
+		checksum += vdev.checksum
+
+		if vdev.name == entry["name"] {
+			continue
+		}
+		switch {
+		case vdev.read > 0, vdev.write > 0, vdev.checksum > 0:
+		case vdev.state == "ONLINE", vdev.state == "AVAIL", vdev.state == "INUSE":
+			continue
+		}
+		faulty = append(faulty, fmt.Sprintf("%s %s (read:%d write:%d cksum:%d)", vdev.name, vdev.state, vdev.read, vdev.write, vdev.checksum))
+	}
+
+	entry["read_errors"] = fmt.Sprintf("%d", read)
+	entry["write_errors"] = fmt.Sprintf("%d", write)
+	entry["checksum_errors"] = fmt.Sprintf("%d", checksum)
+	entry["vdevs"] = fmt.Sprintf("%d", len(vdevs))
+	entry["faulty_vdevs"] = strings.Join(faulty, ", ")
+	l.vdevs[entry["name"]] = vdevs
+}
+
+// setScan parses the scan line, ex.: scrub repaired 0B in 00:06:10 with 0 errors on Sun Oct 13 00:30:11 2024
+func (l *CheckZFS) setScan(entry map[string]string, scan string) {
+	entry["scan"] = scan
+
+	switch {
+	case strings.HasPrefix(scan, "scrub repaired"):
+		entry["scan_function"] = "scrub"
+		entry["scan_state"] = "finished"
+		if matches := reZpoolScanDate.FindStringSubmatch(scan); matches != nil {
+			l.setLastScrub(entry, matches[1])
+		}
+	case strings.HasPrefix(scan, "resilvered"):
+		entry["scan_function"] = "resilver"
+		entry["scan_state"] = "finished"
+	case strings.HasPrefix(scan, "none requested"):
+		entry["scan_function"] = "none"
+		entry["scan_state"] = "none"
+	default:
+		// ex.: scrub in progress since ..., resilver in progress since ..., scrub canceled on ..., scrub paused since ...
+		function, rest, _ := strings.Cut(scan, " ")
+		entry["scan_function"] = function
+		switch {
+		case strings.HasPrefix(rest, "in progress"):
+			entry["scan_state"] = "scanning"
+		case strings.HasPrefix(rest, "paused"):
+			entry["scan_state"] = "paused"
+		case strings.HasPrefix(rest, "canceled"):
+			entry["scan_state"] = "canceled"
+		}
+	}
+}
+
+// setLastScrub sets last_scrub and scrub_age from a unix timestamp or ctime date
+func (l *CheckZFS) setLastScrub(entry map[string]string, date string) {
+	date = strings.TrimSpace(date)
+	if date == "" {
+		return
+	}
+
+	var scrubTime time.Time
+	if epoch, err := convert.Int64E(date); err == nil {
+		scrubTime = time.Unix(epoch, 0)
+	} else {
+		parsed, err := time.ParseInLocation(zpoolDateFormat, date, time.Local)
+		if err != nil {
+			log.Debugf("failed to parse zpool scan date %q: %s", date, err.Error())
+
+			return
+		}
+		scrubTime = parsed
+	}
+
+	entry["last_scrub"] = fmt.Sprintf("%d", scrubTime.Unix())
+	entry["scrub_age"] = fmt.Sprintf("%d", int64(time.Since(scrubTime).Seconds()))
+}
+
+func (l *CheckZFS) addMetrics(check *CheckData, entry map[string]string) {
+	size := convert.Float64(entry["size"])
+	check.result.Metrics = append(check.result.Metrics,
+		&CheckMetric{
+			Name:          entry["name"] + "_used",
+			ThresholdName: "used",
+			Unit:          "B",
+			Value:         convert.Int64(entry["used"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+			Max:           &size,
+		},
+		&CheckMetric{
+			Name:          entry["name"] + "_used_pct",
+			ThresholdName: "used_pct",
+			Unit:          "%",
+			Value:         convert.Float64(entry["used_pct"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+			Max:           &Hundred,
+		},
+	)
+
+	if entry["fragmentation"] != "" {
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["name"] + "_fragmentation",
+			ThresholdName: "fragmentation",
+			Unit:          "%",
+			Value:         convert.Float64(entry["fragmentation"]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+			Max:           &Hundred,
+		})
+	}
+
+	for _, name := range []string{"read_errors", "write_errors", "checksum_errors"} {
+		check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+			Name:          entry["name"] + "_" + name,
+			ThresholdName: name,
+			Value:         convert.Int64(entry[name]),
+			Warning:       check.warnThreshold,
+			Critical:      check.critThreshold,
+			Min:           &Zero,
+		})
+	}
+
+	// error counters of each vdev, the pool root is already covered by the sums above
+	for _, vdev := range l.vdevs[entry["name"]] {
+		if vdev.name == entry["name"] {
+			continue
+		}
+		for _, counter := range []struct {
+			name  string
+			value int64
+		}{
+			{"read_errors", vdev.read},
+			{"write_errors", vdev.write},
+			{"checksum_errors", vdev.checksum},
+		} {
+			check.result.Metrics = append(check.result.Metrics, &CheckMetric{
+				Name:          entry["name"] + "_" + vdev.name + "_" + counter.name,
+				ThresholdName: counter.name,
+				Value:         counter.value,
+				Warning:       check.warnThreshold,
+				Critical:      check.critThreshold,
+				Min:           &Zero,
+			})
+		}
+	}
+}
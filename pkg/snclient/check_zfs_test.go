@@ -0,0 +1,117 @@
+//go:build !windows
+
+package snclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorded output of: zpool list -Hp -o name,size,alloc,free,frag,cap,dedup,health
+const zpoolListOutput = "rpool\t107374182400\t18253611008\t89120571392\t4\t17\t1.00\tONLINE\n" +
+	"tank\t3985729650688\t1793578336256\t2192151314432\t12\t45\t1.00\tDEGRADED"
+
+// recorded (and shortened) output of: zpool status -p
+const zpoolStatusOutput = `  pool: rpool
+ state: ONLINE
+  scan: scrub repaired 0B in 00:01:02 with 0 errors on Sun Oct 13 00:25:03 2024
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	rpool       ONLINE       0     0     0
+	  nvme0n1p3 ONLINE       0     0     0
+
+errors: No known data errors
+
+  pool: tank
+ state: DEGRADED
+status: One or more devices are faulted in response to persistent errors.
+	Sufficient replicas exist for the pool to continue functioning in a
+	degraded state.
+action: Replace the faulted device, or use 'zpool clear' to mark the device
+	repaired.
+  scan: resilvered 1.21G in 00:02:11 with 0 errors on Mon Oct 14 09:12:44 2024
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        DEGRADED     0     0     0
+	  mirror-0  DEGRADED     0     0     0
+	    sda     ONLINE       0     0     0
+	    sdb     FAULTED      3     0    12  too many errors
+	spares
+	  sdc       AVAIL
+
+errors: 2 data errors, use '-v' for a list`
+
+// recorded (and shortened) output of: zpool status -j --json-int
+const zpoolStatusJSON = `{
+  "output_version": {"command": "zpool status", "vers_major": 0, "vers_minor": 1},
+  "pools": {
+    "rpool": {
+      "name": "rpool", "state": "ONLINE", "error_count": 0,
+      "vdevs": {
+        "rpool": {
+          "name": "rpool", "vdev_type": "root", "state": "ONLINE", "read_errors": 0, "write_errors": 0, "checksum_errors": 0,
+          "vdevs": {
+            "nvme0n1p3": {"name": "nvme0n1p3", "vdev_type": "disk", "state": "ONLINE", "read_errors": 0, "write_errors": 0, "checksum_errors": 2}
+          }
+        }
+      },
+      "scan_stats": {"function": "SCRUB", "state": "FINISHED", "start_time": 1728771901, "end_time": 1728772003, "errors": 0}
+    }
+  }
+}`
+
+func TestCheckZFS(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	MockSystemUtilityArgs(t, "zpool", 0, [][2]string{
+		{"list -Hp", zpoolListOutput},
+		{"status -p", zpoolStatusOutput},
+	})
+
+	res := snc.RunCheck("check_zfs", []string{})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Equalf(t, "CRITICAL - critical(tank DEGRADED (used: 45%)) |"+
+		"'rpool_used'=18253611008B;;;0;107374182400 'rpool_used_pct'=17%;80;90;0;100 'rpool_fragmentation'=4%;;;0;100 "+
+		"'rpool_read_errors'=0;0;;0 'rpool_write_errors'=0;0;;0 'rpool_checksum_errors'=0;0;;0 "+
+		"'rpool_nvme0n1p3_read_errors'=0;0;;0 'rpool_nvme0n1p3_write_errors'=0;0;;0 'rpool_nvme0n1p3_checksum_errors'=0;0;;0 "+
+		"'tank_used'=1793578336256B;;;0;3985729650688 'tank_used_pct'=45%;80;90;0;100 'tank_fragmentation'=12%;;;0;100 "+
+		"'tank_read_errors'=3;0;;0 'tank_write_errors'=0;0;;0 'tank_checksum_errors'=12;0;;0 "+
+		"'tank_mirror-0_read_errors'=0;0;;0 'tank_mirror-0_write_errors'=0;0;;0 'tank_mirror-0_checksum_errors'=0;0;;0 "+
+		"'tank_sda_read_errors'=0;0;;0 'tank_sda_write_errors'=0;0;;0 'tank_sda_checksum_errors'=0;0;;0 "+
+		"'tank_sdb_read_errors'=3;0;;0 'tank_sdb_write_errors'=0;0;;0 'tank_sdb_checksum_errors'=12;0;;0",
+		string(res.BuildPluginOutput()), "output matches")
+
+	res = snc.RunCheck("check_zfs", []string{"pool=tank", "detail-syntax=${name} ${faulty_vdevs} data_errors:${data_errors} ${scan_function}"})
+	assert.Equalf(t, CheckExitCritical, res.State, "state Critical")
+	assert.Containsf(t, string(res.BuildPluginOutput()),
+		"CRITICAL - tank mirror-0 DEGRADED (read:0 write:0 cksum:0), sdb FAULTED (read:3 write:0 cksum:12) data_errors:2 resilver |",
+		"output matches")
+
+	res = snc.RunCheck("check_zfs", []string{"pool=rpool", "warn=scrub_age > 1d", "crit=none", "detail-syntax=${name} ${scan_function} ${scan_state}"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "WARNING - rpool scrub finished |", "output matches")
+
+	res = snc.RunCheck("check_zfs", []string{"pool=none"})
+	assert.Equalf(t, CheckExitUnknown, res.State, "state Unknown")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "UNKNOWN - no zfs pools found", "output matches")
+}
+
+func TestCheckZFSJSON(t *testing.T) {
+	snc := StartTestAgent(t, "")
+	defer StopTestAgent(t, snc)
+
+	MockSystemUtilityArgs(t, "zpool", 0, [][2]string{
+		{"list -Hp", zpoolListOutput},
+		{"status -j", zpoolStatusJSON},
+	})
+
+	res := snc.RunCheck("check_zfs", []string{"pool=rpool", "show-all", "detail-syntax=${name} ${state} cksum:${checksum_errors} ${scan} last:${last_scrub}"})
+	assert.Equalf(t, CheckExitWarning, res.State, "state Warning")
+	assert.Containsf(t, string(res.BuildPluginOutput()),
+		"WARNING - rpool ONLINE cksum:2 scrub finished on 1728772003 last:1728772003 |", "output matches")
+	assert.Containsf(t, string(res.BuildPluginOutput()), "'rpool_nvme0n1p3_checksum_errors'=2;0;;0", "vdev error counter from json")
+}
@@ -136,6 +136,7 @@ type CheckData struct {
 	perfConfig                    []PerfConfig
 	perfSyntax                    string
 	hasInventory                  InventoryMode
+	inventoryName                 string // name of the inventory section, defaults to the check name without check_ prefix
 	output                        OutputMode
 	implemented                   Implemented
 	attributes                    []CheckAttribute
@@ -587,6 +587,10 @@ func (config *Config) getCombine(section, key string) (combine, trim string) {
 		if key == "allowed pattern" {
 			return " , ", ", "
 		}
+	case "/settings/check/sysctl":
+		if key == "allowed keys" || key == "allowed files" {
+			return " , ", ", "
+		}
 	default:
 		if key == "allowed hosts" {
 			return " , ", ", "
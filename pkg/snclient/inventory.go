@@ -119,11 +119,14 @@ func (snc *Agent) buildInventory(ctx context.Context, modules []string) *Invento
 
 			continue
 		}
+		name := strings.TrimPrefix(check.Name, "check_")
+		if meta.inventoryName != "" {
+			name = meta.inventoryName
+		}
 		switch meta.hasInventory {
 		case NoInventory:
 			// skipped
 		case ListInventory:
-			name := strings.TrimPrefix(check.Name, "check_")
 			if len(modules) > 0 && (!slices.Contains(modules, name)) {
 				continue
 			}
@@ -138,7 +141,6 @@ func (snc *Agent) buildInventory(ctx context.Context, modules []string) *Invento
 
 			inventory[name] = data.Raw.listData
 		case NoCallInventory:
-			name := strings.TrimPrefix(check.Name, "check_")
 			if len(modules) > 0 && !slices.Contains(modules, name) {
 				continue
 			}
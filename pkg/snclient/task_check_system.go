@@ -3,7 +3,9 @@ package snclient
 import (
 	"bufio"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"slices"
@@ -23,11 +25,18 @@ const (
 
 // DefaultSystemTaskConfig sets defaults for windows and unix system task
 var DefaultSystemTaskConfig = ConfigData{
+	"cgroup depth":          "2",
 	"default buffer length": "15m",
 	"device filter":         "^veth",
 	"metrics interval":      "5s",
 }
 
+// cgroupPath is the mount point of the cgroup v2 unified hierarchy
+var cgroupPath = "/sys/fs/cgroup"
+
+// netstatFiles contains the linux protocol statistics used by check_netstat
+var netstatFiles = []string{"/proc/net/snmp", "/proc/net/netstat", "/proc/net/snmp6"}
+
 // initialization function first discovers partitions
 // depending on their type, corresponding device of that partition is added
 // non-physical drives are not added to IO counters
@@ -97,10 +106,16 @@ type CheckSystemHandler struct {
 	bufferLength    time.Duration
 	metricsInterval time.Duration
 	deviceFilter    []regexp.Regexp
+	cgroupDepth     int
+	cgroupPath      string
+	netstatFiles    []string
 }
 
 func NewCheckSystemHandler() Module {
-	return &CheckSystemHandler{}
+	return &CheckSystemHandler{
+		cgroupPath:   cgroupPath,
+		netstatFiles: netstatFiles,
+	}
 }
 
 func (c *CheckSystemHandler) Init(snc *Agent, section *ConfigSection, _ *Config, _ *AgentRunSet) error {
@@ -135,6 +150,12 @@ func (c *CheckSystemHandler) Init(snc *Agent, section *ConfigSection, _ *Config,
 		c.deviceFilter = []regexp.Regexp{*deviceFilter}
 	}
 
+	cgroupDepth, _, err := section.GetInt("cgroup depth")
+	if err != nil {
+		return fmt.Errorf("cgroup depth: %s", err.Error())
+	}
+	c.cgroupDepth = int(cgroupDepth)
+
 	// create counter
 	c.update(true)
 
@@ -222,6 +243,8 @@ func (c *CheckSystemHandler) update(create bool) {
 
 	if runtime.GOOS == "linux" {
 		c.addLinuxKernelStats(create)
+		c.addLinuxCgroupStats()
+		c.addLinuxNetstatStats()
 	}
 
 	// Windows and Non-Windows have their own definitions for this
@@ -297,3 +320,126 @@ func (c *CheckSystemHandler) addLinuxKernelStats(create bool) {
 		}
 	}
 }
+
+// addLinuxCgroupStats collects the cpu usage of cgroup v2 groups up to the configured depth, used by check_cgroup to calculate cpu rates
+func (c *CheckSystemHandler) addLinuxCgroupStats() {
+	if c.cgroupDepth <= 0 {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(c.cgroupPath, "cgroup.controllers")); err != nil {
+		return
+	}
+
+	_ = filepath.WalkDir(c.cgroupPath, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil || !dirEntry.IsDir() {
+			return nil //nolint:nilerr // skip unreadable cgroups
+		}
+		key := cgroupName(c.cgroupPath, path)
+		if key != "/" && strings.Count(key, "/")+1 > c.cgroupDepth {
+			return fs.SkipDir
+		}
+		usage, ok := readCgroupKeyValue(filepath.Join(path, "cpu.stat"))["usage_usec"]
+		if !ok {
+			return nil
+		}
+		if c.snc.Counter.Get("cgroup", key) == nil {
+			c.snc.counterCreate("cgroup", key, c.bufferLength, c.metricsInterval)
+		}
+		c.snc.Counter.Set("cgroup", key, convert.Float64(usage))
+
+		return nil
+	})
+
+	// remove cgroups not updated within the bufferLength
+	trimData := time.Now().Add(-c.bufferLength).UnixMilli()
+	for _, key := range c.snc.Counter.Keys("cgroup") {
+		last := c.snc.Counter.Get("cgroup", key).GetLast()
+		if last.UnixMilli < trimData {
+			log.Tracef("removed old cgroup: %s (last update: %s)", key, time.UnixMilli(last.UnixMilli).String())
+			c.snc.Counter.Delete("cgroup", key)
+		}
+	}
+}
+
+// addLinuxNetstatStats collects the protocol counters, used by check_netstat to calculate rates
+func (c *CheckSystemHandler) addLinuxNetstatStats() {
+	for key, val := range readNetstatCounters(c.netstatFiles) {
+		if c.snc.Counter.Get("netstat", key) == nil {
+			c.snc.counterCreate("netstat", key, c.bufferLength, c.metricsInterval)
+		}
+		c.snc.Counter.Set("netstat", key, val)
+	}
+}
+
+// readNetstatCounters returns all protocol counters from the given files, keys are <protocol>.<counter>, ex.: TcpExt.ListenDrops
+func readNetstatCounters(files []string) map[string]float64 {
+	counters := map[string]float64{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(string(data), "\n")
+		for i := 0; i < len(lines); i++ {
+			fields := strings.Fields(lines[i])
+			switch {
+			case len(fields) == 0:
+				continue
+			case strings.HasSuffix(fields[0], ":"):
+				// snmp and netstat use pairs of header and value lines, ex.:
+				// Udp: InDatagrams NoPorts InErrors
+				// Udp: 1234 5 0
+				if i+1 >= len(lines) {
+					continue
+				}
+				values := strings.Fields(lines[i+1])
+				i++
+				if len(values) != len(fields) || values[0] != fields[0] {
+					continue
+				}
+				proto := strings.TrimSuffix(fields[0], ":")
+				for j := 1; j < len(fields); j++ {
+					counters[proto+"."+fields[j]] = convert.Float64(values[j])
+				}
+			case len(fields) == 2:
+				// snmp6 uses one counter per line, ex.: Udp6InErrors 0
+				idx := strings.IndexByte(fields[0], '6')
+				if idx <= 0 || idx == len(fields[0])-1 {
+					continue
+				}
+				counters[fields[0][:idx+1]+"."+fields[0][idx+1:]] = convert.Float64(fields[1])
+			}
+		}
+	}
+
+	return counters
+}
+
+// cgroupName returns the path of a cgroup relative to the cgroup root, ex.: system.slice/nginx.service
+func cgroupName(root, path string) string {
+	name, err := filepath.Rel(root, path)
+	if err != nil || name == "." {
+		return "/"
+	}
+
+	return name
+}
+
+// readCgroupKeyValue parses flat keyed cgroup files like cpu.stat or memory.events
+func readCgroupKeyValue(file string) map[string]string {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	res := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			res[fields[0]] = fields[1]
+		}
+	}
+
+	return res
+}
@@ -198,3 +198,35 @@ func waitForStatusOK(t *testing.T, url string) string {
 
 	return ""
 }
+
+// MockSystemUtilityArgs creates a mock utility which prints the output of the
+// first case whose pattern is contained in the command line arguments.
+func MockSystemUtilityArgs(t *testing.T, name string, exitCode int, cases [][2]string) (utilPath string) {
+	t.Helper()
+
+	tmpPath := MockSystemUtilities(t, map[string]string{name: ""})
+
+	script := []string{"#!/bin/sh", `case "$*" in`}
+	for _, c := range cases {
+		script = append(script, fmt.Sprintf("*'%s'*)\ncat <<'MOCK_STDOUT'\n%s\nMOCK_STDOUT\n;;", c[0], c[1]))
+	}
+	script = append(script, "esac", fmt.Sprintf("exit %d", exitCode))
+
+	utilPath = filepath.Join(tmpPath, name)
+	err := os.WriteFile(utilPath, []byte(strings.Join(script, "\n")+"\n"), 0o600)
+	require.NoError(t, err)
+	err = os.Chmod(utilPath, 0o700)
+	require.NoError(t, err)
+
+	return utilPath
+}
+
+// writeTestFile creates the file including all parent folders with the given content
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	require.NoError(t, err)
+	err = os.WriteFile(path, []byte(data), 0o600)
+	require.NoError(t, err)
+}
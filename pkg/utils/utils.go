@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"cmp"
 	"crypto/md5"  //nolint:gosec // needed for md5 file hash
 	"crypto/sha1" //nolint:gosec // needed for sha1 file hash
 	"crypto/sha256"
@@ -34,6 +35,8 @@ import (
 
 var reMountPassword = regexp.MustCompile(`//.*:.*@`)
 
+var reVersionSegment = regexp.MustCompile(`\d+|[a-zA-Z]+`)
+
 var TimeFactors = []struct {
 	suffix string
 	factor float64
@@ -388,6 +391,36 @@ func ParseVersion(str string) (num float64) {
 	return num
 }
 
+// CompareVersion compares two version strings segment wise, similar to rpmvercmp.
+// Numeric segments are compared by value, alphabetic segments lexically.
+// It returns -1 if a < b, 0 if both are equal and 1 if a > b.
+func CompareVersion(verA, verB string) int {
+	segA := reVersionSegment.FindAllString(verA, -1)
+	segB := reVersionSegment.FindAllString(verB, -1)
+
+	for i := 0; i < len(segA) && i < len(segB); i++ {
+		numA, errA := strconv.ParseUint(segA[i], 10, 64)
+		numB, errB := strconv.ParseUint(segB[i], 10, 64)
+		switch {
+		case errA == nil && errB == nil:
+			if c := cmp.Compare(numA, numB); c != 0 {
+				return c
+			}
+		case errA == nil:
+			// numeric segments are newer than alphabetic ones
+			return 1
+		case errB == nil:
+			return -1
+		default:
+			if c := strings.Compare(segA[i], segB[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return cmp.Compare(len(segA), len(segB))
+}
+
 // Sha256FileSum returns sha256 sum for given file
 func Sha256FileSum(path string) (hashStr string, err error) {
 	file, err := os.Open(path)
@@ -104,6 +104,27 @@ func TestParseVersion(t *testing.T) {
 	}
 }
 
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a   string
+		b   string
+		res int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0.1", -1},
+		{"1.10", "1.9", 1},
+		{"6.1.0-26-amd64", "6.1.0-27-amd64", -1},
+		{"5.14.0-503.el9.x86_64", "5.14.0-427.13.1.el9_4.x86_64", 1},
+		{"6.11.5-arch1-1", "6.11.5-arch1-1", 0},
+		{"1.0a", "1.0.1", -1},
+	}
+
+	for _, tst := range tests {
+		res := CompareVersion(tst.a, tst.b)
+		assert.Equalf(t, tst.res, res, "CompareVersion: %v <=> %v -> %v", tst.a, tst.b, res)
+	}
+}
+
 func TestDurationString(t *testing.T) {
 	tests := []struct {
 		in  time.Duration